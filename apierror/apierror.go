@@ -0,0 +1,235 @@
+// Package apierror holds the errors reported to clients. Handlers record them
+// with Abort and the middleware writes them as RFC 7807 problem details, with
+// a stable code clients can rely on whatever the wording of the detail;
+// middlewares rejecting a request write them at once with Respond.
+// Other errors are reported as internal errors without their text, so that
+// database or provider errors never reach clients.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"one-way-ticket/repository"
+)
+
+var log = logrus.New()
+
+// ContentType is the media type of the problem details
+const ContentType = "application/problem+json"
+
+// Error is an error reported to the client with its status
+type Error struct {
+	Status int
+	// Code identifies the error for clients, e.g. "seat_taken"
+	Code   string
+	Detail string
+	// Violations lists the fields of the request breaking validation rules
+	Violations []Violation
+	// Err is the cause of the error, it is logged but never sent to clients
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same status and code, so that errors.Is finds
+// an error whatever its detail or cause
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Status == e.Status && t.Code == e.Code
+}
+
+// Wrap returns a copy of the error caused by err
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithDetail returns a copy of the error with another detail, e.g. naming
+// the seat the error is about
+func (e *Error) WithDetail(detail string) *Error {
+	detailed := *e
+	detailed.Detail = detail
+	return &detailed
+}
+
+// New creates an error reported with the status
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// Validation creates an error for a request that is malformed or invalid
+func Validation(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+// Unauthorized creates an error for a request without valid credentials
+func Unauthorized(code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+// Forbidden creates an error for a request the caller is not allowed to make
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+// NotFound creates an error for a resource that does not exist
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+// Conflict creates an error for a request conflicting with the state of a
+// resource
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Gone creates an error for a resource that existed but no longer can be used
+func Gone(code, detail string) *Error {
+	return New(http.StatusGone, code, detail)
+}
+
+// Unprocessable creates an error for a well formed request that references
+// records which do not exist or breaks a business rule
+func Unprocessable(code, detail string) *Error {
+	return New(http.StatusUnprocessableEntity, code, detail)
+}
+
+// InvalidBody creates the error for a request body that cannot be read into
+// the input of the handler
+func InvalidBody(err error) *Error {
+	return Validation("invalid_body", err.Error())
+}
+
+// Violation is a field of a request breaking a validation rule
+type Violation struct {
+	// Field is the path of the field in the body, e.g. layout.rows[1].label
+	Field string `json:"field"`
+	// Rule names the broken rule, e.g. required, email or future
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Invalid creates the error for a request whose fields break validation
+// rules, the problem lists every violation
+func Invalid(violations ...Violation) *Error {
+	e := Validation("invalid_fields", "Request has invalid fields")
+	e.Violations = violations
+	return e
+}
+
+// Internal is reported for every error that is not an Error
+var Internal = New(http.StatusInternalServerError, "internal_error", "Internal server error")
+
+// errors of the repositories that handlers report as they are
+var (
+	errNotFound         = NotFound("not_found", "Resource not found")
+	errDuplicate        = Conflict("duplicate", "Resource already exists")
+	errInvalidReference = Unprocessable("invalid_reference", "Request references a resource that does not exist")
+	errConflict         = Conflict("conflict", "Resource was changed concurrently, retry the request")
+	errInvalidCursor    = Validation("invalid_cursor", "Invalid cursor")
+)
+
+// From returns the Error reporting err: err itself, the matching error of a
+// repository error, or Internal
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return errNotFound.Wrap(err)
+	case errors.Is(err, repository.ErrDuplicate):
+		return errDuplicate.Wrap(err)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errInvalidReference.Wrap(err)
+	case errors.Is(err, repository.ErrConflict):
+		return errConflict.Wrap(err)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return errInvalidCursor.Wrap(err)
+	}
+	return Internal.Wrap(err)
+}
+
+// Problem is the body of an error response, as defined by RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Violations lists the invalid fields of a request
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Problem returns the problem reporting e on the request of c
+func (e *Error) Problem(c *gin.Context) Problem {
+	return Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(e.Status),
+		Status:     e.Status,
+		Detail:     e.Detail,
+		Instance:   c.Request.URL.Path,
+		Code:       e.Code,
+		Violations: e.Violations,
+	}
+}
+
+// Respond writes err as a problem and aborts the request
+func Respond(c *gin.Context, err error) {
+	e := From(err)
+	if e.Status >= http.StatusInternalServerError {
+		log.WithFields(logrus.Fields{"path": c.Request.URL.Path, "error": err}).Error("Request failed")
+	}
+	Write(c, e.Status, e.Problem(c))
+}
+
+// Write writes body, a Problem or a struct embedding one to add members to
+// it, as the response and aborts the request
+func Write(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// Abort records err and aborts the request, the middleware reports it
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Middleware reports the last error recorded by the handlers when they did
+// not write a response themselves
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Respond(c, c.Errors.Last().Err)
+	}
+}
+
+// NoRoute reports requests to routes that do not exist
+func NoRoute(c *gin.Context) {
+	Abort(c, NotFound("route_not_found", "No route matches "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+// Recovery reports the panics of the handlers as internal errors
+func Recovery(c *gin.Context, recovered interface{}) {
+	log.WithFields(logrus.Fields{"path": c.Request.URL.Path, "panic": recovered}).Error("Handler panicked")
+	Respond(c, Internal)
+}
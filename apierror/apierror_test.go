@@ -0,0 +1,147 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/repository"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	seatTaken := Conflict("seat_taken", "Seat is already booked")
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"Error", seatTaken, http.StatusConflict, "seat_taken"},
+		{"Wrapped Error", fmt.Errorf("booking: %w", seatTaken), http.StatusConflict, "seat_taken"},
+		{"Not Found", fmt.Errorf("movie 42: %w", repository.ErrNotFound), http.StatusNotFound, "not_found"},
+		{"Duplicate", repository.ErrDuplicate, http.StatusConflict, "duplicate"},
+		{"Invalid Reference", repository.ErrInvalidReference, http.StatusUnprocessableEntity, "invalid_reference"},
+		{"Concurrent Change", repository.ErrConflict, http.StatusConflict, "conflict"},
+		{"Invalid Cursor", repository.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+		{"Other Error", errors.New(`pq: relation "movies" does not exist`), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := From(tt.err)
+			assert.Equal(t, tt.status, e.Status)
+			assert.Equal(t, tt.code, e.Code)
+		})
+	}
+}
+
+func TestIs(t *testing.T) {
+	seatTaken := Conflict("seat_taken", "Seat is already booked")
+
+	assert.ErrorIs(t, seatTaken.WithDetail("A1: Seat is already booked"), seatTaken)
+	assert.ErrorIs(t, seatTaken.Wrap(repository.ErrDuplicate), seatTaken)
+	assert.ErrorIs(t, seatTaken.Wrap(repository.ErrDuplicate), repository.ErrDuplicate)
+	assert.NotErrorIs(t, Conflict("seat_held", "Seat is held"), seatTaken)
+}
+
+func setupRouter(handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.CustomRecovery(Recovery), Middleware())
+	r.GET("/resource", handler)
+	r.NoRoute(NoRoute)
+	return r
+}
+
+func serve(r *gin.Engine, path string) (*httptest.ResponseRecorder, Problem) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	r.ServeHTTP(w, req)
+
+	var problem Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &problem)
+	return w, problem
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Domain Error", func(t *testing.T) {
+		r := setupRouter(func(c *gin.Context) {
+			Abort(c, NotFound("movie_not_found", "Movie not found"))
+		})
+
+		w, problem := serve(r, "/resource")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+		assert.Equal(t, Problem{
+			Type:     "about:blank",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   "Movie not found",
+			Instance: "/resource",
+			Code:     "movie_not_found",
+		}, problem)
+	})
+
+	t.Run("Database Error", func(t *testing.T) {
+		r := setupRouter(func(c *gin.Context) {
+			Abort(c, errors.New(`pq: duplicate key value violates unique constraint "bookings_seat_key"`))
+		})
+
+		w, problem := serve(r, "/resource")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "internal_error", problem.Code)
+		assert.NotContains(t, w.Body.String(), "pq:")
+		assert.NotContains(t, w.Body.String(), "bookings_seat_key")
+	})
+
+	t.Run("Cause Of Domain Error", func(t *testing.T) {
+		r := setupRouter(func(c *gin.Context) {
+			Abort(c, Conflict("seat_taken", "Seat is already booked").Wrap(errors.New("pq: unique violation")))
+		})
+
+		w, problem := serve(r, "/resource")
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "Seat is already booked", problem.Detail)
+		assert.NotContains(t, w.Body.String(), "pq:")
+	})
+
+	t.Run("Response Written", func(t *testing.T) {
+		r := setupRouter(func(c *gin.Context) {
+			_ = c.Error(errors.New("logged only"))
+			c.JSON(http.StatusOK, gin.H{"status": "success"})
+		})
+
+		w, _ := serve(r, "/resource")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status": "success"}`, w.Body.String())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		r := setupRouter(func(c *gin.Context) {
+			panic("nil map")
+		})
+
+		w, problem := serve(r, "/resource")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "internal_error", problem.Code)
+		assert.NotContains(t, w.Body.String(), "nil map")
+	})
+
+	t.Run("Unknown Route", func(t *testing.T) {
+		r := setupRouter(func(c *gin.Context) {})
+
+		w, problem := serve(r, "/unknown")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "route_not_found", problem.Code)
+	})
+}
@@ -1,69 +1,114 @@
 package auth
 
 import (
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
-	"one-way-ticket/db"
-	"one-way-ticket/dynamo"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth/password"
+	"one-way-ticket/config"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/sessions"
 	"time"
 )
 
-// Handler struct to handle login requests and interact with DynamoDB
+var (
+	errInvalidCredentials  = apierror.Unauthorized("invalid_credentials", "Invalid username or password")
+	errInvalidRefreshToken = apierror.Unauthorized("invalid_refresh_token", "Invalid or expired refresh token")
+)
+
+// Tokens are returned by a login and by a refresh: the access token and the
+// refresh token exchanging it for a new pair once it expired
+type Tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Handler struct to handle login requests and interact with the session store
 type Handler struct {
-	ddb dynamodbiface.DynamoDBAPI
+	store sessions.SessionStore
+	users repository.UserRepository
+	cfg   config.AuthConfig
 }
 
-// NewHandler creates a new Handler with the provided DynamoDB client
-func NewHandler(ddb dynamodbiface.DynamoDBAPI) *Handler {
-	return &Handler{ddb: ddb}
+// NewHandler creates a new Handler with the provided session store, user
+// repository and token settings
+func NewHandler(store sessions.SessionStore, users repository.UserRepository, cfg config.AuthConfig) *Handler {
+	return &Handler{store: store, users: users, cfg: cfg}
 }
 
 func (h *Handler) Login(c *gin.Context) {
 	username := c.PostForm("username")
-	password := c.PostForm("password")
+	plain := c.PostForm("password")
 
 	// perform authentication here
-	if !(username == "admin" && password == "password") {
-		var user models.User
-		err := db.Dbx.Get(&user, "SELECT * FROM users WHERE username=$1", username)
+	user, err := h.users.GetByUsername(username)
+	if err != nil {
+		log.Println(err.Error())
+		apierror.Respond(c, errInvalidCredentials)
+		return
+	}
+
+	match, needsRehash, err := password.Verify(plain, user.Password)
+	if err != nil || !match {
 		if err != nil {
 			log.Println(err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"status": "unauthorized"})
-			return
 		}
+		apierror.Respond(c, errInvalidCredentials)
+		return
 	}
 
-	// set TTL for session
-	ttl := time.Now().Add(15 * time.Minute).Unix()
+	// upgrade the stored hash when the hashing parameters have changed
+	if needsRehash {
+		h.rehashPassword(user.ID, plain)
+	}
 
-	// set claims
-	claims := &models.Claims{
-		Username: username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: ttl,
-		},
+	sessionID, err := randomToken(16)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
 	}
 
-	// generate a JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	refreshToken, refreshHash, err := newRefreshToken(sessionID)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 
 	// generate encoded token and send it as a response
-	t, err := token.SignedString([]byte("secret"))
+	t, err := h.signAccessToken(user, sessionID)
 	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		apierror.Respond(c, err)
 		return
 	}
 
-	err = dynamo.CreateSession(h.ddb, t, ttl)
+	// the session lives as long as its refresh tokens
+	err = h.store.Create(models.Session{
+		Token:        sessionID,
+		UserID:       user.ID,
+		RefreshToken: refreshHash,
+		TTL:          time.Now().Add(h.cfg.RefreshTTL).Unix(),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
-		log.Println(err)
+		apierror.Respond(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": t})
+	c.JSON(http.StatusOK, Tokens{Token: t, RefreshToken: refreshToken})
+}
+
+// rehashPassword stores a hash produced with the current parameters. Failures
+// are only logged because the user has already been authenticated.
+func (h *Handler) rehashPassword(userID uint, plain string) {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	err = h.users.UpdatePassword(userID, hash)
+	if err != nil {
+		log.Println(err.Error())
+	}
 }
@@ -2,25 +2,59 @@ package auth
 
 import (
 	"encoding/json"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
-	"one-way-ticket/dynamo"
-	"one-way-ticket/mocks"
+	"one-way-ticket/auth/password"
+	"one-way-ticket/config"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/sessions"
+	"os"
 	"strings"
 	"testing"
 	"time"
 )
 
+var testConfig = config.AuthConfig{
+	SigningKey: "test-signing-key-with-32-characters",
+	TokenTTL:   15 * time.Minute,
+	RefreshTTL: 24 * time.Hour,
+}
+
+func TestMain(m *testing.M) {
+	// the lowest bcrypt cost keeps the tests fast
+	params := password.DefaultParams
+	params.BcryptCost = 4
+	hasher, err := password.NewHasher(params)
+	if err != nil {
+		panic(err)
+	}
+	password.SetDefault(hasher)
+	os.Exit(m.Run())
+}
+
+// newUsers returns a user repository holding one user with the given password
+func newUsers(t *testing.T, username, plain string, role models.Role) (repository.UserRepository, models.User) {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	users := memory.NewStore().Users()
+	user := models.User{Username: username, Password: hash, Email: username + "@example.com", Role: role}
+	err = users.Create(&user)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return users, user
+}
+
 func TestLoginUnauthorizedUser(t *testing.T) {
 	router := gin.Default()
-	// Create a new Handler with the mock client
-	handler := NewHandler(&mocks.MockDynamoDBClient{})
+	handler := NewHandler(sessions.NewMemoryStore(), memory.NewStore().Users(), testConfig)
 	router.POST("/login", handler.Login)
 
 	w := httptest.NewRecorder()
@@ -30,23 +64,33 @@ func TestLoginUnauthorizedUser(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
-	assert.Contains(t, w.Body.String(), "{\"status\":\"unauthorized\"}")
+	assert.Contains(t, w.Body.String(), `"code":"invalid_credentials"`)
 }
 
-func TestLoginAdminUser(t *testing.T) {
-	// Create a new Handler with the mock client
-	mockSvc := new(mocks.MockDynamoDBClient)
-	mockSvc.On("PutItem", mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
-		return input.TableName != nil && *input.TableName == dynamo.TableName &&
-			input.Item["token"].S != nil && len(*input.Item["token"].S) > 0
-	})).Return(&dynamodb.PutItemOutput{}, nil)
+func TestLoginWrongPassword(t *testing.T) {
+	users, _ := newUsers(t, "wrongpassword", "correct-password", models.RoleCustomer)
 
-	// Create a new Handler with the mock client
-	handler := NewHandler(mockSvc)
 	router := gin.Default()
+	handler := NewHandler(sessions.NewMemoryStore(), users, testConfig)
 	router.POST("/login", handler.Login)
 
-	username := "admin"
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader("username=wrongpassword&password=incorrect"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLoginValidUser(t *testing.T) {
+	username := "loginuser"
+	users, _ := newUsers(t, username, "password", models.RoleStaff)
+
+	store := sessions.NewMemoryStore()
+	handler := NewHandler(store, users, testConfig)
+	router := gin.Default()
+	router.POST("/login", handler.Login)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/login", strings.NewReader("username="+username+"&password=password"))
@@ -66,11 +110,50 @@ func TestLoginAdminUser(t *testing.T) {
 
 	claims := &models.Claims{}
 	parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
+		return []byte(testConfig.SigningKey), nil
 	})
 
 	assert.NoError(t, err)
 	assert.True(t, parsedToken.Valid)
 	assert.Equal(t, username, claims.Username)
+	assert.Equal(t, models.RoleStaff, claims.Role)
 	assert.WithinDuration(t, time.Now().Add(time.Minute*15), time.Unix(claims.ExpiresAt, 0), 5*time.Second)
+
+	// the token is bound to a new session
+	sess, err := store.Get(claims.Id)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+	assert.Equal(t, claims.UserID, sess.UserID)
+	assert.WithinDuration(t, time.Now().Add(testConfig.RefreshTTL), time.Unix(sess.TTL, 0), 5*time.Second)
+}
+
+func TestLoginRehashesOutdatedPassword(t *testing.T) {
+	params := password.DefaultParams
+	params.BcryptCost = 5
+	outdated, err := password.NewHasher(params)
+	assert.NoError(t, err)
+	hash, err := outdated.Hash("password")
+	assert.NoError(t, err)
+
+	users := memory.NewStore().Users()
+	user := models.User{Username: "rehashuser", Password: hash, Email: "rehash@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, users.Create(&user))
+
+	router := gin.Default()
+	router.POST("/login", NewHandler(sessions.NewMemoryStore(), users, testConfig).Login)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader("username=rehashuser&password=password"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := users.Get(int(user.ID))
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, stored.Password)
+	match, needsRehash, err := password.Verify("password", stored.Password)
+	assert.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
 }
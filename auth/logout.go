@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/sessions"
+)
+
+// Logout revokes the session of the access token
+func (h *Handler) Logout(c *gin.Context) {
+	claims := c.MustGet(ClaimsKey).(*models.Claims)
+
+	err := h.store.Delete(claims.Id)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+// LogoutAll revokes every session of the user, on every device
+func (h *Handler) LogoutAll(c *gin.Context) {
+	err := sessions.DeleteAllForUser(h.store, CurrentUserID(c))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
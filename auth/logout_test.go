@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/models"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/sessions"
+	"testing"
+	"time"
+)
+
+func setupLogoutRouter(handler *Handler) *gin.Engine {
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		claims := &models.Claims{UserID: 7, Username: "test", Role: models.RoleCustomer}
+		claims.Id = "current-session"
+		c.Set(ClaimsKey, claims)
+		c.Set(UserIDKey, claims.UserID)
+		c.Next()
+	})
+	router.POST("/logout", handler.Logout)
+	router.POST("/logout/all", handler.LogoutAll)
+	return router
+}
+
+func newStoreWithUserSessions(t *testing.T) *sessions.MemoryStore {
+	store := sessions.NewMemoryStore()
+	ttl := time.Now().Add(time.Hour).Unix()
+	for _, sess := range []models.Session{
+		{Token: "current-session", UserID: 7, TTL: ttl},
+		{Token: "other-device", UserID: 7, TTL: ttl},
+		{Token: "other-user", UserID: 8, TTL: ttl},
+	} {
+		err := store.Create(sess)
+		assert.NoError(t, err)
+	}
+	return store
+}
+
+func TestLogout(t *testing.T) {
+	store := newStoreWithUserSessions(t)
+
+	router := setupLogoutRouter(NewHandler(store, memory.NewStore().Users(), testConfig))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	remaining, err := store.ListByUser(7)
+	assert.NoError(t, err)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, "other-device", remaining[0].Token)
+}
+
+func TestLogoutAll(t *testing.T) {
+	store := newStoreWithUserSessions(t)
+
+	router := setupLogoutRouter(NewHandler(store, memory.NewStore().Users(), testConfig))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout/all", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	remaining, err := store.ListByUser(7)
+	assert.NoError(t, err)
+	assert.Empty(t, remaining)
+
+	other, err := store.ListByUser(8)
+	assert.NoError(t, err)
+	assert.Len(t, other, 1)
+}
@@ -1,41 +1,133 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"one-way-ticket/dynamo"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"strings"
+	"time"
+)
+
+// gin context keys set by AuthenticateMiddleware for the handlers
+const (
+	ClaimsKey   = "claims"
+	UserIDKey   = "user_id"
+	UsernameKey = "username"
+	RoleKey     = "role"
+)
+
+const (
+	MissingToken       = "Missing or malformed bearer token"
+	InvalidToken       = "Invalid or expired token"
+	InvalidSession     = "Session expired or revoked"
+	SessionUnavailable = "Session store unavailable, please retry later"
+)
+
+var (
+	errMissingToken       = apierror.Unauthorized("missing_token", MissingToken)
+	errInvalidToken       = apierror.Unauthorized("invalid_token", InvalidToken)
+	errInvalidSession     = apierror.Unauthorized("invalid_session", InvalidSession)
+	errSessionUnavailable = apierror.New(http.StatusServiceUnavailable, "session_unavailable", SessionUnavailable)
 )
 
 func (h *Handler) AuthenticateMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
+		tokenString, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			unauthorized(c, errMissingToken)
+			return
+		}
 
 		// parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return []byte("secret"), nil
-		})
+		claims := &models.Claims{}
+		token, err := h.parseToken(tokenString, claims)
 		if err != nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			unauthorized(c, errInvalidToken)
 			return
 		}
 
 		//verify the token
-		if _, ok := token.Claims.(jwt.MapClaims); !ok || !token.Valid {
-			c.AbortWithStatus(http.StatusUnauthorized)
+		if !token.Valid || claims.Id == "" {
+			unauthorized(c, errInvalidToken)
 			return
 		}
 
-		_, err = dynamo.GetSessionForUser(h.ddb, tokenString)
+		// the token must belong to a live session: logging out deletes the
+		// session before the token expires, and stores such as DynamoDB only
+		// delete expired items lazily so the TTL is checked as well
+		sess, err := h.store.Get(claims.Id)
 		if err != nil {
-			c.AbortWithStatus(http.StatusInternalServerError)
+			apierror.Respond(c, errSessionUnavailable.Wrap(err))
+			return
+		}
+		if sess == nil || sess.TTL < time.Now().Unix() || sess.UserID != claims.UserID {
+			unauthorized(c, errInvalidSession)
 			return
 		}
 
+		c.Set(ClaimsKey, claims)
+		c.Set(UserIDKey, claims.UserID)
+		c.Set(UsernameKey, claims.Username)
+		c.Set(RoleKey, claims.Role)
 		c.Next()
 	}
 }
+
+// bearerToken extracts the token of an "Authorization: Bearer <token>" header
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// unauthorized rejects the request, the middlewares respond themselves as
+// they run before the handlers
+func unauthorized(c *gin.Context, err *apierror.Error) {
+	c.Header("WWW-Authenticate", `Bearer realm="one-way-ticket"`)
+	apierror.Respond(c, err)
+}
+
+// parseToken verifies the token with the signing key and then with each of
+// the verification keys, so that tokens signed before a key rotation remain valid
+func (h *Handler) parseToken(tokenString string, claims *models.Claims) (*jwt.Token, error) {
+	var token *jwt.Token
+	var err error
+	for _, key := range append([]string{h.cfg.SigningKey}, h.cfg.VerificationKeys...) {
+		token, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method")
+			}
+			return []byte(key), nil
+		})
+
+		var validationErr *jwt.ValidationError
+		if err == nil || !errors.As(err, &validationErr) || validationErr.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
+		}
+	}
+	return token, err
+}
+
+// CurrentUserID returns the ID of the authenticated user
+func CurrentUserID(c *gin.Context) uint {
+	return c.GetUint(UserIDKey)
+}
+
+// CurrentUsername returns the username of the authenticated user
+func CurrentUsername(c *gin.Context) string {
+	return c.GetString(UsernameKey)
+}
+
+// CurrentRole returns the role of the authenticated user
+func CurrentRole(c *gin.Context) models.Role {
+	role, _ := c.Get(RoleKey)
+	r, _ := role.(models.Role)
+	return r
+}
@@ -1,72 +1,164 @@
 package auth
 
 import (
-	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"errors"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
-	"one-way-ticket/dynamo"
-	"one-way-ticket/mocks"
+	"one-way-ticket/models"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/sessions"
 	"testing"
 	"time"
 )
 
-func generateTestToken(secret string, expirationTime time.Duration) (string, error) {
-	claims := &jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(expirationTime).Unix(),
+func generateTestToken(secret string, sessionID string, expirationTime time.Duration) (string, error) {
+	claims := &models.Claims{
+		UserID:   7,
+		Username: "test",
+		Role:     models.RoleCustomer,
+		StandardClaims: jwt.StandardClaims{
+			Id:        sessionID,
+			ExpiresAt: time.Now().Add(expirationTime).Unix(),
+		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
 
+// unavailableStore fails every call, like a session store during an outage
+type unavailableStore struct {
+	sessions.SessionStore
+}
+
+func (unavailableStore) Get(string) (*models.Session, error) {
+	return nil, errors.New("connection refused")
+}
+
 func TestAuthenticateMiddleware(t *testing.T) {
-	// Create a new Handler with the mock client
-	mockSvc := new(mocks.MockDynamoDBClient)
-	mockSvc.On("GetItem", mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
-		return input.TableName != nil && *input.TableName == dynamo.TableName &&
-			input.Key["token"].S != nil && len(*input.Key["token"].S) > 0
-	})).Return(&dynamodb.GetItemOutput{}, nil)
-
-	handler := NewHandler(mockSvc)
+	store := sessions.NewMemoryStore()
+	for _, sess := range []models.Session{
+		{Token: "test-session", UserID: 7, TTL: time.Now().Add(time.Hour).Unix()},
+		{Token: "expired-session", UserID: 7, TTL: time.Now().Add(-time.Minute).Unix()},
+		{Token: "other-user-session", UserID: 8, TTL: time.Now().Add(time.Hour).Unix()},
+	} {
+		err := store.Create(sess)
+		assert.NoError(t, err)
+	}
+
+	cfg := testConfig
+	cfg.VerificationKeys = []string{"previous-signing-key-with-32-characters"}
+	handler := NewHandler(store, memory.NewStore().Users(), cfg)
 	router := gin.Default()
 	router.Use(handler.AuthenticateMiddleware())
 	router.GET("/users", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":  CurrentUserID(c),
+			"username": CurrentUsername(c),
+			"role":     CurrentRole(c),
+		})
 	})
 
-	t.Run("Valid Token", func(t *testing.T) {
-		token, _ := generateTestToken("secret", time.Minute*5)
+	request := func(authorization string) *httptest.ResponseRecorder {
 		req, _ := http.NewRequest("GET", "/users", nil)
-		req.Header.Set("Authorization", token)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
 		w := httptest.NewRecorder()
-
 		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Valid Token", func(t *testing.T) {
+		token, _ := generateTestToken(testConfig.SigningKey, "test-session", time.Minute*5)
+		w := request("Bearer " + token)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.JSONEq(t, `{"status": "success"}`, w.Body.String())
+		assert.JSONEq(t, `{"user_id": 7, "username": "test", "role": "customer"}`, w.Body.String())
 	})
 
-	t.Run("Invalid Token", func(t *testing.T) {
-		req, _ := http.NewRequest("GET", "/users", nil)
-		req.Header.Set("Authorization", "invalid_token")
-		w := httptest.NewRecorder()
+	t.Run("Rotated Key", func(t *testing.T) {
+		token, _ := generateTestToken("previous-signing-key-with-32-characters", "test-session", time.Minute*5)
+		w := request("Bearer " + token)
 
-		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Unknown Key", func(t *testing.T) {
+		token, _ := generateTestToken("unknown-signing-key-with-32-characters", "test-session", time.Minute*5)
+		w := request("Bearer " + token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Missing Bearer Scheme", func(t *testing.T) {
+		token, _ := generateTestToken(testConfig.SigningKey, "test-session", time.Minute*5)
+		w := request(token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"missing_token"`)
+		assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Missing Header", func(t *testing.T) {
+		w := request("")
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 
+	t.Run("Invalid Token", func(t *testing.T) {
+		w := request("Bearer invalid_token")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_token"`)
+	})
+
 	t.Run("Expired Token", func(t *testing.T) {
-		token, _ := generateTestToken("secret", -time.Minute*5)
+		token, _ := generateTestToken(testConfig.SigningKey, "test-session", -time.Minute*5)
+		w := request("Bearer " + token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Revoked Session", func(t *testing.T) {
+		token, _ := generateTestToken(testConfig.SigningKey, "revoked-session", time.Minute*5)
+		w := request("Bearer " + token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_session"`)
+	})
+
+	t.Run("Expired Session", func(t *testing.T) {
+		token, _ := generateTestToken(testConfig.SigningKey, "expired-session", time.Minute*5)
+		w := request("Bearer " + token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_session"`)
+	})
+
+	t.Run("Session Of Another User", func(t *testing.T) {
+		token, _ := generateTestToken(testConfig.SigningKey, "other-user-session", time.Minute*5)
+		w := request("Bearer " + token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Session Store Unavailable", func(t *testing.T) {
+		router := gin.Default()
+		router.Use(NewHandler(unavailableStore{}, memory.NewStore().Users(), testConfig).AuthenticateMiddleware())
+		router.GET("/users", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "success"})
+		})
+
+		token, _ := generateTestToken(testConfig.SigningKey, "test-session", time.Minute*5)
 		req, _ := http.NewRequest("GET", "/users", nil)
-		req.Header.Set("Authorization", token)
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
-
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"session_unavailable"`)
 	})
 }
@@ -0,0 +1,190 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Algorithm string
+
+const (
+	Bcrypt   Algorithm = "bcrypt"
+	Argon2id Algorithm = "argon2id"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+	ErrInvalidHash      = errors.New("invalid password hash")
+	// ErrTooLong is returned by bcrypt for passwords longer than 72 bytes
+	ErrTooLong = bcrypt.ErrPasswordTooLong
+)
+
+// Params holds the tunables of the hashing algorithms. Only the fields of the
+// selected Algorithm are used for new hashes, but all of them are compared
+// when deciding whether an existing hash needs to be upgraded.
+type Params struct {
+	Algorithm     Algorithm
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // in KiB
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+}
+
+// DefaultParams follows the OWASP recommendations for both algorithms.
+var DefaultParams = Params{
+	Algorithm:     Bcrypt,
+	BcryptCost:    12,
+	Argon2Time:    2,
+	Argon2Memory:  19 * 1024,
+	Argon2Threads: 1,
+	Argon2KeyLen:  32,
+	Argon2SaltLen: 16,
+}
+
+// Hasher hashes and verifies passwords with a fixed set of parameters
+type Hasher struct {
+	params Params
+}
+
+// NewHasher validates the parameters and creates a new Hasher
+func NewHasher(params Params) (*Hasher, error) {
+	switch params.Algorithm {
+	case Bcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if params.Argon2Time == 0 || params.Argon2Memory == 0 || params.Argon2Threads == 0 {
+			return nil, errors.New("argon2id time, memory and threads must be positive")
+		}
+		if params.Argon2KeyLen < 16 || params.Argon2SaltLen < 8 {
+			return nil, errors.New("argon2id key length must be at least 16 and salt length at least 8")
+		}
+	default:
+		return nil, ErrUnknownAlgorithm
+	}
+	return &Hasher{params: params}, nil
+}
+
+// Hash returns the encoded hash of the plain text password
+func (h *Hasher) Hash(plain string) (string, error) {
+	switch h.params.Algorithm {
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(plain), h.params.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		return string(hash), nil
+	case Argon2id:
+		salt := make([]byte, h.params.Argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed to generate salt: %v", err)
+		}
+		key := argon2.IDKey([]byte(plain), salt, h.params.Argon2Time, h.params.Argon2Memory, h.params.Argon2Threads, h.params.Argon2KeyLen)
+		return encodeArgon2id(h.params, salt, key), nil
+	default:
+		return "", ErrUnknownAlgorithm
+	}
+}
+
+// Verify checks the plain text password against an encoded hash. needsRehash
+// is true when the password matches but the hash was produced with another
+// algorithm or other parameters than the ones of the Hasher.
+func (h *Hasher) Verify(plain, encoded string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+		}
+		return true, h.params.Algorithm != Bcrypt || cost != h.params.BcryptCost, nil
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		candidate := argon2.IDKey([]byte(plain), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, params.Argon2KeyLen)
+		if subtle.ConstantTimeCompare(key, candidate) != 1 {
+			return false, false, nil
+		}
+		return true, h.params.Algorithm != Argon2id ||
+			params.Argon2Time != h.params.Argon2Time ||
+			params.Argon2Memory != h.params.Argon2Memory ||
+			params.Argon2Threads != h.params.Argon2Threads ||
+			params.Argon2KeyLen != h.params.Argon2KeyLen ||
+			uint32(len(salt)) != h.params.Argon2SaltLen, nil
+	default:
+		return false, false, ErrInvalidHash
+	}
+}
+
+// encodeArgon2id uses the PHC string format, e.g.
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func encodeArgon2id(params Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Argon2Memory, params.Argon2Time, params.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(encoded string) (Params, []byte, []byte, error) {
+	params := Params{Algorithm: Argon2id}
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Time, &params.Argon2Threads); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	params.Argon2SaltLen = uint32(len(salt))
+	params.Argon2KeyLen = uint32(len(key))
+	return params, salt, key, nil
+}
+
+var defaultHasher, _ = NewHasher(DefaultParams)
+
+// SetDefault replaces the Hasher used by the package level Hash and Verify
+func SetDefault(h *Hasher) {
+	defaultHasher = h
+}
+
+// Hash hashes the password with the default Hasher
+func Hash(plain string) (string, error) {
+	return defaultHasher.Hash(plain)
+}
+
+// Verify verifies the password with the default Hasher
+func Verify(plain, encoded string) (match bool, needsRehash bool, err error) {
+	return defaultHasher.Verify(plain, encoded)
+}
@@ -0,0 +1,119 @@
+package password
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func newTestHasher(t *testing.T, params Params) *Hasher {
+	h, err := NewHasher(params)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	return h
+}
+
+func TestBcryptHashAndVerify(t *testing.T) {
+	params := DefaultParams
+	params.BcryptCost = 4
+	h := newTestHasher(t, params)
+
+	hash, err := h.Hash("secret-password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2a$04$"))
+
+	match, rehash, err := h.Verify("secret-password", hash)
+	assert.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, rehash)
+
+	match, _, err = h.Verify("wrong-password", hash)
+	assert.NoError(t, err)
+	assert.False(t, match)
+
+	_, err = h.Hash(strings.Repeat("a", 73))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	params := DefaultParams
+	params.Algorithm = Argon2id
+	params.Argon2Memory = 1024
+	h := newTestHasher(t, params)
+
+	hash, err := h.Hash("secret-password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$"))
+
+	match, rehash, err := h.Verify("secret-password", hash)
+	assert.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, rehash)
+
+	match, _, err = h.Verify("wrong-password", hash)
+	assert.NoError(t, err)
+	assert.False(t, match)
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	params := DefaultParams
+	params.BcryptCost = 4
+	old := newTestHasher(t, params)
+	hash, err := old.Hash("secret-password")
+	assert.NoError(t, err)
+
+	t.Run("Changed Cost", func(t *testing.T) {
+		params := DefaultParams
+		params.BcryptCost = 5
+		match, rehash, err := newTestHasher(t, params).Verify("secret-password", hash)
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, rehash)
+	})
+
+	t.Run("Changed Algorithm", func(t *testing.T) {
+		params := DefaultParams
+		params.Algorithm = Argon2id
+		params.Argon2Memory = 1024
+		match, rehash, err := newTestHasher(t, params).Verify("secret-password", hash)
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, rehash)
+	})
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		params := DefaultParams
+		params.BcryptCost = 5
+		match, rehash, err := newTestHasher(t, params).Verify("wrong-password", hash)
+		assert.NoError(t, err)
+		assert.False(t, match)
+		assert.False(t, rehash)
+	})
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	h := newTestHasher(t, DefaultParams)
+
+	_, _, err := h.Verify("password", "password")
+	assert.ErrorIs(t, err, ErrInvalidHash)
+
+	_, _, err = h.Verify("password", "$argon2id$v=19$broken")
+	assert.ErrorIs(t, err, ErrInvalidHash)
+}
+
+func TestNewHasherValidation(t *testing.T) {
+	_, err := NewHasher(Params{Algorithm: "md5"})
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+
+	params := DefaultParams
+	params.BcryptCost = 100
+	_, err = NewHasher(params)
+	assert.Error(t, err)
+
+	params = DefaultParams
+	params.Algorithm = Argon2id
+	params.Argon2Threads = 0
+	_, err = NewHasher(params)
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+)
+
+const (
+	Forbidden = "You do not have permission to perform this action"
+)
+
+var errForbidden = apierror.Forbidden("forbidden", Forbidden)
+
+type Permission string
+
+const (
+	ReadUsers      Permission = "users:read"
+	WriteUsers     Permission = "users:write"
+	ReadMovies     Permission = "movies:read"
+	WriteMovies    Permission = "movies:write"
+	ReadHalls      Permission = "halls:read"
+	WriteHalls     Permission = "halls:write"
+	ReadShowtimes  Permission = "showtimes:read"
+	WriteShowtimes Permission = "showtimes:write"
+	// ReadBookings and WriteBookings cover the bookings of the caller,
+	// ManageBookings covers the bookings of every user
+	ReadBookings   Permission = "bookings:read"
+	WriteBookings  Permission = "bookings:write"
+	ManageBookings Permission = "bookings:manage"
+	// CheckInTickets lets staff scan tickets at the entrance
+	CheckInTickets Permission = "tickets:checkin"
+)
+
+// rolePermissions is the access policy: the permissions granted to each role
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleAdmin: {
+		ReadUsers, WriteUsers,
+		ReadMovies, WriteMovies,
+		ReadHalls, WriteHalls,
+		ReadShowtimes, WriteShowtimes,
+		ReadBookings, WriteBookings, ManageBookings,
+		CheckInTickets,
+	},
+	models.RoleStaff: {
+		ReadUsers,
+		ReadMovies, WriteMovies,
+		ReadHalls, WriteHalls,
+		ReadShowtimes, WriteShowtimes,
+		ReadBookings, WriteBookings, ManageBookings,
+		CheckInTickets,
+	},
+	models.RoleCustomer: {
+		ReadMovies,
+		ReadHalls,
+		ReadShowtimes,
+		ReadBookings, WriteBookings,
+	},
+}
+
+// HasPermission reports whether the role is granted the permission
+func HasPermission(role models.Role, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ActsFor reports whether the authenticated user may act on the resources of
+// the user userID: their own ones, or those of every user when their role
+// manages bookings
+func ActsFor(c *gin.Context, userID int) bool {
+	return userID == int(CurrentUserID(c)) || HasPermission(CurrentRole(c), ManageBookings)
+}
+
+// Authorize returns a middleware that only lets through requests whose token
+// grants every one of the permissions. It must run after AuthenticateMiddleware.
+func Authorize(permissions ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := CurrentRole(c)
+		for _, permission := range permissions {
+			if !HasPermission(role, permission) {
+				apierror.Respond(c, errForbidden)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/models"
+	"testing"
+)
+
+func setupPolicyRouter(role models.Role) *gin.Engine {
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		if role != "" {
+			c.Set(RoleKey, role)
+		}
+		c.Next()
+	})
+	router.GET("/movies", Authorize(ReadMovies), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+	router.POST("/movies", Authorize(WriteMovies), func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"status": "success"})
+	})
+	return router
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   models.Role
+		method string
+		code   int
+	}{
+		{"Customer Reads Movies", models.RoleCustomer, "GET", http.StatusOK},
+		{"Customer Creates Movie", models.RoleCustomer, "POST", http.StatusForbidden},
+		{"Staff Creates Movie", models.RoleStaff, "POST", http.StatusCreated},
+		{"Admin Creates Movie", models.RoleAdmin, "POST", http.StatusCreated},
+		{"Unknown Role", models.Role("guest"), "GET", http.StatusForbidden},
+		{"Missing Claims", "", "GET", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupPolicyRouter(tt.role)
+			req, _ := http.NewRequest(tt.method, "/movies", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), `"code":"forbidden"`)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	assert.True(t, HasPermission(models.RoleAdmin, WriteUsers))
+	assert.False(t, HasPermission(models.RoleStaff, WriteUsers))
+	assert.True(t, HasPermission(models.RoleStaff, ManageBookings))
+	assert.True(t, HasPermission(models.RoleCustomer, WriteBookings))
+	assert.False(t, HasPermission(models.RoleCustomer, ManageBookings))
+	assert.False(t, HasPermission(models.RoleCustomer, WriteShowtimes))
+	assert.True(t, HasPermission(models.RoleStaff, CheckInTickets))
+	assert.False(t, HasPermission(models.RoleCustomer, CheckInTickets))
+}
+
+func TestActsFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   models.Role
+		userID int
+		acts   bool
+	}{
+		{"Customer For Self", models.RoleCustomer, 1, true},
+		{"Customer For Other", models.RoleCustomer, 2, false},
+		{"Staff For Other", models.RoleStaff, 2, true},
+		{"Admin For Other", models.RoleAdmin, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set(UserIDKey, uint(1))
+			c.Set(RoleKey, tt.role)
+
+			assert.Equal(t, tt.acts, ActsFor(c, tt.userID))
+		})
+	}
+}
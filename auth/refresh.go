@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/sessions"
+	"time"
+)
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token can be used once: presenting a token that has
+// already been rotated revokes the whole session, since either the client or
+// an attacker holds a stolen copy.
+func (h *Handler) Refresh(c *gin.Context) {
+	sessionID, secret, ok := splitRefreshToken(c.PostForm("refresh_token"))
+	if !ok {
+		apierror.Respond(c, errInvalidRefreshToken)
+		return
+	}
+
+	sess, err := h.store.Get(sessionID)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if sess == nil || sess.TTL < time.Now().Unix() {
+		apierror.Respond(c, errInvalidRefreshToken)
+		return
+	}
+
+	oldHash := hashRefreshToken(secret)
+	if subtle.ConstantTimeCompare([]byte(oldHash), []byte(sess.RefreshToken)) != 1 {
+		h.revokeSession(sess, "refresh token reuse detected")
+		apierror.Respond(c, errInvalidRefreshToken)
+		return
+	}
+
+	// reload the user so that role changes apply and deleted users are locked out
+	user, err := h.users.Get(int(sess.UserID))
+	if err != nil {
+		h.revokeSession(sess, err.Error())
+		apierror.Respond(c, errInvalidRefreshToken)
+		return
+	}
+
+	refreshToken, newHash, err := newRefreshToken(sessionID)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	err = h.store.RotateRefreshToken(sessionID, oldHash, newHash, time.Now().Add(h.cfg.RefreshTTL).Unix())
+	if errors.Is(err, sessions.ErrRefreshTokenReused) {
+		h.revokeSession(sess, "concurrent refresh token reuse detected")
+		apierror.Respond(c, errInvalidRefreshToken)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	t, err := h.signAccessToken(user, sessionID)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Tokens{Token: t, RefreshToken: refreshToken})
+}
+
+func (h *Handler) revokeSession(sess *models.Session, reason string) {
+	log.Printf("Revoking session of user %d: %s", sess.UserID, reason)
+	err := h.store.Delete(sess.Token)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/models"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/sessions"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStoreWithSession(t *testing.T, sess models.Session) *sessions.MemoryStore {
+	store := sessions.NewMemoryStore()
+	err := store.Create(sess)
+	assert.NoError(t, err)
+	return store
+}
+
+func postRefresh(handler *Handler, refreshToken string) *httptest.ResponseRecorder {
+	router := gin.Default()
+	router.POST("/token/refresh", handler.Refresh)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/token/refresh", strings.NewReader("refresh_token="+refreshToken))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshMalformedToken(t *testing.T) {
+	w := postRefresh(NewHandler(sessions.NewMemoryStore(), memory.NewStore().Users(), testConfig), "malformed")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRefreshExpiredSession(t *testing.T) {
+	store := newStoreWithSession(t, models.Session{
+		Token:        "expired-session",
+		UserID:       1,
+		RefreshToken: hashRefreshToken("secret"),
+		TTL:          time.Now().Add(-time.Minute).Unix(),
+	})
+
+	w := postRefresh(NewHandler(store, memory.NewStore().Users(), testConfig), "expired-session.secret")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	store := newStoreWithSession(t, models.Session{
+		Token:        "reused-session",
+		UserID:       1,
+		RefreshToken: hashRefreshToken("current-secret"),
+		TTL:          time.Now().Add(time.Hour).Unix(),
+	})
+
+	w := postRefresh(NewHandler(store, memory.NewStore().Users(), testConfig), "reused-session.rotated-secret")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// presenting an old refresh token revokes the session
+	sess, err := store.Get("reused-session")
+	assert.NoError(t, err)
+	assert.Nil(t, sess)
+}
+
+func TestRefreshValidToken(t *testing.T) {
+	users, user := newUsers(t, "refreshuser", "password", models.RoleCustomer)
+
+	store := newStoreWithSession(t, models.Session{
+		Token:        "valid-session",
+		UserID:       user.ID,
+		RefreshToken: hashRefreshToken("current-secret"),
+		TTL:          time.Now().Add(time.Hour).Unix(),
+	})
+	handler := NewHandler(store, users, testConfig)
+
+	w := postRefresh(handler, "valid-session.current-secret")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response["token"])
+	assert.True(t, strings.HasPrefix(response["refresh_token"], "valid-session."))
+	assert.NotEqual(t, "valid-session.current-secret", response["refresh_token"])
+
+	// the rotated token can no longer be used
+	w = postRefresh(handler, "valid-session.current-secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
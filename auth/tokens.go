@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/dgrijalva/jwt-go"
+	"one-way-ticket/models"
+	"strings"
+	"time"
+)
+
+// randomToken returns n random bytes encoded as URL safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken returns the digest stored in the session, so that a leak
+// of the sessions table does not leak usable refresh tokens
+func hashRefreshToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken returns a refresh token of the form <session ID>.<secret>
+// together with the hash of its secret
+func newRefreshToken(sessionID string) (token string, hash string, err error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return sessionID + "." + secret, hashRefreshToken(secret), nil
+}
+
+// splitRefreshToken returns the session ID and the secret of a refresh token
+func splitRefreshToken(token string) (sessionID string, secret string, ok bool) {
+	sessionID, secret, ok = strings.Cut(token, ".")
+	return sessionID, secret, ok && sessionID != "" && secret != ""
+}
+
+// signAccessToken issues a short-lived JWT bound to the session through the jti claim
+func (h *Handler) signAccessToken(user models.User, sessionID string) (string, error) {
+	claims := &models.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		StandardClaims: jwt.StandardClaims{
+			Id:        sessionID,
+			ExpiresAt: time.Now().Add(h.cfg.TokenTTL).Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.cfg.SigningKey))
+}
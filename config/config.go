@@ -0,0 +1,553 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"one-way-ticket/auth/password"
+	"one-way-ticket/pricing"
+)
+
+// minSigningKeyLength is the minimum length of the HMAC keys, in bytes
+const minSigningKeyLength = 32
+
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Password     PasswordConfig     `yaml:"password"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Dynamo       DynamoConfig       `yaml:"dynamo"`
+	Sessions     SessionsConfig     `yaml:"sessions"`
+	Holds        HoldsConfig        `yaml:"holds"`
+	Pricing      PricingConfig      `yaml:"pricing"`
+	Payments     PaymentsConfig     `yaml:"payments"`
+	Cancellation CancellationConfig `yaml:"cancellation"`
+	Tickets      TicketsConfig      `yaml:"tickets"`
+	Bootstrap    BootstrapConfig    `yaml:"bootstrap"`
+}
+
+type ServerConfig struct {
+	Address string `yaml:"address"`
+}
+
+type AuthConfig struct {
+	// SigningKey signs new tokens, VerificationKeys are previous signing keys
+	// that are still accepted so that keys can be rotated without logging
+	// everybody out
+	SigningKey       string        `yaml:"signing_key"`
+	VerificationKeys []string      `yaml:"verification_keys"`
+	TokenTTL         time.Duration `yaml:"token_ttl"`
+	// RefreshTTL is the lifetime of a session and of its refresh tokens
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+type PasswordConfig struct {
+	Algorithm     string `yaml:"algorithm"`
+	BcryptCost    int    `yaml:"bcrypt_cost"`
+	Argon2Time    uint32 `yaml:"argon2_time"`
+	Argon2Memory  uint32 `yaml:"argon2_memory"`
+	Argon2Threads uint8  `yaml:"argon2_threads"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// AutoMigrate applies the pending schema migrations on startup
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type DynamoConfig struct {
+	// Endpoint overrides the AWS endpoint, e.g. to point at LocalStack
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// Session store backends
+const (
+	SessionStoreDynamoDB = "dynamodb"
+	SessionStoreMemory   = "memory"
+	SessionStorePostgres = "postgres"
+	SessionStoreRedis    = "redis"
+)
+
+type SessionsConfig struct {
+	// Store selects the session backend, the in-memory store is only meant
+	// for tests and single instance development setups
+	Store         string        `yaml:"store"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+	Redis         RedisConfig   `yaml:"redis"`
+}
+
+type RedisConfig struct {
+	Address  string `yaml:"address"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type HoldsConfig struct {
+	// Duration is how long a hold reserves its seats before it must be
+	// confirmed, expired holds are deleted every ReapInterval
+	Duration     time.Duration `yaml:"duration"`
+	ReapInterval time.Duration `yaml:"reap_interval"`
+}
+
+// PricingConfig sets the prices of the tickets, see pricing.Rules. The ticket
+// types and seat categories of the file are added to the default ones.
+type PricingConfig struct {
+	Currency              string         `yaml:"currency"`
+	BasePrice             int            `yaml:"base_price"`
+	TicketTypes           map[string]int `yaml:"ticket_types"`
+	Categories            map[string]int `yaml:"categories"`
+	MatineeBefore         string         `yaml:"matinee_before"`
+	MatineeDiscount       int            `yaml:"matinee_discount"`
+	WeekendSurcharge      int            `yaml:"weekend_surcharge"`
+	OpeningNightSurcharge int            `yaml:"opening_night_surcharge"`
+}
+
+// CancellationConfig is the refund policy of cancelled bookings, see
+// pricing.RefundPolicy
+type CancellationConfig struct {
+	FullRefundBefore time.Duration `yaml:"full_refund_before"`
+	// PartialRefund is the percentage of the price refunded after
+	// FullRefundBefore, until the showtime starts
+	PartialRefund int `yaml:"partial_refund"`
+}
+
+type TicketsConfig struct {
+	// SigningKey signs the tokens of the e-tickets, the JWT signing key is
+	// used when it is empty
+	SigningKey string `yaml:"signing_key"`
+	// Tickets are checked in from CheckInOpensBefore the start of their
+	// showtime until CheckInClosesAfter it
+	CheckInOpensBefore time.Duration `yaml:"checkin_opens_before"`
+	CheckInClosesAfter time.Duration `yaml:"checkin_closes_after"`
+}
+
+// Payment providers
+const (
+	PaymentProviderFake = "fake"
+)
+
+type PaymentsConfig struct {
+	// Provider selects the payment provider, the fake provider takes no money
+	// and is meant for tests and development
+	Provider string `yaml:"provider"`
+	// WebhookSecret signs the webhooks of the provider, webhooks are refused
+	// while it is empty
+	WebhookSecret string             `yaml:"webhook_secret"`
+	Fake          FakePaymentsConfig `yaml:"fake"`
+}
+
+type FakePaymentsConfig struct {
+	// FailureRate is the share of payments that are declined, from 0 to 1
+	FailureRate float64 `yaml:"failure_rate"`
+	// Latency delays every call to the provider
+	Latency time.Duration `yaml:"latency"`
+}
+
+// BootstrapConfig describes the admin account created on startup when the
+// database does not contain any admin yet
+type BootstrapConfig struct {
+	AdminUsername string `yaml:"admin_username"`
+	AdminPassword string `yaml:"admin_password"`
+	AdminEmail    string `yaml:"admin_email"`
+}
+
+// Default returns the configuration used for every value that is set
+// neither in the configuration file nor in the environment
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address: ":8080",
+		},
+		Auth: AuthConfig{
+			TokenTTL:   15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Password: PasswordConfig{
+			Algorithm:     string(password.DefaultParams.Algorithm),
+			BcryptCost:    password.DefaultParams.BcryptCost,
+			Argon2Time:    password.DefaultParams.Argon2Time,
+			Argon2Memory:  password.DefaultParams.Argon2Memory,
+			Argon2Threads: password.DefaultParams.Argon2Threads,
+		},
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		Dynamo: DynamoConfig{
+			Region: "us-east-1",
+		},
+		Sessions: SessionsConfig{
+			Store:         SessionStoreDynamoDB,
+			PurgeInterval: 10 * time.Minute,
+			Redis: RedisConfig{
+				Address: "localhost:6379",
+			},
+		},
+		Holds: HoldsConfig{
+			Duration:     10 * time.Minute,
+			ReapInterval: time.Minute,
+		},
+		Payments: PaymentsConfig{
+			Provider: PaymentProviderFake,
+		},
+		Cancellation: CancellationConfig{
+			FullRefundBefore: pricing.DefaultRefundPolicy.FullRefundBefore,
+			PartialRefund:    pricing.DefaultRefundPolicy.PartialRefund,
+		},
+		Tickets: TicketsConfig{
+			CheckInOpensBefore: time.Hour,
+			CheckInClosesAfter: 30 * time.Minute,
+		},
+		Pricing: PricingConfig{
+			Currency:              pricing.DefaultRules.Currency,
+			BasePrice:             pricing.DefaultRules.BasePrice,
+			TicketTypes:           copyPercents(pricing.DefaultRules.TicketTypes),
+			Categories:            copyPercents(pricing.DefaultRules.Categories),
+			MatineeBefore:         pricing.DefaultRules.MatineeBefore,
+			MatineeDiscount:       pricing.DefaultRules.MatineeDiscount,
+			WeekendSurcharge:      pricing.DefaultRules.WeekendSurcharge,
+			OpeningNightSurcharge: pricing.DefaultRules.OpeningNightSurcharge,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional YAML file at
+// path and the environment, in increasing order of precedence, and validates it
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %v", err)
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadEnv() error {
+	lookupString("LISTEN_ADDR", &cfg.Server.Address)
+
+	lookupString("JWT_SIGNING_KEY", &cfg.Auth.SigningKey)
+	if keys, ok := os.LookupEnv("JWT_VERIFICATION_KEYS"); ok {
+		cfg.Auth.VerificationKeys = splitList(keys)
+	}
+
+	lookupString("PASSWORD_ALGORITHM", &cfg.Password.Algorithm)
+
+	lookupString("DB_HOST", &cfg.Database.Host)
+	lookupString("DB_NAME", &cfg.Database.Name)
+	lookupString("DB_USER", &cfg.Database.User)
+	lookupString("DB_PASSWORD", &cfg.Database.Password)
+	lookupString("DB_SSL", &cfg.Database.SSLMode)
+
+	lookupString("DYNAMO_ENDPOINT", &cfg.Dynamo.Endpoint)
+	lookupString("AWS_REGION", &cfg.Dynamo.Region)
+	lookupString("AWS_ACCESS_KEY_ID", &cfg.Dynamo.AccessKeyID)
+	lookupString("AWS_SECRET_ACCESS_KEY", &cfg.Dynamo.SecretAccessKey)
+
+	lookupString("SESSION_STORE", &cfg.Sessions.Store)
+	lookupString("REDIS_ADDR", &cfg.Sessions.Redis.Address)
+	lookupString("REDIS_PASSWORD", &cfg.Sessions.Redis.Password)
+
+	lookupString("PRICE_CURRENCY", &cfg.Pricing.Currency)
+
+	lookupString("TICKET_SIGNING_KEY", &cfg.Tickets.SigningKey)
+	lookupString("PAYMENT_PROVIDER", &cfg.Payments.Provider)
+	lookupString("PAYMENT_WEBHOOK_SECRET", &cfg.Payments.WebhookSecret)
+
+	lookupString("BOOTSTRAP_ADMIN_USERNAME", &cfg.Bootstrap.AdminUsername)
+	lookupString("BOOTSTRAP_ADMIN_PASSWORD", &cfg.Bootstrap.AdminPassword)
+	lookupString("BOOTSTRAP_ADMIN_EMAIL", &cfg.Bootstrap.AdminEmail)
+
+	return errors.Join(
+		lookupDuration("TOKEN_TTL", &cfg.Auth.TokenTTL),
+		lookupDuration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTTL),
+		lookupInt("PASSWORD_BCRYPT_COST", &cfg.Password.BcryptCost),
+		lookupUint32("PASSWORD_ARGON2_TIME", &cfg.Password.Argon2Time),
+		lookupUint32("PASSWORD_ARGON2_MEMORY", &cfg.Password.Argon2Memory),
+		lookupUint8("PASSWORD_ARGON2_THREADS", &cfg.Password.Argon2Threads),
+		lookupInt("DB_PORT", &cfg.Database.Port),
+		lookupBool("DB_AUTO_MIGRATE", &cfg.Database.AutoMigrate),
+		lookupDuration("SESSION_PURGE_INTERVAL", &cfg.Sessions.PurgeInterval),
+		lookupInt("REDIS_DB", &cfg.Sessions.Redis.DB),
+		lookupDuration("HOLD_DURATION", &cfg.Holds.Duration),
+		lookupDuration("HOLD_REAP_INTERVAL", &cfg.Holds.ReapInterval),
+		lookupInt("PRICE_BASE", &cfg.Pricing.BasePrice),
+		lookupFloat("FAKE_PAYMENT_FAILURE_RATE", &cfg.Payments.Fake.FailureRate),
+		lookupDuration("FAKE_PAYMENT_LATENCY", &cfg.Payments.Fake.Latency),
+		lookupDuration("CANCELLATION_FULL_REFUND_BEFORE", &cfg.Cancellation.FullRefundBefore),
+		lookupInt("CANCELLATION_PARTIAL_REFUND", &cfg.Cancellation.PartialRefund),
+		lookupDuration("CHECKIN_OPENS_BEFORE", &cfg.Tickets.CheckInOpensBefore),
+		lookupDuration("CHECKIN_CLOSES_AFTER", &cfg.Tickets.CheckInClosesAfter),
+	)
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.Server.Address == "" {
+		errs = append(errs, errors.New("server address is required"))
+	}
+
+	if len(cfg.Auth.SigningKey) < minSigningKeyLength {
+		errs = append(errs, fmt.Errorf("JWT signing key must be at least %d bytes long", minSigningKeyLength))
+	}
+	for _, key := range cfg.Auth.VerificationKeys {
+		if len(key) < minSigningKeyLength {
+			errs = append(errs, fmt.Errorf("JWT verification keys must be at least %d bytes long", minSigningKeyLength))
+			break
+		}
+	}
+	if cfg.Auth.TokenTTL <= 0 {
+		errs = append(errs, errors.New("token TTL must be positive"))
+	}
+	if cfg.Auth.RefreshTTL < cfg.Auth.TokenTTL {
+		errs = append(errs, errors.New("refresh token TTL must not be shorter than the token TTL"))
+	}
+
+	if _, err := password.NewHasher(cfg.Password.Params()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid password settings: %v", err))
+	}
+
+	if cfg.Database.Host == "" || cfg.Database.Name == "" || cfg.Database.User == "" {
+		errs = append(errs, errors.New("database host, name and user are required"))
+	}
+	if cfg.Database.Port <= 0 || cfg.Database.Port > 65535 {
+		errs = append(errs, errors.New("database port must be between 1 and 65535"))
+	}
+
+	switch cfg.Sessions.Store {
+	case SessionStoreDynamoDB, SessionStoreMemory, SessionStorePostgres:
+	case SessionStoreRedis:
+		if cfg.Sessions.Redis.Address == "" {
+			errs = append(errs, errors.New("redis address is required by the redis session store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown session store %q", cfg.Sessions.Store))
+	}
+	if cfg.Sessions.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("session purge interval must be positive"))
+	}
+
+	if cfg.Holds.Duration <= 0 {
+		errs = append(errs, errors.New("hold duration must be positive"))
+	}
+	if cfg.Holds.ReapInterval <= 0 {
+		errs = append(errs, errors.New("hold reap interval must be positive"))
+	}
+
+	if _, err := pricing.NewEngine(cfg.Pricing.Rules()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid pricing settings: %v", err))
+	}
+
+	if cfg.Tickets.SigningKey != "" && len(cfg.Tickets.SigningKey) < minSigningKeyLength {
+		errs = append(errs, fmt.Errorf("ticket signing key must be at least %d bytes long", minSigningKeyLength))
+	}
+	if cfg.Tickets.CheckInOpensBefore < 0 || cfg.Tickets.CheckInClosesAfter < 0 {
+		errs = append(errs, errors.New("check-in window must not be negative"))
+	}
+
+	if err := cfg.Cancellation.Policy().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid cancellation settings: %v", err))
+	}
+
+	switch cfg.Payments.Provider {
+	case PaymentProviderFake:
+		if cfg.Payments.Fake.FailureRate < 0 || cfg.Payments.Fake.FailureRate > 1 {
+			errs = append(errs, errors.New("fake payment failure rate must be between 0 and 1"))
+		}
+		if cfg.Payments.Fake.Latency < 0 {
+			errs = append(errs, errors.New("fake payment latency must not be negative"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown payment provider %q", cfg.Payments.Provider))
+	}
+
+	bootstrap := cfg.Bootstrap
+	if bootstrap.AdminUsername != "" || bootstrap.AdminPassword != "" || bootstrap.AdminEmail != "" {
+		if bootstrap.AdminUsername == "" || bootstrap.AdminPassword == "" || bootstrap.AdminEmail == "" {
+			errs = append(errs, errors.New("bootstrap admin requires a username, a password and an email"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Params converts the settings to the parameters of the password package
+func (p PasswordConfig) Params() password.Params {
+	params := password.DefaultParams
+	params.Algorithm = password.Algorithm(p.Algorithm)
+	params.BcryptCost = p.BcryptCost
+	params.Argon2Time = p.Argon2Time
+	params.Argon2Memory = p.Argon2Memory
+	params.Argon2Threads = p.Argon2Threads
+	return params
+}
+
+// Rules converts the settings to the rules of the pricing package
+func (p PricingConfig) Rules() pricing.Rules {
+	return pricing.Rules{
+		Currency:              p.Currency,
+		BasePrice:             p.BasePrice,
+		TicketTypes:           p.TicketTypes,
+		Categories:            p.Categories,
+		MatineeBefore:         p.MatineeBefore,
+		MatineeDiscount:       p.MatineeDiscount,
+		WeekendSurcharge:      p.WeekendSurcharge,
+		OpeningNightSurcharge: p.OpeningNightSurcharge,
+	}
+}
+
+// Key returns the key signing the tokens of the e-tickets
+func (t TicketsConfig) Key(auth AuthConfig) string {
+	if t.SigningKey != "" {
+		return t.SigningKey
+	}
+	return auth.SigningKey
+}
+
+// Policy converts the settings to the refund policy of the pricing package
+func (c CancellationConfig) Policy() pricing.RefundPolicy {
+	return pricing.RefundPolicy{
+		FullRefundBefore: c.FullRefundBefore,
+		PartialRefund:    c.PartialRefund,
+	}
+}
+
+// DSN returns the lib/pq connection string
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s dbname=%s port=%d user=%s password=%s sslmode=%s",
+		d.Host, d.Name, d.Port, d.User, d.Password, d.SSLMode,
+	)
+}
+
+func lookupString(name string, target *string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*target = value
+	}
+}
+
+func lookupInt(name string, target *int) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupBool(name string, target *bool) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupFloat(name string, target *float64) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupUint32(name string, target *uint32) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = uint32(parsed)
+	return nil
+}
+
+func lookupUint8(name string, target *uint8) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = uint8(parsed)
+	return nil
+}
+
+func lookupDuration(name string, target *time.Duration) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+// copyPercents copies the default percentages, the configuration file adds
+// its entries to the copy
+func copyPercents(percents map[string]int) map[string]int {
+	copied := make(map[string]int, len(percents))
+	for name, percent := range percents {
+		copied[name] = percent
+	}
+	return copied
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
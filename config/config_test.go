@@ -0,0 +1,172 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testSigningKey = "test-signing-key-with-32-characters"
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+	t.Setenv("DB_NAME", "onewayticket")
+	t.Setenv("DB_USER", "test")
+
+	cfg, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Address)
+	assert.Equal(t, 15*time.Minute, cfg.Auth.TokenTTL)
+	assert.Equal(t, 5432, cfg.Database.Port)
+	assert.Equal(t, "bcrypt", cfg.Password.Algorithm)
+	assert.False(t, cfg.Database.AutoMigrate)
+	assert.Equal(t, 10*time.Minute, cfg.Holds.Duration)
+	assert.Equal(t, "fake", cfg.Payments.Provider)
+}
+
+func TestLoadFileAndEnv(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  address: ":9090"
+auth:
+  signing_key: file-signing-key-with-32-characters
+  token_ttl: 30m
+database:
+  host: db
+  port: 5433
+  name: tickets
+  user: file
+dynamo:
+  endpoint: http://localstack:4566
+`)
+	t.Setenv("DB_USER", "env")
+	t.Setenv("DB_AUTO_MIGRATE", "true")
+	t.Setenv("JWT_VERIFICATION_KEYS", "old-signing-key-with-32-characters-1, old-signing-key-with-32-characters-2")
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ":9090", cfg.Server.Address)
+	assert.Equal(t, 30*time.Minute, cfg.Auth.TokenTTL)
+	assert.Equal(t, "file-signing-key-with-32-characters", cfg.Auth.SigningKey)
+	assert.Len(t, cfg.Auth.VerificationKeys, 2)
+	assert.Equal(t, "env", cfg.Database.User)
+	assert.True(t, cfg.Database.AutoMigrate)
+	assert.Equal(t, "http://localstack:4566", cfg.Dynamo.Endpoint)
+	assert.Equal(t, "host=db dbname=tickets port=5433 user=env password= sslmode=disable", cfg.Database.DSN())
+}
+
+func TestLoadValidation(t *testing.T) {
+	t.Setenv("DB_NAME", "onewayticket")
+	t.Setenv("DB_USER", "test")
+
+	t.Run("Missing Signing Key", func(t *testing.T) {
+		_, err := Load("")
+		assert.ErrorContains(t, err, "JWT signing key")
+	})
+
+	t.Run("Invalid Duration", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("TOKEN_TTL", "forever")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "invalid TOKEN_TTL")
+	})
+
+	t.Run("Incomplete Bootstrap Admin", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("BOOTSTRAP_ADMIN_USERNAME", "admin")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "bootstrap admin")
+	})
+
+	t.Run("Unknown Session Store", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("SESSION_STORE", "memcached")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "unknown session store")
+	})
+
+	t.Run("Unknown Password Algorithm", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("PASSWORD_ALGORITHM", "md5")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "invalid password settings")
+	})
+
+	t.Run("Non Positive Hold Duration", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("HOLD_DURATION", "0s")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "hold duration must be positive")
+	})
+
+	t.Run("Non Positive Base Price", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("PRICE_BASE", "0")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "invalid pricing settings")
+	})
+
+	t.Run("Invalid Failure Rate", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("FAKE_PAYMENT_FAILURE_RATE", "1.5")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "failure rate")
+	})
+
+	t.Run("Invalid Partial Refund", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("CANCELLATION_PARTIAL_REFUND", "150")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "invalid cancellation settings")
+	})
+
+	t.Run("Short Ticket Signing Key", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("TICKET_SIGNING_KEY", "short")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "ticket signing key")
+	})
+
+	t.Run("Unknown Payment Provider", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+		t.Setenv("PAYMENT_PROVIDER", "cash")
+		_, err := Load("")
+		assert.ErrorContains(t, err, "unknown payment provider")
+	})
+}
+
+func TestLoadPricing(t *testing.T) {
+	path := writeConfigFile(t, `
+pricing:
+  currency: CHF
+  ticket_types:
+    member: 50
+  categories:
+    vip: 200
+`)
+	t.Setenv("JWT_SIGNING_KEY", testSigningKey)
+	t.Setenv("DB_NAME", "onewayticket")
+	t.Setenv("DB_USER", "test")
+	t.Setenv("PRICE_BASE", "1500")
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	rules := cfg.Pricing.Rules()
+	assert.Equal(t, "CHF", rules.Currency)
+	assert.Equal(t, 1500, rules.BasePrice)
+	assert.Equal(t, map[string]int{"adult": 100, "child": 60, "senior": 70, "student": 80, "member": 50}, rules.TicketTypes)
+	assert.Equal(t, 200, rules.Categories["vip"])
+	assert.Equal(t, 130, rules.Categories["premium"])
+	assert.Equal(t, "17:00", rules.MatineeBefore)
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS showtimes;
+DROP TABLE IF EXISTS movies;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -1,18 +1,33 @@
-CREATE TABLE users (
+-- Initial schema, formerly db/init.sql. The statements are idempotent so that
+-- databases created from init.sql can adopt the migrations: the tables of
+-- init.sql are kept and the columns added since are added to them.
+CREATE TABLE IF NOT EXISTS users (
                        user_id SERIAL PRIMARY KEY,
                        username VARCHAR(50) NOT NULL,
                        password VARCHAR(255) NOT NULL,
                        email VARCHAR(100) NOT NULL UNIQUE
 );
 
-CREATE TABLE movies (
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer' CHECK (role IN ('admin', 'staff', 'customer'));
+
+CREATE TABLE IF NOT EXISTS sessions (
+                          token VARCHAR(64) PRIMARY KEY,
+                          user_id INT NOT NULL,
+                          refresh_token VARCHAR(64) NOT NULL,
+                          ttl BIGINT NOT NULL,
+                          FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS movies (
                         movie_id SERIAL PRIMARY KEY,
                         title VARCHAR(100) NOT NULL,
                         duration INT NOT NULL,
                         genre VARCHAR(50) NOT NULL
 );
 
-CREATE TABLE showtimes (
+CREATE TABLE IF NOT EXISTS showtimes (
                            showtime_id SERIAL PRIMARY KEY,
                            movie_id INT NOT NULL,
                            showtime TIMESTAMP NOT NULL,
@@ -20,7 +35,7 @@ CREATE TABLE showtimes (
                            FOREIGN KEY (movie_id) REFERENCES movies(movie_id)
 );
 
-CREATE TABLE Bookings (
+CREATE TABLE IF NOT EXISTS Bookings (
                           booking_id SERIAL PRIMARY KEY,
                           user_id INT NOT NULL,
                           showtime_id INT NOT NULL,
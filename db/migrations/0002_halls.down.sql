@@ -0,0 +1,17 @@
+-- Only seats of the default 10x10 layout can be converted back to numbers,
+-- the bookings of other seats are lost.
+DELETE FROM bookings WHERE seat !~ '^[A-J]([1-9]|10)$';
+ALTER TABLE bookings ADD COLUMN seat_number INT;
+UPDATE bookings SET seat_number = (ascii(substr(seat, 1, 1)) - 65) * 10 + substr(seat, 2)::int;
+ALTER TABLE bookings ALTER COLUMN seat_number SET NOT NULL;
+ALTER TABLE bookings ADD CONSTRAINT bookings_seat_number_check CHECK (seat_number > 0 AND seat_number <= 100);
+ALTER TABLE bookings DROP COLUMN seat;
+ALTER TABLE bookings ADD CONSTRAINT bookings_showtime_id_seat_number_key UNIQUE (showtime_id, seat_number);
+
+ALTER TABLE showtimes ADD COLUMN hall VARCHAR(50);
+UPDATE showtimes SET hall = halls.name FROM halls WHERE halls.hall_id = showtimes.hall_id;
+ALTER TABLE showtimes ALTER COLUMN hall SET NOT NULL;
+DROP INDEX showtimes_hall_id_idx;
+ALTER TABLE showtimes DROP COLUMN hall_id;
+
+DROP TABLE halls;
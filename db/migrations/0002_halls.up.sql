@@ -0,0 +1,26 @@
+-- Halls replace the free-text showtimes.hall, and bookings reference seats by
+-- their label in the hall layout instead of a number between 1 and 100.
+CREATE TABLE halls (
+                       hall_id SERIAL PRIMARY KEY,
+                       name VARCHAR(50) NOT NULL UNIQUE,
+                       capacity INT NOT NULL CHECK (capacity >= 0),
+                       layout JSONB NOT NULL
+);
+
+-- existing halls get the layout matching the former 1..100 seat numbers:
+-- rows A to J of 10 seats, seat n becoming row (n-1)/10 and position (n-1)%10+1
+INSERT INTO halls (name, capacity, layout)
+SELECT DISTINCT hall, 100, '{"rows":[{"label":"A","seats":10},{"label":"B","seats":10},{"label":"C","seats":10},{"label":"D","seats":10},{"label":"E","seats":10},{"label":"F","seats":10},{"label":"G","seats":10},{"label":"H","seats":10},{"label":"I","seats":10},{"label":"J","seats":10}]}'::jsonb
+FROM showtimes;
+
+ALTER TABLE showtimes ADD COLUMN hall_id INT REFERENCES halls(hall_id);
+UPDATE showtimes SET hall_id = halls.hall_id FROM halls WHERE halls.name = showtimes.hall;
+ALTER TABLE showtimes ALTER COLUMN hall_id SET NOT NULL;
+ALTER TABLE showtimes DROP COLUMN hall;
+CREATE INDEX showtimes_hall_id_idx ON showtimes (hall_id, showtime);
+
+ALTER TABLE bookings ADD COLUMN seat VARCHAR(10);
+UPDATE bookings SET seat = chr(65 + (seat_number - 1) / 10) || ((seat_number - 1) % 10 + 1);
+ALTER TABLE bookings ALTER COLUMN seat SET NOT NULL;
+ALTER TABLE bookings DROP COLUMN seat_number;
+ALTER TABLE bookings ADD CONSTRAINT bookings_showtime_id_seat_key UNIQUE (showtime_id, seat);
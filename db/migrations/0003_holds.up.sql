@@ -0,0 +1,19 @@
+-- Holds reserve seats for a limited time before they are booked. A seat can
+-- be part of a single hold per showtime, expired holds are deleted by the
+-- reaper or before a new hold of the showtime is created.
+CREATE TABLE holds (
+                       hold_id SERIAL PRIMARY KEY,
+                       user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+                       showtime_id INT NOT NULL REFERENCES showtimes(showtime_id) ON DELETE CASCADE,
+                       expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX holds_expires_at_idx ON holds (expires_at);
+
+CREATE TABLE hold_seats (
+                            hold_id INT NOT NULL REFERENCES holds(hold_id) ON DELETE CASCADE,
+                            showtime_id INT NOT NULL,
+                            seat VARCHAR(10) NOT NULL,
+                            PRIMARY KEY (hold_id, seat),
+                            UNIQUE (showtime_id, seat)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS order_id;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,11 @@
+-- Orders group the bookings of several seats made in one request. Bookings
+-- made one seat at a time have no order.
+CREATE TABLE orders (
+                        order_id SERIAL PRIMARY KEY,
+                        user_id INT NOT NULL REFERENCES users(user_id),
+                        showtime_id INT NOT NULL REFERENCES showtimes(showtime_id),
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE bookings ADD COLUMN order_id INT REFERENCES orders(order_id);
+CREATE INDEX bookings_order_id_idx ON bookings (order_id);
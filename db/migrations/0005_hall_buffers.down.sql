@@ -0,0 +1 @@
+ALTER TABLE halls DROP COLUMN IF EXISTS trailer_minutes, DROP COLUMN IF EXISTS cleaning_minutes;
@@ -0,0 +1,5 @@
+-- Showtimes occupy their hall for the trailers, the movie and the cleaning
+-- afterwards, the buffers are set per hall.
+ALTER TABLE halls
+    ADD COLUMN trailer_minutes INT NOT NULL DEFAULT 15 CHECK (trailer_minutes >= 0),
+    ADD COLUMN cleaning_minutes INT NOT NULL DEFAULT 15 CHECK (cleaning_minutes >= 0);
@@ -0,0 +1 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS ticket_type, DROP COLUMN IF EXISTS price, DROP COLUMN IF EXISTS price_breakdown;
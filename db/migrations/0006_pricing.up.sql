@@ -0,0 +1,6 @@
+-- Bookings keep the ticket type and the price they were sold at. Bookings
+-- made before tickets were priced cost 0 and have no breakdown.
+ALTER TABLE bookings
+    ADD COLUMN ticket_type VARCHAR(20) NOT NULL DEFAULT 'adult',
+    ADD COLUMN price INT NOT NULL DEFAULT 0 CHECK (price >= 0),
+    ADD COLUMN price_breakdown JSONB;
@@ -0,0 +1 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS status, DROP COLUMN IF EXISTS amount, DROP COLUMN IF EXISTS currency, DROP COLUMN IF EXISTS payment_id, DROP COLUMN IF EXISTS expires_at;
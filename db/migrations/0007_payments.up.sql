@@ -0,0 +1,12 @@
+-- Orders are paid through a payment provider. Orders placed before payments
+-- existed were settled at the box office and count as paid.
+ALTER TABLE orders
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'paid'
+        CHECK (status IN ('pending', 'paid', 'failed', 'refunded')),
+    ADD COLUMN amount INT NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'EUR',
+    ADD COLUMN payment_id VARCHAR(100) UNIQUE,
+    ADD COLUMN expires_at TIMESTAMPTZ;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX orders_pending_expires_at_idx ON orders (expires_at) WHERE status = 'pending';
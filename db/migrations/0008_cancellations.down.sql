@@ -0,0 +1,5 @@
+-- The history of cancelled bookings is lost, their seats may be booked again.
+DELETE FROM bookings WHERE status = 'cancelled';
+DROP INDEX IF EXISTS bookings_showtime_id_seat_key;
+ALTER TABLE bookings ADD CONSTRAINT bookings_showtime_id_seat_key UNIQUE (showtime_id, seat);
+ALTER TABLE bookings DROP COLUMN IF EXISTS status, DROP COLUMN IF EXISTS cancelled_at, DROP COLUMN IF EXISTS cancellation_reason, DROP COLUMN IF EXISTS refund_amount;
@@ -0,0 +1,9 @@
+-- Cancelled bookings are kept for history with the amount refunded. Their seat
+-- is free again, so only active bookings must have distinct seats.
+ALTER TABLE bookings
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+    ADD COLUMN cancelled_at TIMESTAMPTZ,
+    ADD COLUMN cancellation_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN refund_amount INT CHECK (refund_amount >= 0);
+ALTER TABLE bookings DROP CONSTRAINT bookings_showtime_id_seat_key;
+CREATE UNIQUE INDEX bookings_showtime_id_seat_key ON bookings (showtime_id, seat) WHERE status = 'active';
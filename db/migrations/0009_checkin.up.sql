@@ -0,0 +1,2 @@
+-- The ticket of a booking is used once, when it is scanned at the entrance.
+ALTER TABLE bookings ADD COLUMN checked_in_at TIMESTAMPTZ;
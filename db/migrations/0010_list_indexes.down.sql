@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS bookings_showtime_id_idx;
+DROP INDEX IF EXISTS bookings_user_id_idx;
+DROP INDEX IF EXISTS showtimes_movie_id_idx;
+DROP INDEX IF EXISTS showtimes_showtime_idx;
@@ -0,0 +1,6 @@
+-- Lists are read page by page in the order of a sort field then of the ID,
+-- these indexes serve the pages of the most common filters and sorts.
+CREATE INDEX showtimes_showtime_idx ON showtimes (showtime, showtime_id);
+CREATE INDEX showtimes_movie_id_idx ON showtimes (movie_id, showtime);
+CREATE INDEX bookings_user_id_idx ON bookings (user_id, booking_id);
+CREATE INDEX bookings_showtime_id_idx ON bookings (showtime_id, booking_id);
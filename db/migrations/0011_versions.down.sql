@@ -0,0 +1,4 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS version;
+ALTER TABLE showtimes DROP COLUMN IF EXISTS version;
+ALTER TABLE movies DROP COLUMN IF EXISTS version;
+ALTER TABLE users DROP COLUMN IF EXISTS version;
@@ -0,0 +1,6 @@
+-- The version of a record counts its changes, clients send it back as the
+-- ETag of the record to update it only if nobody changed it in between.
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE movies ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE showtimes ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE bookings ADD COLUMN version INT NOT NULL DEFAULT 1;
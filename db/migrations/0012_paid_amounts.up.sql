@@ -0,0 +1,6 @@
+-- The amount paid for a booking of an order is recorded when the order is
+-- paid, refunds are computed from it. Paid and refunded orders paid the price
+-- of their bookings.
+ALTER TABLE bookings ADD COLUMN paid_amount INT CHECK (paid_amount >= 0);
+UPDATE bookings SET paid_amount = price
+    FROM orders WHERE orders.order_id = bookings.order_id AND orders.status IN ('paid', 'refunded');
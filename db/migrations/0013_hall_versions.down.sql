@@ -0,0 +1 @@
+ALTER TABLE halls DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+-- Halls are versioned like the other records, their version is their ETag.
+ALTER TABLE halls ADD COLUMN version INT NOT NULL DEFAULT 1;
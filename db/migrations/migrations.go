@@ -0,0 +1,304 @@
+// Package migrations applies the versioned schema migrations embedded in the
+// binary. A migration is a pair of files NNNN_name.up.sql and
+// NNNN_name.down.sql, applied in increasing version order. Every applied
+// migration is recorded in the schema_migrations table together with the
+// checksum of its up script, so that editing an applied migration is detected.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.New()
+
+//go:embed *.sql
+var embedded embed.FS
+
+// lockID identifies the advisory lock held while migrating, so that several
+// instances starting at once do not apply the same migration twice
+const lockID int64 = 0x6f6e652d776179
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	ErrUnknownVersion   = errors.New("database contains a migration unknown to this binary")
+	ErrNoDownMigration  = errors.New("migration cannot be reverted")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes a known migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Load reads the migrations of fsys, sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %v", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	dbx        *sqlx.DB
+	migrations []Migration
+}
+
+// New creates a Migrator with the migrations embedded in the binary
+func New(dbx *sqlx.DB) (*Migrator, error) {
+	return NewMigrator(dbx, embedded)
+}
+
+// NewMigrator creates a Migrator with the migrations of fsys
+func NewMigrator(dbx *sqlx.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{dbx: dbx, migrations: migrations}, nil
+}
+
+// Up applies every pending migration, each one in its own transaction, and
+// returns the applied migrations
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(conn *sqlx.Conn) error {
+		applied, err := m.verify(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err = inTx(conn, func(tx *sqlx.Tx) error {
+				if _, err := tx.Exec(migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			log.Infof("Applied migration %d_%s", migration.Version, migration.Name)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the reverted migrations
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(conn *sqlx.Conn) error {
+		applied, err := m.verify(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+			}
+			err = inTx(conn, func(tx *sqlx.Tx) error {
+				if _, err := tx.Exec(migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec("DELETE FROM schema_migrations WHERE version=$1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			log.Infof("Reverted migration %d_%s", migration.Version, migration.Name)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists the known migrations and whether they have been applied
+func (m *Migrator) Status() ([]Status, error) {
+	var statuses []Status
+	err := m.locked(func(conn *sqlx.Conn) error {
+		applied, err := m.verify(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if a, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = a.AppliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs fn on a dedicated connection holding the advisory lock, the
+// lock belongs to the session so every statement must use that connection
+func (m *Migrator) locked(fn func(conn *sqlx.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.dbx.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer func() {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+		if err != nil {
+			log.Error("Failed to release migration lock: ", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	return fn(conn)
+}
+
+// verify loads the applied migrations and checks that each of them is known
+// and unchanged
+func (m *Migrator) verify(conn *sqlx.Conn) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	err := conn.SelectContext(context.Background(), &rows, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+
+	known := map[int64]Migration{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	applied := map[int64]appliedMigration{}
+	for _, row := range rows {
+		migration, ok := known[row.Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d_%s", ErrUnknownVersion, row.Version, row.Name)
+		}
+		if migration.Checksum != row.Checksum {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, row.Version, row.Name)
+		}
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func inTx(conn *sqlx.Conn, fn func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
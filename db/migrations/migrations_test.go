@@ -0,0 +1,178 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"one-way-ticket/config"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0002_items_name.up.sql":   {Data: []byte("ALTER TABLE items ADD COLUMN name TEXT;")},
+		"0002_items_name.down.sql": {Data: []byte("ALTER TABLE items DROP COLUMN name;")},
+		"0001_items.up.sql":        {Data: []byte("CREATE TABLE items (id SERIAL PRIMARY KEY);")},
+		"0001_items.down.sql":      {Data: []byte("DROP TABLE items;")},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS())
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 2) {
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "items", migrations[0].Name)
+		assert.Equal(t, "DROP TABLE items;", migrations[0].Down)
+		assert.Len(t, migrations[0].Checksum, 64)
+		assert.Equal(t, int64(2), migrations[1].Version)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Run("Invalid Name", func(t *testing.T) {
+		_, err := Load(fstest.MapFS{"items.sql": {Data: []byte("SELECT 1;")}})
+		assert.ErrorContains(t, err, "invalid migration file name")
+	})
+
+	t.Run("Missing Up Script", func(t *testing.T) {
+		_, err := Load(fstest.MapFS{"0001_items.down.sql": {Data: []byte("DROP TABLE items;")}})
+		assert.ErrorContains(t, err, "has no up script")
+	})
+
+	t.Run("Conflicting Names", func(t *testing.T) {
+		_, err := Load(fstest.MapFS{
+			"0001_items.up.sql":  {Data: []byte("SELECT 1;")},
+			"0001_things.up.sql": {Data: []byte("SELECT 1;")},
+		})
+		assert.ErrorContains(t, err, "has two names")
+	})
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(embedded)
+	assert.NoError(t, err)
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, migration.Down, "migration %d_%s has no down script", migration.Version, migration.Name)
+	}
+}
+
+// TestMigrator runs in its own schema so that it does not interfere with the
+// tables used by the other packages
+func TestMigrator(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("database is not configured")
+	}
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := sqlx.Connect("postgres", cfg.Database.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	admin.MustExec("DROP SCHEMA IF EXISTS migrations_test CASCADE")
+	admin.MustExec("CREATE SCHEMA migrations_test")
+	defer admin.MustExec("DROP SCHEMA migrations_test CASCADE")
+
+	dbx, err := sqlx.Connect("postgres", cfg.Database.DSN()+" search_path=migrations_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbx.Close()
+
+	migrator, err := NewMigrator(dbx, testFS())
+	assert.NoError(t, err)
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 2)
+	dbx.MustExec("INSERT INTO items (name) VALUES ('seat')")
+
+	// nothing left to apply
+	applied, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := migrator.Down(1)
+	assert.NoError(t, err)
+	if assert.Len(t, reverted, 1) {
+		assert.Equal(t, int64(2), reverted[0].Version)
+	}
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 2) {
+		assert.True(t, statuses[0].Applied)
+		assert.False(t, statuses[1].Applied)
+	}
+
+	t.Run("Checksum Mismatch", func(t *testing.T) {
+		fsys := testFS()
+		fsys["0001_items.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE items (id BIGSERIAL PRIMARY KEY);")}
+		modified, err := NewMigrator(dbx, fsys)
+		assert.NoError(t, err)
+		_, err = modified.Up()
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("Unknown Version", func(t *testing.T) {
+		fsys := testFS()
+		delete(fsys, "0001_items.up.sql")
+		delete(fsys, "0001_items.down.sql")
+		older, err := NewMigrator(dbx, fsys)
+		assert.NoError(t, err)
+		_, err = older.Up()
+		assert.ErrorIs(t, err, ErrUnknownVersion)
+	})
+}
+
+// TestAdoptInitSchema applies the embedded migrations to a database created
+// from the former db/init.sql
+func TestAdoptInitSchema(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("database is not configured")
+	}
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := sqlx.Connect("postgres", cfg.Database.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	admin.MustExec("DROP SCHEMA IF EXISTS adoption_test CASCADE")
+	admin.MustExec("CREATE SCHEMA adoption_test")
+	defer admin.MustExec("DROP SCHEMA adoption_test CASCADE")
+
+	dbx, err := sqlx.Connect("postgres", cfg.Database.DSN()+" search_path=adoption_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbx.Close()
+
+	// the users table of init.sql, which had no roles
+	dbx.MustExec(`CREATE TABLE users (
+		user_id SERIAL PRIMARY KEY,
+		username VARCHAR(50) NOT NULL,
+		password VARCHAR(255) NOT NULL,
+		email VARCHAR(100) NOT NULL UNIQUE
+	)`)
+	dbx.MustExec("INSERT INTO users (username, password, email) VALUES ('olduser', 'hash', 'old@example.com')")
+
+	migrator, err := New(dbx)
+	assert.NoError(t, err)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+
+	var role string
+	assert.NoError(t, dbx.Get(&role, "SELECT role FROM users WHERE username = 'olduser'"))
+	assert.Equal(t, "customer", role)
+	_, err = dbx.Exec("UPDATE users SET role = 'owner'")
+	assert.Error(t, err)
+}
@@ -1,43 +1,47 @@
 package dynamo
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"one-way-ticket/config"
 	"one-way-ticket/models"
-	"os"
+	"strconv"
 )
 
 var TableName = "sessions"
 
+// UserIndexName is the global secondary index of the sessions table keyed by user_id
+var UserIndexName = "user_id-index"
+
+// ErrRefreshTokenReused is returned when a refresh token is rotated but the
+// session no longer holds it, i.e. it has already been used
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
 // NewDynamoClient initialize AWS session that the SDK uses for communication
-func NewDynamoClient() dynamodbiface.DynamoDBAPI {
-	awsAccessKey := os.Getenv("AWS_ACCESS_KEY_ID")
-	awsSecretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-	awsRegion := os.Getenv("AWS_REGION")
-	if awsRegion == "" {
-		awsRegion = "us-east-1"
-	}
+func NewDynamoClient(cfg config.DynamoConfig) dynamodbiface.DynamoDBAPI {
 	token := ""
 
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region:      aws.String(awsRegion),
-		Credentials: credentials.NewStaticCredentials(awsAccessKey, awsSecretKey, token),
-	}))
+	awsConfig := &aws.Config{
+		Region:      aws.String(cfg.Region),
+		Credentials: credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, token),
+	}
+	if cfg.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.Endpoint)
+	}
+
+	sess := session.Must(session.NewSession(awsConfig))
 	return dynamodb.New(sess)
 }
 
 // CreateSession creates a new session
-func CreateSession(svc dynamodbiface.DynamoDBAPI, token string, ttl int64) error {
-	sess := models.Session{
-		Token: token,
-		TTL:   ttl,
-	}
-
+func CreateSession(svc dynamodbiface.DynamoDBAPI, sess models.Session) error {
 	av, err := dynamodbattribute.MarshalMap(sess)
 	if err != nil {
 		return fmt.Errorf("failed to marshal session: %v", err)
@@ -56,12 +60,12 @@ func CreateSession(svc dynamodbiface.DynamoDBAPI, token string, ttl int64) error
 }
 
 // GetSessionForUser retrieves a session by sessionID
-func GetSessionForUser(svc dynamodbiface.DynamoDBAPI, userID string) (*models.Session, error) {
+func GetSessionForUser(svc dynamodbiface.DynamoDBAPI, token string) (*models.Session, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(TableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"token": {
-				S: aws.String(userID),
+				S: aws.String(token),
 			},
 		},
 	}
@@ -83,3 +87,196 @@ func GetSessionForUser(svc dynamodbiface.DynamoDBAPI, userID string) (*models.Se
 
 	return &sess, nil
 }
+
+// RotateRefreshToken replaces the refresh token hash of a session and extends
+// its TTL, provided the session still holds oldHash. The condition makes the
+// rotation atomic: of two concurrent refreshes with the same token only one
+// succeeds, the other gets ErrRefreshTokenReused.
+func RotateRefreshToken(svc dynamodbiface.DynamoDBAPI, token, oldHash, newHash string, ttl int64) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"token": {
+				S: aws.String(token),
+			},
+		},
+		UpdateExpression:    aws.String("SET refresh_token = :new, #ttl = :ttl"),
+		ConditionExpression: aws.String("refresh_token = :old"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ttl": aws.String("ttl"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":new": {S: aws.String(newHash)},
+			":old": {S: aws.String(oldHash)},
+			":ttl": {N: aws.String(strconv.FormatInt(ttl, 10))},
+		},
+	}
+
+	_, err := svc.UpdateItem(input)
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrRefreshTokenReused
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update item in DynamoDB: %v", err)
+	}
+	return nil
+}
+
+// DeleteSession deletes a session, deleting a missing session is not an error
+func DeleteSession(svc dynamodbiface.DynamoDBAPI, token string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"token": {
+				S: aws.String(token),
+			},
+		},
+	}
+
+	_, err := svc.DeleteItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to delete item from DynamoDB: %v", err)
+	}
+	return nil
+}
+
+// GetSessionsByUser lists the sessions of a user through the user_id index
+func GetSessionsByUser(svc dynamodbiface.DynamoDBAPI, userID uint) ([]models.Session, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(TableName),
+		IndexName:              aws.String(UserIndexName),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":user_id": {N: aws.String(strconv.FormatUint(uint64(userID), 10))},
+		},
+	}
+
+	var sessions []models.Session
+	for {
+		result, err := svc.Query(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query DynamoDB: %v", err)
+		}
+
+		var page []models.Session
+		err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sessions: %v", err)
+		}
+		sessions = append(sessions, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return sessions, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// DeleteSessionsForUser revokes every session of a user
+func DeleteSessionsForUser(svc dynamodbiface.DynamoDBAPI, userID uint) error {
+	sessions, err := GetSessionsByUser(svc, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		err = DeleteSession(svc, sess.Token)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions whose TTL is before now. DynamoDB
+// deletes expired items on its own, but only within a few days.
+func DeleteExpiredSessions(svc dynamodbiface.DynamoDBAPI, now int64) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(TableName),
+		FilterExpression:     aws.String("#ttl < :now"),
+		ProjectionExpression: aws.String("#token"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ttl":   aws.String("ttl"),
+			"#token": aws.String("token"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now, 10))},
+		},
+	}
+
+	deleted := 0
+	for {
+		result, err := svc.Scan(input)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to scan DynamoDB: %v", err)
+		}
+
+		for _, item := range result.Items {
+			err = DeleteSession(svc, aws.StringValue(item["token"].S))
+			if err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return deleted, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// EnsureSessionsTable creates the sessions table with its user_id index and
+// enables the DynamoDB TTL on the ttl attribute when the table does not exist
+func EnsureSessionsTable(svc dynamodbiface.DynamoDBAPI) error {
+	_, err := svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(TableName)})
+	if err == nil {
+		return nil
+	}
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || awsErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+		return fmt.Errorf("failed to describe table: %v", err)
+	}
+
+	_, err = svc.CreateTable(&dynamodb.CreateTableInput{
+		TableName:   aws.String(TableName),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("token"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("user_id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("token"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(UserIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("user_id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+				},
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create table: %v", err)
+	}
+
+	err = svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(TableName)})
+	if err != nil {
+		return fmt.Errorf("failed to wait for table creation: %v", err)
+	}
+
+	_, err = svc.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(TableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String("ttl"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable TTL: %v", err)
+	}
+	return nil
+}
@@ -3,9 +3,11 @@ package dynamo
 import (
 	"errors"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"one-way-ticket/mocks"
 	"one-way-ticket/models"
 	"testing"
@@ -15,19 +17,22 @@ import (
 func TestCreateSession(t *testing.T) {
 	mockSvc := new(mocks.MockDynamoDBClient)
 	mockSession := models.Session{
-		Token: "test-token",
-		TTL:   123456,
+		Token:        "test-token",
+		UserID:       7,
+		RefreshToken: "refresh-hash",
+		TTL:          123456,
 	}
 
 	av, err := dynamodbattribute.MarshalMap(mockSession)
 	assert.NoError(t, err)
+	assert.Equal(t, "7", *av["user_id"].N)
 
 	mockSvc.On("PutItem", &dynamodb.PutItemInput{
 		TableName: aws.String(TableName),
 		Item:      av,
 	}).Return(&dynamodb.PutItemOutput{}, nil)
 
-	err = CreateSession(mockSvc, "test-token", 123456)
+	err = CreateSession(mockSvc, mockSession)
 	assert.NoError(t, err)
 
 	mockSvc.AssertExpectations(t)
@@ -101,3 +106,96 @@ func TestGetSessionForUser_Error(t *testing.T) {
 
 	mockSvc.AssertExpectations(t)
 }
+
+// Test RotateRefreshToken
+func TestRotateRefreshToken(t *testing.T) {
+	mockSvc := new(mocks.MockDynamoDBClient)
+	mockSvc.On("UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return *input.Key["token"].S == "test-token" &&
+			*input.ConditionExpression == "refresh_token = :old" &&
+			*input.ExpressionAttributeValues[":old"].S == "old-hash" &&
+			*input.ExpressionAttributeValues[":new"].S == "new-hash" &&
+			*input.ExpressionAttributeValues[":ttl"].N == "123456"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := RotateRefreshToken(mockSvc, "test-token", "old-hash", "new-hash", 123456)
+	assert.NoError(t, err)
+
+	mockSvc.AssertExpectations(t)
+}
+
+// Test RotateRefreshToken when the refresh token was already rotated
+func TestRotateRefreshToken_Reused(t *testing.T) {
+	mockSvc := new(mocks.MockDynamoDBClient)
+	mockSvc.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{},
+		awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil))
+
+	err := RotateRefreshToken(mockSvc, "test-token", "old-hash", "new-hash", 123456)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	mockSvc.AssertExpectations(t)
+}
+
+// Test DeleteSessionsForUser
+func TestDeleteSessionsForUser(t *testing.T) {
+	mockSvc := new(mocks.MockDynamoDBClient)
+	first, err := dynamodbattribute.MarshalMap(models.Session{Token: "first-token", UserID: 7, TTL: 123456})
+	assert.NoError(t, err)
+	second, err := dynamodbattribute.MarshalMap(models.Session{Token: "second-token", UserID: 7, TTL: 123456})
+	assert.NoError(t, err)
+
+	lastKey := map[string]*dynamodb.AttributeValue{"token": {S: aws.String("first-token")}}
+	mockSvc.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.IndexName == UserIndexName && input.ExclusiveStartKey == nil &&
+			*input.ExpressionAttributeValues[":user_id"].N == "7"
+	})).Return(&dynamodb.QueryOutput{
+		Items:            []map[string]*dynamodb.AttributeValue{first},
+		LastEvaluatedKey: lastKey,
+	}, nil).Once()
+	mockSvc.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ExclusiveStartKey != nil
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{second},
+	}, nil).Once()
+	for _, token := range []string{"first-token", "second-token"} {
+		mockSvc.On("DeleteItem", &dynamodb.DeleteItemInput{
+			TableName: aws.String(TableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"token": {
+					S: aws.String(token),
+				},
+			},
+		}).Return(&dynamodb.DeleteItemOutput{}, nil).Once()
+	}
+
+	err = DeleteSessionsForUser(mockSvc, 7)
+	assert.NoError(t, err)
+
+	mockSvc.AssertExpectations(t)
+}
+
+// Test DeleteExpiredSessions
+func TestDeleteExpiredSessions(t *testing.T) {
+	mockSvc := new(mocks.MockDynamoDBClient)
+	mockSvc.On("Scan", mock.MatchedBy(func(input *dynamodb.ScanInput) bool {
+		return *input.ExpressionAttributeValues[":now"].N == "123456"
+	})).Return(&dynamodb.ScanOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"token": {S: aws.String("expired-token")}},
+		},
+	}, nil)
+	mockSvc.On("DeleteItem", &dynamodb.DeleteItemInput{
+		TableName: aws.String(TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"token": {
+				S: aws.String("expired-token"),
+			},
+		},
+	}).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+	deleted, err := DeleteExpiredSessions(mockSvc, 123456)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	mockSvc.AssertExpectations(t)
+}
@@ -1,21 +1,89 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"one-way-ticket/auth/password"
+	"one-way-ticket/config"
 	"one-way-ticket/db"
+	"one-way-ticket/db/migrations"
+	"one-way-ticket/payments"
+	"one-way-ticket/repository/postgres"
 	"one-way-ticket/routers"
+	"one-way-ticket/service/bookings"
+	"one-way-ticket/service/users"
+	"one-way-ticket/sessions"
+	"os"
 )
 
 func main() {
-	err := db.Connect()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML configuration file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	hasher, err := password.NewHasher(cfg.Password.Params())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	password.SetDefault(hasher)
+
+	err = db.Connect(cfg.Database)
 	if err != nil {
 		return
 	}
 	defer db.Close()
 
-	r := routers.SetupRouter()
-	// listen and serve on 0.0.0.0:8080
-	err = r.Run(":8080")
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(db.Dbx, flag.Args()[1:])
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
+	if cfg.Database.AutoMigrate {
+		migrator, err := migrations.New(db.Dbx)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		_, err = migrator.Up()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	repos := postgres.NewStore(db.Dbx)
+
+	err = users.BootstrapAdmin(repos.Users(), cfg.Bootstrap)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	store, err := sessions.NewStore(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sessions.StartPurger(ctx, store, cfg.Sessions.PurgeInterval)
+	bookings.StartHoldReaper(ctx, repos.Holds(), cfg.Holds.ReapInterval)
+	bookings.StartOrderReaper(ctx, repos, cfg.Holds.ReapInterval)
+
+	provider, err := payments.NewProvider(cfg.Payments)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	r, err := routers.SetupRouter(cfg, store, repos, provider)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	err = r.Run(cfg.Server.Address)
 	if err != nil {
 		log.Fatal(err.Error())
 		return
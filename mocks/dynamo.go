@@ -23,3 +23,27 @@ func (m *MockDynamoDBClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.Ge
 	args := m.Called(input)
 	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
 }
+
+// UpdateItem Mock method
+func (m *MockDynamoDBClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+// DeleteItem Mock method
+func (m *MockDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+
+// Query Mock method
+func (m *MockDynamoDBClient) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+// Scan Mock method
+func (m *MockDynamoDBClient) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
@@ -1,14 +1,70 @@
 package models
 
+import "time"
+
+// BookingStatus tells whether the seat of a booking is still taken
+type BookingStatus string
+
+const (
+	BookingStatusActive BookingStatus = "active"
+	// BookingStatusCancelled bookings are kept for history, their seat is
+	// free
+	BookingStatusCancelled BookingStatus = "cancelled"
+)
+
 type Booking struct {
 	BookingID  int `db:"booking_id" json:"booking_id"`
 	UserID     int `db:"user_id" json:"user_id"`
 	ShowtimeID int `db:"showtime_id" json:"showtime_id"`
-	SeatNumber int `db:"seat_number" json:"seat_number"`
+	// Seat is the label of the seat in the hall layout, e.g. "F12"
+	Seat string `db:"seat" json:"seat"`
+	// OrderID is the order the booking was made with, if any
+	OrderID    *int   `db:"order_id" json:"order_id,omitempty"`
+	TicketType string `db:"ticket_type" json:"ticket_type"`
+	// Price is the total of PriceBreakdown in cents, bookings made before
+	// tickets were priced have neither
+	Price          int             `db:"price" json:"price"`
+	PriceBreakdown *PriceBreakdown `db:"price_breakdown" json:"price_breakdown,omitempty"`
+	// PaidAmount is the amount paid for the booking in cents, recorded when
+	// its order is paid
+	PaidAmount *int          `db:"paid_amount" json:"paid_amount,omitempty"`
+	Status     BookingStatus `db:"status" json:"status"`
+	// CancelledAt, CancellationReason and RefundAmount, in cents, are set
+	// when the booking is cancelled
+	CancelledAt        *time.Time `db:"cancelled_at" json:"cancelled_at,omitempty"`
+	CancellationReason string     `db:"cancellation_reason" json:"cancellation_reason,omitempty"`
+	RefundAmount       *int       `db:"refund_amount" json:"refund_amount,omitempty"`
+	// CheckedInAt is set when the ticket of the booking is scanned at the
+	// entrance
+	CheckedInAt *time.Time `db:"checked_in_at" json:"checked_in_at,omitempty"`
+	// Version counts the changes of the booking, cancelling it and checking
+	// it in included, it is its ETag
+	Version int `db:"version" json:"version"`
+}
+
+// Paid returns the amount paid for the booking in cents, the amount refunds
+// are computed from. Bookings without an order are paid their price at the
+// box office, those of an order that was not paid yet are not paid at all.
+func (b Booking) Paid() int {
+	if b.OrderID == nil {
+		return b.Price
+	}
+	if b.PaidAmount == nil {
+		return 0
+	}
+	return *b.PaidAmount
 }
 
 type BookingInput struct {
-	UserID     int `db:"user_id" json:"user_id" binding:"required"`
-	ShowtimeID int `db:"showtime_id" json:"showtime_id" binding:"required"`
-	SeatNumber int `db:"seat_number" json:"seat_number" binding:"required"`
+	// UserID defaults to the caller, only staff may book for other users
+	UserID     int    `db:"user_id" json:"user_id,omitempty"`
+	ShowtimeID int    `db:"showtime_id" json:"showtime_id" binding:"required"`
+	Seat       string `db:"seat" json:"seat" binding:"required"`
+	// TicketType defaults to DefaultTicketType
+	TicketType string `db:"ticket_type" json:"ticket_type,omitempty"`
+}
+
+// CancellationInput is the optional body of a cancellation
+type CancellationInput struct {
+	Reason string `json:"reason"`
 }
@@ -3,6 +3,8 @@ package models
 import "github.com/dgrijalva/jwt-go"
 
 type Claims struct {
+	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	Role     Role   `json:"role"`
 	jwt.StandardClaims
 }
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Buffers of the halls that do not set theirs, in minutes
+const (
+	DefaultTrailerMinutes  = 15
+	DefaultCleaningMinutes = 15
+)
+
+// Bounds of a hall layout, seats are listed on every seat map and booking so
+// the size of a hall is capped
+const (
+	MaxRows     = 100
+	MaxRowSeats = 100
+)
+
+type Hall struct {
+	HallID int    `db:"hall_id" json:"hall_id"`
+	Name   string `db:"name" json:"name"`
+	// Capacity is the number of bookable seats, derived from the layout
+	Capacity int        `db:"capacity" json:"capacity"`
+	Layout   HallLayout `db:"layout" json:"layout"`
+	// TrailerMinutes are shown before the movie, CleaningMinutes are needed
+	// after it before the next showtime can start
+	TrailerMinutes  int `db:"trailer_minutes" json:"trailer_minutes"`
+	CleaningMinutes int `db:"cleaning_minutes" json:"cleaning_minutes"`
+	// Version counts the changes of the hall, it is its ETag
+	Version int `db:"version" json:"version"`
+}
+
+type HallInput struct {
+	Name   string     `json:"name" binding:"required,max=50"`
+	Layout HallLayout `json:"layout" binding:"required"`
+	// the buffers default to DefaultTrailerMinutes and DefaultCleaningMinutes
+	TrailerMinutes  *int `json:"trailer_minutes" binding:"omitempty,min=0"`
+	CleaningMinutes *int `json:"cleaning_minutes" binding:"omitempty,min=0"`
+}
+
+// ShowtimeEnd returns when the hall is free again after a showtime starting
+// at start of a movie lasting duration minutes: the trailers, the movie and
+// the cleaning are over
+func (h Hall) ShowtimeEnd(start time.Time, duration int) time.Time {
+	return start.Add(time.Duration(h.TrailerMinutes+duration+h.CleaningMinutes) * time.Minute)
+}
+
+// HallLayout describes the seats of a hall row by row. A seat is labelled
+// with its row label followed by its position in the row, e.g. "F12". Each
+// seat has a single label: "F012" names no seat, so that bookings of a seat
+// are always stored under the same label.
+type HallLayout struct {
+	Rows []HallRow `json:"rows" binding:"required"`
+}
+
+type HallRow struct {
+	Label string `json:"label"`
+	// Seats is the number of positions in the row, numbered from 1
+	Seats int `json:"seats"`
+	// Aisles are positions without a seat, the numbering is not shifted so
+	// the seats keep the same label when an aisle is added
+	Aisles []int `json:"aisles,omitempty"`
+	// Disabled seats exist but cannot be booked, e.g. broken seats
+	Disabled []int `json:"disabled,omitempty"`
+	// Category of the seats of the row, DefaultSeatCategory when empty
+	Category string `json:"category,omitempty"`
+}
+
+// DefaultSeatCategory is the category of the rows that do not set one
+const DefaultSeatCategory = "standard"
+
+var (
+	rowLabel      = regexp.MustCompile(`^[A-Z]{1,2}$`)
+	seatLabel     = regexp.MustCompile(`^([A-Z]{1,2})([0-9]+)$`)
+	categoryLabel = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,29}$`)
+)
+
+// Validate checks that the rows are uniquely labelled, that the layout stays
+// within MaxRows rows of MaxRowSeats positions and that the aisles and
+// disabled seats are positions of their row
+func (l HallLayout) Validate() error {
+	if len(l.Rows) == 0 {
+		return errors.New("layout must have at least one row")
+	}
+	if len(l.Rows) > MaxRows {
+		return fmt.Errorf("layout must have at most %d rows", MaxRows)
+	}
+
+	labels := map[string]bool{}
+	for _, row := range l.Rows {
+		if !rowLabel.MatchString(row.Label) {
+			return fmt.Errorf("row label %q must be one or two capital letters", row.Label)
+		}
+		if labels[row.Label] {
+			return fmt.Errorf("row %s is defined twice", row.Label)
+		}
+		labels[row.Label] = true
+
+		if row.Category != "" && !categoryLabel.MatchString(row.Category) {
+			return fmt.Errorf("category %q of row %s must be a lower case identifier", row.Category, row.Label)
+		}
+		if row.Seats < 1 {
+			return fmt.Errorf("row %s must have at least one seat", row.Label)
+		}
+		if row.Seats > MaxRowSeats {
+			return fmt.Errorf("row %s must have at most %d seats", row.Label, MaxRowSeats)
+		}
+		for _, position := range append(append([]int{}, row.Aisles...), row.Disabled...) {
+			if position < 1 || position > row.Seats {
+				return fmt.Errorf("position %d is outside of row %s", position, row.Label)
+			}
+		}
+		for _, position := range row.Disabled {
+			if row.isAisle(position) {
+				return fmt.Errorf("position %d of row %s is an aisle and cannot be disabled", position, row.Label)
+			}
+		}
+	}
+	return nil
+}
+
+// Capacity counts the bookable seats
+func (l HallLayout) Capacity() int {
+	capacity := 0
+	for _, row := range l.Rows {
+		for position := 1; position <= row.Seats; position++ {
+			if row.isSeat(position) && !row.isDisabled(position) {
+				capacity++
+			}
+		}
+	}
+	return capacity
+}
+
+// Seats lists the labels of every seat, disabled ones included, row by row
+func (l HallLayout) Seats() []string {
+	var seats []string
+	for _, row := range l.Rows {
+		for position := 1; position <= row.Seats; position++ {
+			if row.isSeat(position) {
+				seats = append(seats, row.Label+strconv.Itoa(position))
+			}
+		}
+	}
+	return seats
+}
+
+// HasSeat reports whether the label names a seat of the hall
+func (l HallLayout) HasSeat(label string) bool {
+	row, position, ok := l.find(label)
+	return ok && row.isSeat(position)
+}
+
+// IsBookable reports whether the label names a seat that can be booked
+func (l HallLayout) IsBookable(label string) bool {
+	row, position, ok := l.find(label)
+	return ok && row.isSeat(position) && !row.isDisabled(position)
+}
+
+// SeatCategory returns the category of the seat, or "" when the label does
+// not name a seat of the hall
+func (l HallLayout) SeatCategory(label string) string {
+	row, position, ok := l.find(label)
+	if !ok || !row.isSeat(position) {
+		return ""
+	}
+	return row.SeatCategory()
+}
+
+func (l HallLayout) find(label string) (HallRow, int, bool) {
+	match := seatLabel.FindStringSubmatch(label)
+	if match == nil {
+		return HallRow{}, 0, false
+	}
+	position, err := strconv.Atoi(match[2])
+	if err != nil || match[1]+strconv.Itoa(position) != label {
+		return HallRow{}, 0, false
+	}
+	for _, row := range l.Rows {
+		if row.Label == match[1] {
+			return row, position, position >= 1 && position <= row.Seats
+		}
+	}
+	return HallRow{}, 0, false
+}
+
+// SeatCategory returns the category of the seats of the row
+func (r HallRow) SeatCategory() string {
+	if r.Category == "" {
+		return DefaultSeatCategory
+	}
+	return r.Category
+}
+
+func (r HallRow) isSeat(position int) bool {
+	return !r.isAisle(position)
+}
+
+func (r HallRow) isAisle(position int) bool {
+	return containsInt(r.Aisles, position)
+}
+
+func (r HallRow) isDisabled(position int) bool {
+	return containsInt(r.Disabled, position)
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the layout in a JSONB column
+func (l HallLayout) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+// Scan reads the layout from a JSONB column
+func (l *HallLayout) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, l)
+	case string:
+		return json.Unmarshal([]byte(data), l)
+	default:
+		return fmt.Errorf("cannot scan %T into a hall layout", src)
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestHallLayoutSeats(t *testing.T) {
+	layout := HallLayout{Rows: []HallRow{
+		{Label: "A", Seats: 3},
+		{Label: "B", Seats: 4, Aisles: []int{2}, Disabled: []int{4}},
+	}}
+
+	assert.NoError(t, layout.Validate())
+	assert.Equal(t, []string{"A1", "A2", "A3", "B1", "B3", "B4"}, layout.Seats())
+	assert.Equal(t, 5, layout.Capacity())
+
+	assert.True(t, layout.IsBookable("A1"))
+	assert.True(t, layout.HasSeat("B4"))
+	assert.False(t, layout.IsBookable("B4"))
+	assert.False(t, layout.HasSeat("B2"))
+	assert.False(t, layout.HasSeat("A4"))
+	assert.False(t, layout.HasSeat("A0"))
+	assert.False(t, layout.HasSeat("C1"))
+	assert.False(t, layout.HasSeat("a1"))
+	// the same seat under another label would be booked twice
+	assert.False(t, layout.HasSeat("A01"))
+	assert.False(t, layout.IsBookable("A001"))
+	assert.Empty(t, layout.SeatCategory("A01"))
+}
+
+func TestHallLayoutValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout HallLayout
+		err    string
+	}{
+		{"No Rows", HallLayout{}, "at least one row"},
+		{"Invalid Label", HallLayout{Rows: []HallRow{{Label: "1", Seats: 5}}}, "capital letters"},
+		{"Duplicate Row", HallLayout{Rows: []HallRow{{Label: "A", Seats: 5}, {Label: "A", Seats: 5}}}, "defined twice"},
+		{"Empty Row", HallLayout{Rows: []HallRow{{Label: "A"}}}, "at least one seat"},
+		{"Long Row", HallLayout{Rows: []HallRow{{Label: "A", Seats: 2000000000}}}, "at most 100 seats"},
+		{"Too Many Rows", HallLayout{Rows: make([]HallRow, MaxRows+1)}, "at most 100 rows"},
+		{"Aisle Outside Row", HallLayout{Rows: []HallRow{{Label: "A", Seats: 5, Aisles: []int{6}}}}, "outside of row A"},
+		{"Disabled Aisle", HallLayout{Rows: []HallRow{{Label: "A", Seats: 5, Aisles: []int{2}, Disabled: []int{2}}}}, "cannot be disabled"},
+		{"Invalid Category", HallLayout{Rows: []HallRow{{Label: "A", Seats: 5, Category: "VIP seats"}}}, "lower case identifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.layout.Validate(), tt.err)
+		})
+	}
+}
+
+func TestHallShowtimeEnd(t *testing.T) {
+	hall := Hall{TrailerMinutes: 20, CleaningMinutes: 10}
+	start := time.Date(2024, 5, 30, 20, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, 5, 30, 22, 30, 0, 0, time.UTC), hall.ShowtimeEnd(start, 120))
+}
+
+func TestHallLayoutScan(t *testing.T) {
+	layout := HallLayout{Rows: []HallRow{{Label: "A", Seats: 3, Disabled: []int{1}}}}
+	value, err := layout.Value()
+	assert.NoError(t, err)
+
+	var scanned HallLayout
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, layout, scanned)
+	assert.Error(t, scanned.Scan(42))
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Hold reserves seats of a showtime for a user until ExpiresAt, confirming
+// the hold turns its seats into bookings
+type Hold struct {
+	HoldID     int       `db:"hold_id" json:"hold_id"`
+	UserID     int       `db:"user_id" json:"user_id"`
+	ShowtimeID int       `db:"showtime_id" json:"showtime_id"`
+	Seats      []string  `db:"-" json:"seats"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`
+}
+
+type HoldInput struct {
+	Seats []string `json:"seats" binding:"required,min=1,dive,required"`
+}
+
+// Expired reports whether the hold no longer reserves its seats at now
+func (h Hold) Expired(now time.Time) bool {
+	return !now.Before(h.ExpiresAt)
+}
+
+type ConfirmHoldInput struct {
+	// TicketTypes maps seats to their ticket type, the other seats are sold
+	// as DefaultTicketType
+	TicketTypes map[string]string `json:"ticket_types,omitempty"`
+}
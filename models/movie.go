@@ -5,10 +5,12 @@ type Movie struct {
 	Title    string `db:"title" json:"title"`
 	Duration int    `db:"duration" json:"duration"`
 	Genre    string `db:"genre" json:"genre"`
+	// Version counts the changes of the movie, it is its ETag
+	Version int `db:"version" json:"version"`
 }
 
 type MovieInput struct {
-	Title    string `json:"title" binding:"required"`
-	Duration int    `json:"duration" binding:"required"`
-	Genre    string `json:"genre" binding:"required"`
+	Title    string `json:"title" binding:"required,max=100"`
+	Duration int    `json:"duration" binding:"required,min=1,max=1440"`
+	Genre    string `json:"genre" binding:"required,max=50"`
 }
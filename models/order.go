@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// OrderStatus is the state of the payment of an order
+type OrderStatus string
+
+const (
+	// OrderPending orders reserve their seats until they are paid or expire
+	OrderPending OrderStatus = "pending"
+	OrderPaid    OrderStatus = "paid"
+	// OrderFailed orders were declined or expired, their seats are released
+	OrderFailed OrderStatus = "failed"
+	// OrderRefunded orders were paid back, their seats are released
+	OrderRefunded OrderStatus = "refunded"
+)
+
+// orderTransitions lists the statuses each status can change to
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending: {OrderPaid, OrderFailed},
+	OrderPaid:    {OrderRefunded},
+}
+
+// CanBecome reports whether an order in status s may change to next
+func (s OrderStatus) CanBecome(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Order groups the bookings made together for one showtime
+type Order struct {
+	OrderID    int         `db:"order_id" json:"order_id"`
+	UserID     int         `db:"user_id" json:"user_id"`
+	ShowtimeID int         `db:"showtime_id" json:"showtime_id"`
+	CreatedAt  time.Time   `db:"created_at" json:"created_at"`
+	Status     OrderStatus `db:"status" json:"status"`
+	// Amount is the total price of the bookings in cents of Currency
+	Amount   int    `db:"amount" json:"amount"`
+	Currency string `db:"currency" json:"currency"`
+	// PaymentID identifies the payment at the provider once it is authorized
+	PaymentID *string `db:"payment_id" json:"payment_id,omitempty"`
+	// ExpiresAt is when a pending order fails if it has not been paid
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	Bookings  []Booking  `db:"-" json:"bookings"`
+}
+
+// Expired reports whether the order is still pending after its expiry
+func (o Order) Expired(now time.Time) bool {
+	return o.Status == OrderPending && o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+type OrderInput struct {
+	// UserID defaults to the caller, only staff may order for other users
+	UserID     int      `json:"user_id,omitempty"`
+	ShowtimeID int      `json:"showtime_id" binding:"required"`
+	Seats      []string `json:"seats" binding:"required,min=1,dive,required"`
+	// TicketTypes maps seats to their ticket type, the other seats are sold
+	// as DefaultTicketType
+	TicketTypes map[string]string `json:"ticket_types,omitempty"`
+}
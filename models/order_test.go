@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOrderStatusCanBecome(t *testing.T) {
+	assert.True(t, OrderPending.CanBecome(OrderPaid))
+	assert.True(t, OrderPending.CanBecome(OrderFailed))
+	assert.True(t, OrderPaid.CanBecome(OrderRefunded))
+	assert.False(t, OrderPending.CanBecome(OrderRefunded))
+	assert.False(t, OrderPaid.CanBecome(OrderFailed))
+	assert.False(t, OrderFailed.CanBecome(OrderPaid))
+	assert.False(t, OrderRefunded.CanBecome(OrderPaid))
+}
+
+func TestOrderExpired(t *testing.T) {
+	now := time.Now()
+	expiry := now.Add(time.Minute)
+	order := Order{Status: OrderPending, ExpiresAt: &expiry}
+	assert.False(t, order.Expired(now))
+	assert.True(t, order.Expired(expiry))
+
+	order.Status = OrderPaid
+	assert.False(t, order.Expired(expiry))
+}
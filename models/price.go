@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultTicketType is sold for the seats that are booked without a type
+const DefaultTicketType = "adult"
+
+// PriceAdjustment is the amount a pricing rule adds to the price, negative for
+// discounts
+type PriceAdjustment struct {
+	Rule   string `json:"rule"`
+	Amount int    `json:"amount"`
+}
+
+// PriceBreakdown explains the price of a ticket. Amounts are in cents of the
+// currency, the total is the base price plus every adjustment.
+type PriceBreakdown struct {
+	Currency    string            `json:"currency"`
+	Base        int               `json:"base"`
+	Adjustments []PriceAdjustment `json:"adjustments"`
+	Total       int               `json:"total"`
+}
+
+// Value stores the breakdown in a JSONB column
+func (b PriceBreakdown) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan reads the breakdown from a JSONB column
+func (b *PriceBreakdown) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, b)
+	case string:
+		return json.Unmarshal([]byte(data), b)
+	default:
+		return fmt.Errorf("cannot scan %T into a price breakdown", src)
+	}
+}
+
+// TicketQuote is the price of one seat
+type TicketQuote struct {
+	Seat       string         `json:"seat"`
+	TicketType string         `json:"ticket_type"`
+	Category   string         `json:"category"`
+	Price      PriceBreakdown `json:"price"`
+}
+
+// Quote prices a set of seats of a showtime
+type Quote struct {
+	ShowtimeID int           `json:"showtime_id"`
+	Currency   string        `json:"currency"`
+	Tickets    []TicketQuote `json:"tickets"`
+	Total      int           `json:"total"`
+}
+
+type QuoteInput struct {
+	Seats []string `json:"seats" binding:"required,min=1,dive,required"`
+	// TicketTypes maps seats to their ticket type, the other seats are sold
+	// as DefaultTicketType
+	TicketTypes map[string]string `json:"ticket_types,omitempty"`
+}
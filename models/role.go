@@ -0,0 +1,18 @@
+package models
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleStaff    Role = "staff"
+	RoleCustomer Role = "customer"
+)
+
+// Valid reports whether the role is one of the known roles
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleStaff, RoleCustomer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strconv"
+)
+
+type SeatStatus string
+
+const (
+	SeatFree    SeatStatus = "free"
+	SeatHeld    SeatStatus = "held"
+	SeatBooked  SeatStatus = "booked"
+	SeatBlocked SeatStatus = "blocked"
+)
+
+// SeatMap is the state of every seat of the hall of a showtime
+type SeatMap struct {
+	ShowtimeID int `json:"showtime_id"`
+	HallID     int `json:"hall_id"`
+	// Available counts the free seats
+	Available int           `json:"available"`
+	Seats     []SeatMapSeat `json:"seats"`
+}
+
+type SeatMapSeat struct {
+	Label    string     `json:"label"`
+	Row      string     `json:"row"`
+	Position int        `json:"position"`
+	Category string     `json:"category"`
+	Status   SeatStatus `json:"status"`
+}
+
+// NewSeatMap builds the seat map of a showtime from the layout of its hall and
+// the labels of its booked and held seats. Disabled seats are blocked, a seat
+// both booked and held is booked.
+func NewSeatMap(showtimeID int, hall Hall, booked, held []string) SeatMap {
+	taken := map[string]SeatStatus{}
+	for _, label := range held {
+		taken[label] = SeatHeld
+	}
+	for _, label := range booked {
+		taken[label] = SeatBooked
+	}
+
+	seatMap := SeatMap{ShowtimeID: showtimeID, HallID: hall.HallID, Seats: []SeatMapSeat{}}
+	for _, row := range hall.Layout.Rows {
+		for position := 1; position <= row.Seats; position++ {
+			if !row.isSeat(position) {
+				continue
+			}
+			seat := SeatMapSeat{
+				Label:    row.Label + strconv.Itoa(position),
+				Row:      row.Label,
+				Position: position,
+				Category: row.SeatCategory(),
+				Status:   SeatFree,
+			}
+			if row.isDisabled(position) {
+				seat.Status = SeatBlocked
+			} else if status, ok := taken[seat.Label]; ok {
+				seat.Status = status
+			} else {
+				seatMap.Available++
+			}
+			seatMap.Seats = append(seatMap.Seats, seat)
+		}
+	}
+	return seatMap
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewSeatMap(t *testing.T) {
+	hall := Hall{HallID: 2, Layout: HallLayout{Rows: []HallRow{
+		{Label: "A", Seats: 4, Aisles: []int{2}, Disabled: []int{4}, Category: "vip"},
+	}}}
+
+	seatMap := NewSeatMap(7, hall, []string{"A1"}, []string{"A1", "A3"})
+
+	assert.Equal(t, 7, seatMap.ShowtimeID)
+	assert.Equal(t, 2, seatMap.HallID)
+	assert.Equal(t, 0, seatMap.Available)
+	assert.Equal(t, []SeatMapSeat{
+		{Label: "A1", Row: "A", Position: 1, Category: "vip", Status: SeatBooked},
+		{Label: "A3", Row: "A", Position: 3, Category: "vip", Status: SeatHeld},
+		{Label: "A4", Row: "A", Position: 4, Category: "vip", Status: SeatBlocked},
+	}, seatMap.Seats)
+}
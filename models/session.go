@@ -1,6 +1,10 @@
 package models
 
 type Session struct {
-	Token string `json:"token"`
-	TTL   int64  `json:"ttl"` // TTL for session expiration
+	Token  string `db:"token" json:"token"` // session ID, carried as the jti claim of access tokens
+	UserID uint   `db:"user_id" json:"user_id"`
+	// RefreshToken is the SHA-256 of the current refresh token, the token
+	// itself is only known to the client
+	RefreshToken string `db:"refresh_token" json:"refresh_token"`
+	TTL          int64  `db:"ttl" json:"ttl"` // TTL for session expiration
 }
@@ -1,14 +1,67 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
+// ShowtimeLayout is the format clients write showtimes in
+const ShowtimeLayout = "2006-01-02 15:04"
+
+// showtimeLayouts are the formats a showtime is written in by the API and
+// read back from the database
+var showtimeLayouts = []string{ShowtimeLayout, "2006-01-02 15:04:05", time.RFC3339}
+
 type Showtime struct {
 	ShowtimeID int    `db:"showtime_id" json:"showtime_id"`
 	MovieID    int    `db:"movie_id" json:"movie_id"`
 	Showtime   string `db:"showtime" json:"showtime"`
-	Hall       string `db:"hall" json:"hall"`
+	HallID     int    `db:"hall_id" json:"hall_id"`
+	// Version counts the changes of the showtime, it is its ETag
+	Version int `db:"version" json:"version"`
+}
+
+// Start parses the time the showtime starts at
+func (s Showtime) Start() (time.Time, error) {
+	for _, layout := range showtimeLayouts {
+		t, err := time.Parse(layout, s.Showtime)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid showtime %q", s.Showtime)
 }
 
 type ShowtimeInput struct {
-	MovieID  int    `db:"movie_id" json:"movie_id" binding:"required"`
-	Showtime string `db:"showtime" json:"showtime" binding:"required"`
-	Hall     string `db:"hall" json:"hall" binding:"required"`
+	MovieID  int    `db:"movie_id" json:"movie_id" binding:"required,min=1"`
+	Showtime string `db:"showtime" json:"showtime" binding:"required,showtime"`
+	HallID   int    `db:"hall_id" json:"hall_id" binding:"required,min=1"`
+}
+
+// BookingAction is what happened to a booking when its showtime changed
+type BookingAction string
+
+const (
+	// BookingKept keeps the seat, only the showtime changed
+	BookingKept BookingAction = "kept"
+	// BookingRebooked moved the booking to another seat of the new hall
+	BookingRebooked BookingAction = "rebooked"
+	// BookingCancelled cancelled the booking with a full refund, no seat was
+	// left for it
+	BookingCancelled BookingAction = "cancelled"
+)
+
+// BookingChange describes a booking affected by a change of its showtime
+type BookingChange struct {
+	BookingID int           `json:"booking_id"`
+	UserID    int           `json:"user_id"`
+	Seat      string        `json:"seat"`
+	NewSeat   string        `json:"new_seat,omitempty"`
+	Action    BookingAction `json:"action"`
+}
+
+// ShowtimeUpdate is an updated showtime with the bookings the update affected
+type ShowtimeUpdate struct {
+	Showtime
+	AffectedBookings []BookingChange `json:"affected_bookings"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Ticket is the e-ticket of a booking. Its Token is signed, it is shown at
+// the entrance as text or as a QR code.
+type Ticket struct {
+	BookingID   int        `json:"booking_id"`
+	ShowtimeID  int        `json:"showtime_id"`
+	Seat        string     `json:"seat"`
+	Token       string     `json:"token"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+type CheckInInput struct {
+	Token string `json:"token" binding:"required"`
+}
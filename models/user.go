@@ -3,12 +3,27 @@ package models
 type User struct {
 	ID       uint   `db:"user_id" json:"user_id"`
 	Username string `db:"username" json:"username"`
-	Password string `db:"password" json:"password"`
+	Password string `db:"password" json:"-"`
 	Email    string `db:"email" json:"email"`
+	Role     Role   `db:"role" json:"role"`
+	// Version counts the changes of the user, it is its ETag
+	Version int `db:"version" json:"version"`
 }
 
+// UserInput is the body creating or updating a user. Passwords are limited to
+// 72 bytes, which bcrypt refuses to exceed, so fewer characters outside ASCII.
 type UserInput struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Password string `json:"password" binding:"required,min=8,bytes=72"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Role     Role   `json:"role" binding:"omitempty,oneof=admin staff customer"`
+}
+
+// UserPatch is a user as patched by a JSON merge patch: the patch applies to
+// the stored user, whose password is kept unless the patch sets a new one
+type UserPatch struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Password string `json:"password,omitempty" binding:"omitempty,min=8,bytes=72"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Role     Role   `json:"role" binding:"required,oneof=admin staff customer"`
 }
@@ -0,0 +1,301 @@
+// Package openapi describes the API as an OpenAPI 3 document. The routes are
+// listed as operations next to the router, the schemas of their bodies are
+// generated from the model structs: their json tags name the properties and
+// their binding tags tell which ones are required.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/validation"
+)
+
+// BearerAuth is the security scheme of the operations requiring an access
+// token
+const BearerAuth = "bearerAuth"
+
+// Operation documents a route of the API
+type Operation struct {
+	Method string
+	// Path is the path of the route as registered with gin, e.g. /movies/:id
+	Path    string
+	Tag     string
+	Summary string
+	// Public operations are called without an access token
+	Public bool
+	// Query lists the query parameters of the operation
+	Query []string
+	// Form lists the fields of a request sent as a form
+	Form []string
+	// Request is a value of the type of the JSON body of the request, nil
+	// when it has none
+	Request interface{}
+	// Status is the status of a successful response
+	Status int
+	// Response is a value of the type of the JSON body of a successful
+	// response, nil when it has none
+	Response interface{}
+	// ContentType is the media type of a successful response that is not
+	// JSON, e.g. image/png
+	ContentType string
+	// Patch operations take a JSON merge patch of Request, in which no field
+	// is required
+	Patch bool
+	// Versioned operations respond with the ETag of a record, those changing
+	// it require the If-Match header and do so only if the ETag matches it
+	Versioned bool
+}
+
+// Key identifies the operation by its method and the path of its route, as
+// gin lists them
+func (o Operation) Key() string {
+	return o.Method + " " + o.Path
+}
+
+// Document builds the OpenAPI document of the operations
+func Document(title, version string, operations []Operation) (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info:    &openapi3.Info{Title: title, Version: version},
+		Paths:   openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				BearerAuth: {Value: openapi3.NewJWTSecurityScheme()},
+			},
+		},
+	}
+	problem, err := schema(doc, apierror.Problem{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range operations {
+		op := openapi3.NewOperation()
+		op.Tags = []string{o.Tag}
+		op.Summary = o.Summary
+		if !o.Public {
+			op.Security = openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate(BearerAuth))
+		}
+
+		for _, segment := range strings.Split(o.Path, "/") {
+			if name, ok := strings.CutPrefix(segment, ":"); ok {
+				op.AddParameter(openapi3.NewPathParameter(name).WithSchema(openapi3.NewIntegerSchema()))
+			}
+		}
+		for _, name := range o.Query {
+			op.AddParameter(openapi3.NewQueryParameter(name).WithSchema(openapi3.NewStringSchema()))
+		}
+		if o.Versioned && o.Method != http.MethodGet && o.Method != http.MethodPost {
+			op.AddParameter(openapi3.NewHeaderParameter("If-Match").
+				WithDescription("ETag of the record as read or * to overwrite it, the update fails with 412 when it changed since and with 428 without If-Match").
+				WithRequired(true).
+				WithSchema(openapi3.NewStringSchema()))
+		}
+
+		switch {
+		case len(o.Form) > 0:
+			form := openapi3.NewObjectSchema()
+			for _, field := range o.Form {
+				form.WithProperty(field, openapi3.NewStringSchema())
+			}
+			form.Required = o.Form
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithFormDataSchema(form)}
+		case o.Patch:
+			body, err := patchSchema(doc, o.Request)
+			if err != nil {
+				return nil, err
+			}
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).
+				WithContent(openapi3.NewContentWithSchemaRef(body, []string{validation.MergePatchContentType}))}
+		case o.Request != nil:
+			body, err := schema(doc, o.Request)
+			if err != nil {
+				return nil, err
+			}
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(body)}
+		}
+
+		response := openapi3.NewResponse().WithDescription(http.StatusText(o.Status))
+		switch {
+		case o.ContentType != "":
+			response.WithContent(openapi3.NewContentWithSchema(openapi3.NewBytesSchema(), []string{o.ContentType}))
+		case o.Response != nil:
+			body, err := schema(doc, o.Response)
+			if err != nil {
+				return nil, err
+			}
+			response.WithJSONSchemaRef(body)
+		}
+		if o.Versioned {
+			response.Headers = openapi3.Headers{"ETag": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+				Description: "Version of the record, to send back in If-Match",
+				Schema:      openapi3.NewStringSchema().NewRef(),
+			}}}}
+		}
+		// errors are problems whatever their status
+		op.Responses = openapi3.NewResponses(
+			openapi3.WithStatus(o.Status, &openapi3.ResponseRef{Value: response}),
+			openapi3.WithName("default", openapi3.NewResponse().
+				WithDescription("Error").
+				WithContent(openapi3.NewContentWithSchemaRef(problem, []string{apierror.ContentType}))),
+		)
+
+		doc.AddOperation(path(o.Path), o.Method, op)
+	}
+	return doc, nil
+}
+
+// Handler serves the document as JSON
+func Handler(doc *openapi3.T) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// path converts the path of a gin route to an OpenAPI path: /movies/:id is
+// /movies/{id}
+func path(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// schema returns a reference to the component schema of the type of value,
+// which it adds to the components of the document. Slices are arrays of
+// references to the schema of their elements.
+func schema(doc *openapi3.T, value interface{}) (*openapi3.SchemaRef, error) {
+	t := reflect.TypeOf(value)
+	if t.Kind() == reflect.Slice {
+		items, err := schema(doc, reflect.Zero(t.Elem()).Interface())
+		if err != nil {
+			return nil, err
+		}
+		array := openapi3.NewArraySchema()
+		array.Items = items
+		return openapi3.NewSchemaRef("", array), nil
+	}
+
+	name := schemaName(t)
+	if _, ok := doc.Components.Schemas[name]; !ok {
+		ref, err := openapi3gen.NewSchemaRefForValue(value, doc.Components.Schemas, openapi3gen.SchemaCustomizer(bindingRules))
+		if err != nil {
+			return nil, err
+		}
+		doc.Components.Schemas[name] = ref
+	}
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil), nil
+}
+
+// patchSchema returns a reference to the schema of a merge patch of the type
+// of value: the schema of the type without required properties, named after
+// it with a Patch suffix
+func patchSchema(doc *openapi3.T, value interface{}) (*openapi3.SchemaRef, error) {
+	if _, err := schema(doc, value); err != nil {
+		return nil, err
+	}
+	name := schemaName(reflect.TypeOf(value))
+	patch := name + "Patch"
+	if _, ok := doc.Components.Schemas[patch]; !ok {
+		copied := *doc.Components.Schemas[name].Value
+		copied.Required = nil
+		doc.Components.Schemas[patch] = openapi3.NewSchemaRef("", &copied)
+	}
+	return openapi3.NewSchemaRef("#/components/schemas/"+patch, nil), nil
+}
+
+// schemaName names the schema of a type after the type. Instances of generic
+// types, the pages of lists, are named after their type argument: the schema
+// of listing.Response[models.User] is UserList.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if open := strings.Index(name, "["); open >= 0 {
+		arg := strings.TrimSuffix(name[open+1:], "]")
+		return arg[strings.LastIndex(arg, ".")+1:] + "List"
+	}
+	return name
+}
+
+// bindingRules adds the binding rules of the fields of a struct to its
+// schema: required fields, the values of fields limited with oneof, email
+// addresses and the bounds of integers and of the length of strings
+func bindingRules(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if _, ok := rule(field.Tag, "required"); ok {
+				schema.Required = append(schema.Required, jsonName(field))
+			}
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if values, ok := rule(tag, "oneof"); ok {
+			for _, value := range strings.Fields(values) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		}
+		if _, ok := rule(tag, "email"); ok {
+			schema.Format = "email"
+		}
+		if min, ok := bound(tag, "min"); ok {
+			schema.MinLength = uint64(min)
+		}
+		if max, ok := bound(tag, "max"); ok {
+			length := uint64(max)
+			schema.MaxLength = &length
+		}
+	case reflect.Int:
+		if min, ok := bound(tag, "min"); ok {
+			schema.Min = &min
+		}
+		if max, ok := bound(tag, "max"); ok {
+			schema.Max = &max
+		}
+	}
+	return nil
+}
+
+// bound returns the number parameter of the binding rule name of a field
+func bound(tag reflect.StructTag, name string) (float64, bool) {
+	param, ok := rule(tag, name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(param, 64)
+	return n, err == nil
+}
+
+// rule returns the parameter of the binding rule name of a field, the rules
+// following dive apply to the elements of the field and are ignored
+func rule(tag reflect.StructTag, name string) (string, bool) {
+	for _, r := range strings.Split(tag.Get("binding"), ",") {
+		if r == "dive" {
+			break
+		}
+		key, param, _ := strings.Cut(r, "=")
+		if key == name {
+			return param, true
+		}
+	}
+	return "", false
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,83 @@
+package openapi
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type page[T any] struct {
+	Items []T `json:"items"`
+}
+
+type item struct {
+	Name  string `json:"name" binding:"required,min=3,max=20"`
+	Email string `json:"email" binding:"omitempty,email"`
+	Kind  string `json:"kind" binding:"required,oneof=small large"`
+	Count int    `json:"count" binding:"min=1,max=9"`
+	Tags  []int  `json:"tags" binding:"required,dive,min=1"`
+	Note  string `json:"note,omitempty"`
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "/movies/", path("/movies/"))
+	assert.Equal(t, "/bookings/{id}/ticket/qr", path("/bookings/:id/ticket/qr"))
+}
+
+func TestSchemaName(t *testing.T) {
+	assert.Equal(t, "item", schemaName(reflect.TypeOf(item{})))
+	assert.Equal(t, "itemList", schemaName(reflect.TypeOf(page[item]{})))
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := Document("test", "1.0.0", []Operation{
+		{Method: "POST", Path: "/items/:id", Tag: "items", Request: item{}, Status: http.StatusCreated, Response: item{}},
+		{Method: "GET", Path: "/items/", Tag: "items", Public: true, Query: []string{"limit"}, Status: http.StatusOK, Response: []item{}},
+	})
+	require.NoError(t, err)
+
+	schema := doc.Components.Schemas["item"].Value
+	assert.ElementsMatch(t, []string{"name", "kind", "tags"}, schema.Required)
+	assert.Equal(t, []interface{}{"small", "large"}, schema.Properties["kind"].Value.Enum)
+	assert.Equal(t, 1.0, *schema.Properties["count"].Value.Min)
+	assert.Equal(t, 9.0, *schema.Properties["count"].Value.Max)
+	assert.Equal(t, uint64(3), schema.Properties["name"].Value.MinLength)
+	assert.Equal(t, uint64(20), *schema.Properties["name"].Value.MaxLength)
+	assert.Equal(t, "email", schema.Properties["email"].Value.Format)
+	assert.Nil(t, schema.Properties["tags"].Value.Min)
+
+	create := doc.Paths.Find("/items/{id}").Post
+	assert.NotNil(t, create.Parameters.GetByInAndName(openapi3.ParameterInPath, "id"))
+	assert.NotNil(t, create.Security)
+	assert.NotNil(t, create.Responses.Status(http.StatusCreated))
+	assert.NotNil(t, create.Responses.Default())
+
+	list := doc.Paths.Find("/items/").Get
+	assert.Nil(t, list.Security)
+	assert.NotNil(t, list.Parameters.GetByInAndName(openapi3.ParameterInQuery, "limit"))
+	assert.Equal(t, "#/components/schemas/item", list.Responses.Status(http.StatusOK).Value.Content.Get("application/json").Schema.Value.Items.Ref)
+}
+
+func TestPatchDocument(t *testing.T) {
+	doc, err := Document("test", "1.0.0", []Operation{
+		{Method: "PATCH", Path: "/items/:id", Tag: "items", Request: item{}, Patch: true, Status: http.StatusOK, Response: item{}, Versioned: true},
+	})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, doc.Components.Schemas["item"].Value.Required)
+	patch := doc.Components.Schemas["itemPatch"].Value
+	assert.Empty(t, patch.Required)
+	assert.Contains(t, patch.Properties, "name")
+
+	op := doc.Paths.Find("/items/{id}").Patch
+	body := op.RequestBody.Value.Content.Get("application/merge-patch+json")
+	require.NotNil(t, body)
+	assert.Equal(t, "#/components/schemas/itemPatch", body.Schema.Ref)
+	if ifMatch := op.Parameters.GetByInAndName(openapi3.ParameterInHeader, "If-Match"); assert.NotNil(t, ifMatch) {
+		assert.True(t, ifMatch.Required)
+	}
+	assert.Contains(t, op.Responses.Status(http.StatusOK).Value.Headers, "ETag")
+}
@@ -0,0 +1,137 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"one-way-ticket/config"
+)
+
+type fakeStatus string
+
+const (
+	fakeAuthorized fakeStatus = "authorized"
+	fakeCaptured   fakeStatus = "captured"
+)
+
+type fakePayment struct {
+	charge   Charge
+	status   fakeStatus
+	refunded int
+}
+
+// FakeProvider is a payment provider that keeps the payments in memory and
+// takes no money. It declines a configurable share of the payments and can
+// be slowed down to look like a remote provider.
+type FakeProvider struct {
+	cfg    config.FakePaymentsConfig
+	secret string
+
+	mu       sync.Mutex
+	random   *rand.Rand
+	payments map[string]*fakePayment
+	next     int
+}
+
+// NewFakeProvider creates a FakeProvider whose webhooks are signed with secret
+func NewFakeProvider(cfg config.FakePaymentsConfig, secret string) *FakeProvider {
+	return &FakeProvider{
+		cfg:      cfg,
+		secret:   secret,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		payments: map[string]*fakePayment{},
+	}
+}
+
+// wait simulates the latency of a remote provider
+func (p *FakeProvider) wait(ctx context.Context) error {
+	if p.cfg.Latency <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(p.cfg.Latency)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, charge Charge) (string, error) {
+	if err := p.wait(ctx); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.random.Float64() < p.cfg.FailureRate {
+		return "", ErrDeclined
+	}
+	p.next++
+	id := fmt.Sprintf("fake_%d", p.next)
+	p.payments[id] = &fakePayment{charge: charge, status: fakeAuthorized}
+	return id, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, paymentID string) error {
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if payment.status != fakeAuthorized {
+		return ErrInvalidState
+	}
+	payment.status = fakeCaptured
+	return nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, paymentID string, amount int) error {
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if payment.status != fakeCaptured || amount <= 0 || payment.refunded+amount > payment.charge.Amount {
+		return ErrInvalidState
+	}
+	payment.refunded += amount
+	return nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (Event, error) {
+	if !verifySignature(payload, signature, p.secret) {
+		return Event{}, ErrInvalidSignature
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+	return event, nil
+}
+
+// Refunded returns the amount refunded of the payment, for tests
+func (p *FakeProvider) Refunded(paymentID string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if payment, ok := p.payments[paymentID]; ok {
+		return payment.refunded
+	}
+	return 0
+}
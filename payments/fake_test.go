@@ -0,0 +1,56 @@
+package payments
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"one-way-ticket/config"
+	"testing"
+	"time"
+)
+
+func TestFakeProvider(t *testing.T) {
+	provider := NewFakeProvider(config.FakePaymentsConfig{}, "")
+	ctx := context.Background()
+
+	id, err := provider.Authorize(ctx, Charge{OrderID: 1, Amount: 2400, Currency: "EUR"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, provider.Refund(ctx, id, 100), ErrInvalidState)
+
+	assert.NoError(t, provider.Capture(ctx, id))
+	assert.ErrorIs(t, provider.Capture(ctx, id), ErrInvalidState)
+	assert.ErrorIs(t, provider.Capture(ctx, "fake_42"), ErrUnknownPayment)
+
+	assert.NoError(t, provider.Refund(ctx, id, 1000))
+	assert.NoError(t, provider.Refund(ctx, id, 1400))
+	assert.ErrorIs(t, provider.Refund(ctx, id, 1), ErrInvalidState)
+	assert.Equal(t, 2400, provider.Refunded(id))
+}
+
+func TestFakeProviderFailures(t *testing.T) {
+	provider := NewFakeProvider(config.FakePaymentsConfig{FailureRate: 1}, "")
+	_, err := provider.Authorize(context.Background(), Charge{OrderID: 1, Amount: 2400, Currency: "EUR"})
+	assert.ErrorIs(t, err, ErrDeclined)
+
+	provider = NewFakeProvider(config.FakePaymentsConfig{Latency: time.Hour}, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = provider.Authorize(ctx, Charge{OrderID: 1, Amount: 2400, Currency: "EUR"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFakeProviderWebhook(t *testing.T) {
+	payload := []byte(`{"type": "payment.refunded", "payment_id": "fake_1"}`)
+
+	provider := NewFakeProvider(config.FakePaymentsConfig{}, "webhook-secret")
+	event, err := provider.VerifyWebhook(payload, Sign(payload, "webhook-secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, Event{Type: EventRefunded, PaymentID: "fake_1"}, event)
+
+	_, err = provider.VerifyWebhook(payload, Sign(payload, "other-secret"))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// without a secret no webhook is accepted
+	provider = NewFakeProvider(config.FakePaymentsConfig{}, "")
+	_, err = provider.VerifyWebhook(payload, Sign(payload, ""))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,90 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"one-way-ticket/config"
+)
+
+var (
+	// ErrDeclined is returned when the provider refuses the payment
+	ErrDeclined = errors.New("payment declined")
+	// ErrUnknownPayment is returned for a payment the provider does not know
+	ErrUnknownPayment = errors.New("unknown payment")
+	// ErrInvalidState is returned when the payment cannot go through the
+	// operation, e.g. refunding a payment that was never captured
+	ErrInvalidState = errors.New("invalid payment state")
+	// ErrInvalidSignature is returned for webhooks that were not signed by
+	// the provider
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Charge is the amount to take for an order, in cents of the currency
+type Charge struct {
+	OrderID  int
+	Amount   int
+	Currency string
+}
+
+// EventType is what happened to a payment at the provider
+type EventType string
+
+const (
+	EventCaptured EventType = "payment.captured"
+	EventFailed   EventType = "payment.failed"
+	EventRefunded EventType = "payment.refunded"
+)
+
+// Event is the payload of a webhook of the provider
+type Event struct {
+	Type      EventType `json:"type"`
+	PaymentID string    `json:"payment_id"`
+}
+
+// PaymentProvider takes the money of the orders. A payment is authorized
+// first, which reserves the amount, then captured once the seats are sold.
+// The provider reports changes made on its side, e.g. a chargeback, through
+// webhooks.
+type PaymentProvider interface {
+	// Authorize reserves the amount of the charge and returns the ID of the
+	// payment, or ErrDeclined
+	Authorize(ctx context.Context, charge Charge) (string, error)
+	// Capture takes the authorized amount
+	Capture(ctx context.Context, paymentID string) error
+	// Refund pays back amount of a captured payment
+	Refund(ctx context.Context, paymentID string, amount int) error
+	// VerifyWebhook checks the signature of a webhook and decodes its event
+	VerifyWebhook(payload []byte, signature string) (Event, error)
+}
+
+// NewProvider creates the provider selected by the configuration
+func NewProvider(cfg config.PaymentsConfig) (PaymentProvider, error) {
+	switch cfg.Provider {
+	case config.PaymentProviderFake:
+		return NewFakeProvider(cfg.Fake, cfg.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the payload, the signature the
+// providers put in the header of their webhooks
+func Sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature compares the signature in constant time, webhooks are
+// refused when no secret is configured
+func verifySignature(payload []byte, signature, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(payload, secret)), []byte(signature))
+}
@@ -0,0 +1,157 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"one-way-ticket/models"
+)
+
+var (
+	ErrUnknownTicketType = errors.New("unknown ticket type")
+	ErrUnknownCategory   = errors.New("seat category has no price")
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Rules are the prices of the tickets. The price of a ticket starts from the
+// base price, the ticket type and the seat category are applied to it, then
+// the rules of the showtime: matinee, weekend and opening night.
+type Rules struct {
+	// Currency is the ISO 4217 code of the prices
+	Currency string
+	// BasePrice is the price of an adult ticket for a standard seat, in cents
+	BasePrice int
+	// TicketTypes and Categories are percentages of the price, e.g. 60 for
+	// a child ticket costing 60% of an adult one
+	TicketTypes map[string]int
+	Categories  map[string]int
+	// MatineeDiscount is taken off showtimes starting before MatineeBefore,
+	// e.g. "17:00"
+	MatineeBefore   string
+	MatineeDiscount int
+	// WeekendSurcharge is added on Saturdays and Sundays
+	WeekendSurcharge int
+	// OpeningNightSurcharge is added on the first day a movie is shown
+	OpeningNightSurcharge int
+}
+
+// DefaultRules are the prices used when the configuration sets none
+var DefaultRules = Rules{
+	Currency:              "EUR",
+	BasePrice:             1200,
+	TicketTypes:           map[string]int{"adult": 100, "child": 60, "senior": 70, "student": 80},
+	Categories:            map[string]int{"standard": 100, "premium": 130, "vip": 180},
+	MatineeBefore:         "17:00",
+	MatineeDiscount:       20,
+	WeekendSurcharge:      10,
+	OpeningNightSurcharge: 15,
+}
+
+// Showing is what the rules of a showtime depend on
+type Showing struct {
+	Start time.Time
+	// OpeningNight is set when the showtime is on the first day its movie
+	// is shown
+	OpeningNight bool
+}
+
+// Engine prices tickets with a fixed set of rules
+type Engine struct {
+	rules Rules
+	// matinee is the time of day the matinee ends
+	matinee time.Duration
+}
+
+// NewEngine validates the rules and creates a new Engine
+func NewEngine(rules Rules) (*Engine, error) {
+	if !currencyCode.MatchString(rules.Currency) {
+		return nil, fmt.Errorf("invalid currency %q", rules.Currency)
+	}
+	if rules.BasePrice <= 0 {
+		return nil, errors.New("base price must be positive")
+	}
+	if _, ok := rules.TicketTypes[models.DefaultTicketType]; !ok {
+		return nil, fmt.Errorf("ticket type %q needs a price", models.DefaultTicketType)
+	}
+	if _, ok := rules.Categories[models.DefaultSeatCategory]; !ok {
+		return nil, fmt.Errorf("seat category %q needs a price", models.DefaultSeatCategory)
+	}
+	for name, percent := range rules.TicketTypes {
+		if percent <= 0 {
+			return nil, fmt.Errorf("price of ticket type %q must be positive", name)
+		}
+	}
+	for name, percent := range rules.Categories {
+		if percent <= 0 {
+			return nil, fmt.Errorf("price of seat category %q must be positive", name)
+		}
+	}
+	matinee, err := time.Parse("15:04", rules.MatineeBefore)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matinee end %q", rules.MatineeBefore)
+	}
+	if rules.MatineeDiscount < 0 || rules.MatineeDiscount > 100 {
+		return nil, errors.New("matinee discount must be between 0 and 100")
+	}
+	if rules.WeekendSurcharge < 0 || rules.OpeningNightSurcharge < 0 {
+		return nil, errors.New("surcharges must not be negative")
+	}
+	return &Engine{
+		rules:   rules,
+		matinee: clock(matinee),
+	}, nil
+}
+
+// clock returns the time of day of t
+func clock(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// Currency returns the currency of the prices
+func (e *Engine) Currency() string {
+	return e.rules.Currency
+}
+
+// Price returns the price of a ticket of the type for a seat of the category
+// at the showing
+func (e *Engine) Price(showing Showing, ticketType, category string) (models.PriceBreakdown, error) {
+	typePercent, ok := e.rules.TicketTypes[ticketType]
+	if !ok {
+		return models.PriceBreakdown{}, fmt.Errorf("%w: %q", ErrUnknownTicketType, ticketType)
+	}
+	categoryPercent, ok := e.rules.Categories[category]
+	if !ok {
+		return models.PriceBreakdown{}, fmt.Errorf("%w: %q", ErrUnknownCategory, category)
+	}
+
+	price := models.PriceBreakdown{
+		Currency:    e.rules.Currency,
+		Base:        e.rules.BasePrice,
+		Adjustments: []models.PriceAdjustment{},
+		Total:       e.rules.BasePrice,
+	}
+	apply := func(rule string, percent int) {
+		total := (price.Total*percent + 50) / 100
+		if total != price.Total {
+			price.Adjustments = append(price.Adjustments, models.PriceAdjustment{Rule: rule, Amount: total - price.Total})
+			price.Total = total
+		}
+	}
+
+	apply("ticket_type:"+ticketType, typePercent)
+	apply("category:"+category, categoryPercent)
+	start := showing.Start
+	if clock(start) < e.matinee {
+		apply("matinee", 100-e.rules.MatineeDiscount)
+	}
+	if start.Weekday() == time.Saturday || start.Weekday() == time.Sunday {
+		apply("weekend", 100+e.rules.WeekendSurcharge)
+	}
+	if showing.OpeningNight {
+		apply("opening_night", 100+e.rules.OpeningNightSurcharge)
+	}
+	return price, nil
+}
@@ -0,0 +1,80 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"one-way-ticket/models"
+)
+
+func TestPrice(t *testing.T) {
+	engine, err := NewEngine(DefaultRules)
+	assert.NoError(t, err)
+
+	// 2024-05-30 is a Thursday
+	evening := Showing{Start: time.Date(2024, 5, 30, 20, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name        string
+		showing     Showing
+		ticketType  string
+		category    string
+		adjustments []models.PriceAdjustment
+		total       int
+	}{
+		{"Adult Standard", evening, "adult", "standard", []models.PriceAdjustment{}, 1200},
+		{"Child Premium", evening, "child", "premium", []models.PriceAdjustment{
+			{Rule: "ticket_type:child", Amount: -480},
+			{Rule: "category:premium", Amount: 216},
+		}, 936},
+		{"Matinee", Showing{Start: time.Date(2024, 5, 30, 16, 59, 0, 0, time.UTC)}, "adult", "standard", []models.PriceAdjustment{
+			{Rule: "matinee", Amount: -240},
+		}, 960},
+		{"Weekend Opening Night", Showing{Start: time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC), OpeningNight: true}, "student", "vip", []models.PriceAdjustment{
+			{Rule: "ticket_type:student", Amount: -240},
+			{Rule: "category:vip", Amount: 768},
+			{Rule: "weekend", Amount: 173},
+			{Rule: "opening_night", Amount: 285},
+		}, 2186},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := engine.Price(tt.showing, tt.ticketType, tt.category)
+			assert.NoError(t, err)
+			assert.Equal(t, "EUR", price.Currency)
+			assert.Equal(t, 1200, price.Base)
+			assert.Equal(t, tt.adjustments, price.Adjustments)
+			assert.Equal(t, tt.total, price.Total)
+		})
+	}
+
+	_, err = engine.Price(evening, "pensioner", "standard")
+	assert.ErrorIs(t, err, ErrUnknownTicketType)
+	_, err = engine.Price(evening, "adult", "balcony")
+	assert.ErrorIs(t, err, ErrUnknownCategory)
+}
+
+func TestNewEngineInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rules *Rules)
+	}{
+		{"Invalid Currency", func(rules *Rules) { rules.Currency = "euro" }},
+		{"Free Tickets", func(rules *Rules) { rules.BasePrice = 0 }},
+		{"Missing Adult", func(rules *Rules) { rules.TicketTypes = map[string]int{"child": 60} }},
+		{"Missing Standard", func(rules *Rules) { rules.Categories = map[string]int{"vip": 180} }},
+		{"Negative Category", func(rules *Rules) { rules.Categories = map[string]int{"standard": 100, "vip": -1} }},
+		{"Invalid Matinee", func(rules *Rules) { rules.MatineeBefore = "5pm" }},
+		{"Discount Over 100", func(rules *Rules) { rules.MatineeDiscount = 120 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := DefaultRules
+			tt.modify(&rules)
+			_, err := NewEngine(rules)
+			assert.Error(t, err)
+		})
+	}
+}
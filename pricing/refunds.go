@@ -0,0 +1,45 @@
+package pricing
+
+import (
+	"errors"
+	"time"
+)
+
+// RefundPolicy decides how much of its price a cancelled booking gets back.
+// Cancelling at least FullRefundBefore the start of the showtime refunds the
+// whole price, cancelling later PartialRefund percent of it, and nothing is
+// refunded once the showtime has started.
+type RefundPolicy struct {
+	FullRefundBefore time.Duration
+	PartialRefund    int
+}
+
+// DefaultRefundPolicy is used when the configuration sets none
+var DefaultRefundPolicy = RefundPolicy{
+	FullRefundBefore: 24 * time.Hour,
+	PartialRefund:    50,
+}
+
+// Validate checks that the policy can be applied
+func (p RefundPolicy) Validate() error {
+	if p.FullRefundBefore < 0 {
+		return errors.New("full refund period must not be negative")
+	}
+	if p.PartialRefund < 0 || p.PartialRefund > 100 {
+		return errors.New("partial refund must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// Refund returns the amount refunded for a ticket of price for a showtime
+// starting at start, cancelled at now
+func (p RefundPolicy) Refund(price int, start, now time.Time) int {
+	switch {
+	case !now.Before(start):
+		return 0
+	case start.Sub(now) >= p.FullRefundBefore:
+		return price
+	default:
+		return (price*p.PartialRefund + 50) / 100
+	}
+}
@@ -0,0 +1,37 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefund(t *testing.T) {
+	start := time.Date(2024, 5, 30, 20, 0, 0, 0, time.UTC)
+	policy := RefundPolicy{FullRefundBefore: 24 * time.Hour, PartialRefund: 50}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		refund int
+	}{
+		{"Days Before", start.Add(-72 * time.Hour), 1205},
+		{"Full Refund Limit", start.Add(-24 * time.Hour), 1205},
+		{"Hours Before", start.Add(-2 * time.Hour), 603},
+		{"Started", start, 0},
+		{"Over", start.Add(3 * time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.refund, policy.Refund(1205, start, tt.now))
+		})
+	}
+}
+
+func TestRefundPolicyValidate(t *testing.T) {
+	assert.NoError(t, DefaultRefundPolicy.Validate())
+	assert.Error(t, RefundPolicy{FullRefundBefore: -time.Hour}.Validate())
+	assert.Error(t, RefundPolicy{PartialRefund: 101}.Validate())
+}
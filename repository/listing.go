@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"one-way-ticket/models"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not issued by a page
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FilterOp is how a filter compares a field with its value
+type FilterOp string
+
+const (
+	OpEqual FilterOp = "="
+	// OpFrom keeps the records whose field is at least the value
+	OpFrom FilterOp = ">="
+	// OpBefore keeps the records whose field is less than the value
+	OpBefore FilterOp = "<"
+)
+
+// Filter keeps the records whose field compares with value by op. The value
+// is an int, a string or a time.Time like the field.
+type Filter struct {
+	Field string
+	Op    FilterOp
+	Value interface{}
+}
+
+// Cursor marks the last record of a page by its sort value and its ID, the
+// next page starts after it. It is only valid for the sort field and the
+// order it was issued for.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// NewCursor creates the cursor of a record whose field sort has the value
+// value, in the descending order when desc is set
+func NewCursor(sort string, desc bool, value interface{}, id int) Cursor {
+	cursor := Cursor{Sort: sort, Desc: desc, ID: id}
+	switch v := value.(type) {
+	case int:
+		cursor.Value = strconv.Itoa(v)
+	case time.Time:
+		cursor.Value = v.UTC().Format(time.RFC3339Nano)
+	default:
+		cursor.Value = fmt.Sprint(v)
+	}
+	return cursor
+}
+
+// Encode returns the cursor as an opaque URL safe string
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor written by Encode
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// ListQuery selects a page of records
+type ListQuery struct {
+	Filters []Filter
+	// Sort is the field the records are ordered by, ties are ordered by ID
+	Sort string
+	Desc bool
+	// After is the cursor of the last record of the previous page, nil for
+	// the first page
+	After *Cursor
+	Limit int
+	// Count asks for the number of records matching the filters, which
+	// costs a second scan of them
+	Count bool
+}
+
+// Page is a page of records with the cursor of the next one
+type Page[T any] struct {
+	Items []T
+	// Next is nil on the last page
+	Next *Cursor
+	// Total is the number of records matching the filters when the query
+	// asked for it
+	Total *int
+}
+
+// Fields maps the fields records can be filtered and sorted by, named after
+// their columns, to their value in a record
+type Fields[T any] map[string]func(T) interface{}
+
+var UserFields = Fields[models.User]{
+	"user_id":  func(u models.User) interface{} { return int(u.ID) },
+	"username": func(u models.User) interface{} { return u.Username },
+	"email":    func(u models.User) interface{} { return u.Email },
+	"role":     func(u models.User) interface{} { return string(u.Role) },
+}
+
+var MovieFields = Fields[models.Movie]{
+	"movie_id": func(m models.Movie) interface{} { return m.MovieID },
+	"title":    func(m models.Movie) interface{} { return m.Title },
+	"duration": func(m models.Movie) interface{} { return m.Duration },
+	"genre":    func(m models.Movie) interface{} { return m.Genre },
+}
+
+var HallFields = Fields[models.Hall]{
+	"hall_id":  func(h models.Hall) interface{} { return h.HallID },
+	"name":     func(h models.Hall) interface{} { return h.Name },
+	"capacity": func(h models.Hall) interface{} { return h.Capacity },
+}
+
+var ShowtimeFields = Fields[models.Showtime]{
+	"showtime_id": func(s models.Showtime) interface{} { return s.ShowtimeID },
+	"movie_id":    func(s models.Showtime) interface{} { return s.MovieID },
+	"hall_id":     func(s models.Showtime) interface{} { return s.HallID },
+	"showtime": func(s models.Showtime) interface{} {
+		start, _ := s.Start()
+		return start
+	},
+}
+
+var BookingFields = Fields[models.Booking]{
+	"booking_id":  func(b models.Booking) interface{} { return b.BookingID },
+	"user_id":     func(b models.Booking) interface{} { return b.UserID },
+	"showtime_id": func(b models.Booking) interface{} { return b.ShowtimeID },
+	"seat":        func(b models.Booking) interface{} { return b.Seat },
+	"status":      func(b models.Booking) interface{} { return string(b.Status) },
+	"price":       func(b models.Booking) interface{} { return b.Price },
+}
+
+var OrderFields = Fields[models.Order]{
+	"order_id":    func(o models.Order) interface{} { return o.OrderID },
+	"user_id":     func(o models.Order) interface{} { return o.UserID },
+	"showtime_id": func(o models.Order) interface{} { return o.ShowtimeID },
+	"status":      func(o models.Order) interface{} { return string(o.Status) },
+	"amount":      func(o models.Order) interface{} { return o.Amount },
+	"created_at":  func(o models.Order) interface{} { return o.CreatedAt },
+}
+
+// Check returns an error when the query uses a field that is not one of
+// fields, so that field names can be trusted as column names
+func (f Fields[T]) Check(query ListQuery) error {
+	if _, ok := f[query.Sort]; !ok {
+		return fmt.Errorf("unknown sort field %q", query.Sort)
+	}
+	if query.After != nil && query.After.Sort != query.Sort {
+		return ErrInvalidCursor
+	}
+	for _, filter := range query.Filters {
+		if _, ok := f[filter.Field]; !ok {
+			return fmt.Errorf("unknown filter field %q", filter.Field)
+		}
+		switch filter.Op {
+		case OpEqual, OpFrom, OpBefore:
+		default:
+			return fmt.Errorf("unknown filter operator %q", filter.Op)
+		}
+	}
+	return nil
+}
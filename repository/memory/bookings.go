@@ -0,0 +1,208 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type BookingRepository struct {
+	store *Store
+}
+
+func (r *BookingRepository) List() ([]models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	bookings := make([]models.Booking, 0, len(r.store.state.bookings))
+	for _, booking := range r.store.state.bookings {
+		bookings = append(bookings, booking)
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].BookingID < bookings[j].BookingID })
+	return bookings, nil
+}
+
+func (r *BookingRepository) ListPage(query repository.ListQuery) (repository.Page[models.Booking], error) {
+	bookings, _ := r.List()
+	return listPage(bookings, "booking_id", repository.BookingFields, query)
+}
+
+func (r *BookingRepository) Get(id int) (models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.state.bookings[id]
+	if !ok {
+		return models.Booking{}, repository.ErrNotFound
+	}
+	return booking, nil
+}
+
+func (r *BookingRepository) ListForShowtime(showtimeID int) ([]models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	bookings := []models.Booking{}
+	for _, booking := range r.store.state.bookings {
+		if booking.ShowtimeID == showtimeID && booking.Status == models.BookingStatusActive {
+			bookings = append(bookings, booking)
+		}
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].BookingID < bookings[j].BookingID })
+	return bookings, nil
+}
+
+func (r *BookingRepository) CountForSeat(showtimeID int, seat string, excludeID int) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.countForSeat(showtimeID, seat, excludeID), nil
+}
+
+func (r *BookingRepository) Create(booking *models.Booking) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.check(*booking); err != nil {
+		return err
+	}
+	booking.Status = models.BookingStatusActive
+	booking.Version = 1
+	booking.BookingID = r.store.state.nextBook
+	r.store.state.nextBook++
+	r.store.state.bookings[booking.BookingID] = *booking
+	return nil
+}
+
+func (r *BookingRepository) Update(booking *models.Booking) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.state.bookings[booking.BookingID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if existing.Version != booking.Version {
+		return repository.ErrConflict
+	}
+	updated := *booking
+	updated.OrderID = existing.OrderID
+	updated.PaidAmount = existing.PaidAmount
+	updated.Status = existing.Status
+	updated.CancelledAt = existing.CancelledAt
+	updated.CancellationReason = existing.CancellationReason
+	updated.RefundAmount = existing.RefundAmount
+	updated.CheckedInAt = existing.CheckedInAt
+	if err := r.check(updated); err != nil {
+		return err
+	}
+	updated.Version++
+	r.store.state.bookings[booking.BookingID] = updated
+	booking.Version = updated.Version
+	return nil
+}
+
+func (r *BookingRepository) Cancel(id int, reason string, refund int, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.state.bookings[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if booking.Status != models.BookingStatusActive {
+		return repository.ErrConflict
+	}
+	booking.Status = models.BookingStatusCancelled
+	booking.CancelledAt = &at
+	booking.CancellationReason = reason
+	booking.RefundAmount = &refund
+	booking.Version++
+	r.store.state.bookings[id] = booking
+	return nil
+}
+
+func (r *BookingRepository) CheckIn(id int, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.state.bookings[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if booking.Status != models.BookingStatusActive || booking.CheckedInAt != nil {
+		return repository.ErrConflict
+	}
+	booking.CheckedInAt = &at
+	booking.Version++
+	r.store.state.bookings[id] = booking
+	return nil
+}
+
+func (r *BookingRepository) Delete(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.bookings[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.store.state.bookings, id)
+	return nil
+}
+
+func (r *BookingRepository) DeleteForOrder(orderID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, booking := range r.store.state.bookings {
+		if booking.OrderID != nil && *booking.OrderID == orderID {
+			delete(r.store.state.bookings, id)
+		}
+	}
+	return nil
+}
+
+func (r *BookingRepository) RecordPayment(orderID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, booking := range r.store.state.bookings {
+		if booking.OrderID != nil && *booking.OrderID == orderID && booking.Status == models.BookingStatusActive {
+			paid := booking.Price
+			booking.PaidAmount = &paid
+			booking.Version++
+			r.store.state.bookings[id] = booking
+		}
+	}
+	return nil
+}
+
+func (r *BookingRepository) check(booking models.Booking) error {
+	if _, ok := r.store.state.users[booking.UserID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if _, ok := r.store.state.showtimes[booking.ShowtimeID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if booking.OrderID != nil {
+		if _, ok := r.store.state.orders[*booking.OrderID]; !ok {
+			return repository.ErrInvalidReference
+		}
+	}
+	if r.countForSeat(booking.ShowtimeID, booking.Seat, booking.BookingID) > 0 {
+		return repository.ErrDuplicate
+	}
+	return nil
+}
+
+func (r *BookingRepository) countForSeat(showtimeID int, seat string, excludeID int) int {
+	count := 0
+	for _, booking := range r.store.state.bookings {
+		if booking.ShowtimeID == showtimeID && booking.Seat == seat && booking.BookingID != excludeID && booking.Status == models.BookingStatusActive {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"sort"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type HallRepository struct {
+	store *Store
+}
+
+func (r *HallRepository) List() ([]models.Hall, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	halls := make([]models.Hall, 0, len(r.store.state.halls))
+	for _, hall := range r.store.state.halls {
+		halls = append(halls, hall)
+	}
+	sort.Slice(halls, func(i, j int) bool { return halls[i].HallID < halls[j].HallID })
+	return halls, nil
+}
+
+func (r *HallRepository) ListPage(query repository.ListQuery) (repository.Page[models.Hall], error) {
+	halls, _ := r.List()
+	return listPage(halls, "hall_id", repository.HallFields, query)
+}
+
+func (r *HallRepository) Get(id int) (models.Hall, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	hall, ok := r.store.state.halls[id]
+	if !ok {
+		return models.Hall{}, repository.ErrNotFound
+	}
+	return hall, nil
+}
+
+// Lock only checks that the hall exists, transactions of the memory store are
+// already serialized
+func (r *HallRepository) Lock(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.halls[id]; !ok {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *HallRepository) Create(hall *models.Hall) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.nameTaken(hall.Name, 0) {
+		return repository.ErrDuplicate
+	}
+	hall.HallID = r.store.state.nextHall
+	hall.Version = 1
+	r.store.state.nextHall++
+	r.store.state.halls[hall.HallID] = *hall
+	return nil
+}
+
+func (r *HallRepository) Update(hall *models.Hall) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.state.halls[hall.HallID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if existing.Version != hall.Version {
+		return repository.ErrConflict
+	}
+	if r.nameTaken(hall.Name, hall.HallID) {
+		return repository.ErrDuplicate
+	}
+	hall.Version++
+	r.store.state.halls[hall.HallID] = *hall
+	return nil
+}
+
+func (r *HallRepository) Delete(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.halls[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, showtime := range r.store.state.showtimes {
+		if showtime.HallID == id {
+			return repository.ErrInvalidReference
+		}
+	}
+	delete(r.store.state.halls, id)
+	return nil
+}
+
+func (r *HallRepository) nameTaken(name string, exceptID int) bool {
+	for _, hall := range r.store.state.halls {
+		if hall.Name == name && hall.HallID != exceptID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type HoldRepository struct {
+	store *Store
+}
+
+func (r *HoldRepository) Get(id int) (models.Hold, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	hold, ok := r.store.state.holds[id]
+	if !ok {
+		return models.Hold{}, repository.ErrNotFound
+	}
+	return hold, nil
+}
+
+func (r *HoldRepository) IsHeld(showtimeID int, seat string, now time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, hold := range r.store.state.holds {
+		if hold.ShowtimeID == showtimeID && !hold.Expired(now) && containsString(hold.Seats, seat) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *HoldRepository) Create(hold *models.Hold) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if len(hold.Seats) == 0 {
+		return errors.New("a hold needs at least one seat")
+	}
+	if _, ok := r.store.state.users[hold.UserID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if _, ok := r.store.state.showtimes[hold.ShowtimeID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	for i, seat := range hold.Seats {
+		if containsString(hold.Seats[:i], seat) {
+			return repository.ErrDuplicate
+		}
+		for _, other := range r.store.state.holds {
+			if other.ShowtimeID == hold.ShowtimeID && containsString(other.Seats, seat) {
+				return repository.ErrDuplicate
+			}
+		}
+	}
+
+	hold.HoldID = r.store.state.nextHold
+	r.store.state.nextHold++
+	hold.Seats = append([]string{}, hold.Seats...)
+	r.store.state.holds[hold.HoldID] = *hold
+	return nil
+}
+
+func (r *HoldRepository) Delete(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.state.holds, id)
+	return nil
+}
+
+func (r *HoldRepository) DeleteForShowtime(showtimeID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, hold := range r.store.state.holds {
+		if hold.ShowtimeID == showtimeID {
+			delete(r.store.state.holds, id)
+		}
+	}
+	return nil
+}
+
+func (r *HoldRepository) DeleteExpired(now time.Time) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deleted := 0
+	for id, hold := range r.store.state.holds {
+		if hold.Expired(now) {
+			delete(r.store.state.holds, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
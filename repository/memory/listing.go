@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"one-way-ticket/repository"
+)
+
+// listPage selects a page of records like the Postgres store does: filtered,
+// ordered by the sort field then by the ID field idField, and starting after
+// the cursor
+func listPage[T any](records []T, idField string, fields repository.Fields[T], query repository.ListQuery) (repository.Page[T], error) {
+	page := repository.Page[T]{Items: []T{}}
+	if err := fields.Check(query); err != nil {
+		return page, err
+	}
+	if query.Limit <= 0 {
+		return page, fmt.Errorf("invalid page size %d", query.Limit)
+	}
+
+	sortValue, id := fields[query.Sort], fields[idField]
+	var after interface{}
+	if query.After != nil {
+		// the zero record gives the type of the sort value
+		var zero T
+		var err error
+		after, err = parseValue(sortValue(zero), query.After.Value)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	var matching []T
+	for _, record := range records {
+		ok, err := matches(record, fields, query.Filters)
+		if err != nil {
+			return page, err
+		}
+		if ok {
+			matching = append(matching, record)
+		}
+	}
+	if query.Count {
+		total := len(matching)
+		page.Total = &total
+	}
+
+	// less orders the records by sort value then ID, both descending when
+	// the query asks for it
+	less := func(a, b T) bool {
+		c, _ := compare(sortValue(a), sortValue(b))
+		if c == 0 {
+			c = id(a).(int) - id(b).(int)
+		}
+		if query.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return less(matching[i], matching[j]) })
+
+	for _, record := range matching {
+		if after != nil {
+			c, err := compare(sortValue(record), after)
+			if err != nil {
+				return page, err
+			}
+			if c == 0 {
+				c = id(record).(int) - query.After.ID
+			}
+			if query.Desc {
+				c = -c
+			}
+			if c <= 0 {
+				continue
+			}
+		}
+		if len(page.Items) == query.Limit {
+			last := page.Items[query.Limit-1]
+			cursor := repository.NewCursor(query.Sort, query.Desc, sortValue(last), id(last).(int))
+			page.Next = &cursor
+			break
+		}
+		page.Items = append(page.Items, record)
+	}
+	return page, nil
+}
+
+func matches[T any](record T, fields repository.Fields[T], filters []repository.Filter) (bool, error) {
+	for _, filter := range filters {
+		c, err := compare(fields[filter.Field](record), filter.Value)
+		if err != nil {
+			return false, fmt.Errorf("filter %s: %w", filter.Field, err)
+		}
+		switch {
+		case filter.Op == repository.OpEqual && c != 0,
+			filter.Op == repository.OpFrom && c < 0,
+			filter.Op == repository.OpBefore && c >= 0:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// compare compares two values of the same type, an int, a string or a
+// time.Time
+func compare(a, b interface{}) (int, error) {
+	switch a := a.(type) {
+	case int:
+		if b, ok := b.(int); ok {
+			return a - b, nil
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// parseValue reads the sort value of a cursor, of the type of like
+func parseValue(like interface{}, s string) (interface{}, error) {
+	switch like.(type) {
+	case int:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, repository.ErrInvalidCursor
+		}
+		return v, nil
+	case time.Time:
+		v, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, repository.ErrInvalidCursor
+		}
+		return v, nil
+	default:
+		return s, nil
+	}
+}
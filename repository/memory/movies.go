@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"sort"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type MovieRepository struct {
+	store *Store
+}
+
+func (r *MovieRepository) List() ([]models.Movie, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	movies := make([]models.Movie, 0, len(r.store.state.movies))
+	for _, movie := range r.store.state.movies {
+		movies = append(movies, movie)
+	}
+	sort.Slice(movies, func(i, j int) bool { return movies[i].MovieID < movies[j].MovieID })
+	return movies, nil
+}
+
+func (r *MovieRepository) ListPage(query repository.ListQuery) (repository.Page[models.Movie], error) {
+	movies, _ := r.List()
+	return listPage(movies, "movie_id", repository.MovieFields, query)
+}
+
+func (r *MovieRepository) Get(id int) (models.Movie, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	movie, ok := r.store.state.movies[id]
+	if !ok {
+		return models.Movie{}, repository.ErrNotFound
+	}
+	return movie, nil
+}
+
+func (r *MovieRepository) Create(movie *models.Movie) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	movie.MovieID = r.store.state.nextMovie
+	movie.Version = 1
+	r.store.state.nextMovie++
+	r.store.state.movies[movie.MovieID] = *movie
+	return nil
+}
+
+func (r *MovieRepository) Update(movie *models.Movie) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.state.movies[movie.MovieID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if existing.Version != movie.Version {
+		return repository.ErrConflict
+	}
+	movie.Version++
+	r.store.state.movies[movie.MovieID] = *movie
+	return nil
+}
+
+func (r *MovieRepository) Delete(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.movies[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, showtime := range r.store.state.showtimes {
+		if showtime.MovieID == id {
+			return repository.ErrInvalidReference
+		}
+	}
+	delete(r.store.state.movies, id)
+	return nil
+}
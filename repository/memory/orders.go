@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type OrderRepository struct {
+	store *Store
+}
+
+func (r *OrderRepository) List() ([]models.Order, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	orders := make([]models.Order, 0, len(r.store.state.orders))
+	for _, order := range r.store.state.orders {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	return orders, nil
+}
+
+func (r *OrderRepository) ListPage(query repository.ListQuery) (repository.Page[models.Order], error) {
+	orders, _ := r.List()
+	return listPage(orders, "order_id", repository.OrderFields, query)
+}
+
+func (r *OrderRepository) Get(id int) (models.Order, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	order, ok := r.store.state.orders[id]
+	if !ok {
+		return models.Order{}, repository.ErrNotFound
+	}
+	for _, booking := range r.store.state.bookings {
+		if booking.OrderID != nil && *booking.OrderID == id {
+			order.Bookings = append(order.Bookings, booking)
+		}
+	}
+	sort.Slice(order.Bookings, func(i, j int) bool { return order.Bookings[i].BookingID < order.Bookings[j].BookingID })
+	return order, nil
+}
+
+func (r *OrderRepository) GetByPayment(paymentID string) (models.Order, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, order := range r.store.state.orders {
+		if order.PaymentID != nil && *order.PaymentID == paymentID {
+			return order, nil
+		}
+	}
+	return models.Order{}, repository.ErrNotFound
+}
+
+func (r *OrderRepository) ListExpired(now time.Time) ([]models.Order, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var orders []models.Order
+	for _, order := range r.store.state.orders {
+		if order.Expired(now) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	return orders, nil
+}
+
+func (r *OrderRepository) Create(order *models.Order) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.users[order.UserID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if _, ok := r.store.state.showtimes[order.ShowtimeID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	order.OrderID = r.store.state.nextOrder
+	r.store.state.nextOrder++
+	order.CreatedAt = time.Now()
+	stored := *order
+	stored.Bookings = nil
+	r.store.state.orders[order.OrderID] = stored
+	return nil
+}
+
+func (r *OrderRepository) Transition(id int, from, to models.OrderStatus, paymentID *string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	order, ok := r.store.state.orders[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if order.Status != from {
+		return repository.ErrConflict
+	}
+	if paymentID != nil {
+		for _, other := range r.store.state.orders {
+			if other.OrderID != id && other.PaymentID != nil && *other.PaymentID == *paymentID {
+				return repository.ErrDuplicate
+			}
+		}
+		payment := *paymentID
+		order.PaymentID = &payment
+	}
+	order.Status = to
+	r.store.state.orders[id] = order
+	return nil
+}
@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type ShowtimeRepository struct {
+	store *Store
+}
+
+func (r *ShowtimeRepository) List() ([]models.Showtime, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	showtimes := make([]models.Showtime, 0, len(r.store.state.showtimes))
+	for _, showtime := range r.store.state.showtimes {
+		showtimes = append(showtimes, showtime)
+	}
+	sort.Slice(showtimes, func(i, j int) bool { return showtimes[i].ShowtimeID < showtimes[j].ShowtimeID })
+	return showtimes, nil
+}
+
+func (r *ShowtimeRepository) ListPage(query repository.ListQuery) (repository.Page[models.Showtime], error) {
+	showtimes, _ := r.List()
+	return listPage(showtimes, "showtime_id", repository.ShowtimeFields, query)
+}
+
+func (r *ShowtimeRepository) Get(id int) (models.Showtime, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	showtime, ok := r.store.state.showtimes[id]
+	if !ok {
+		return models.Showtime{}, repository.ErrNotFound
+	}
+	return showtime, nil
+}
+
+// Lock only checks that the showtime exists, transactions of the memory store
+// are already serialized
+func (r *ShowtimeRepository) Lock(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.showtimes[id]; !ok {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ShowtimeRepository) Occupancy(id int) (repository.Occupancy, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	showtime, ok := r.store.state.showtimes[id]
+	if !ok {
+		return repository.Occupancy{}, repository.ErrNotFound
+	}
+	occupancy := repository.Occupancy{ShowtimeID: id, Hall: r.store.state.halls[showtime.HallID], Booked: []string{}, Held: []string{}}
+	for _, booking := range r.store.state.bookings {
+		if booking.ShowtimeID == id && booking.Status == models.BookingStatusActive {
+			occupancy.Booked = append(occupancy.Booked, booking.Seat)
+		}
+	}
+	now := time.Now()
+	for _, hold := range r.store.state.holds {
+		if hold.ShowtimeID == id && !hold.Expired(now) {
+			occupancy.Held = append(occupancy.Held, hold.Seats...)
+		}
+	}
+	sort.Strings(occupancy.Booked)
+	sort.Strings(occupancy.Held)
+	return occupancy, nil
+}
+
+func (r *ShowtimeRepository) ListOverlapping(hallID int, start, end time.Time, excludeID int) ([]models.Showtime, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var showtimes []models.Showtime
+	for _, showtime := range r.store.state.showtimes {
+		if showtime.HallID != hallID || showtime.ShowtimeID == excludeID {
+			continue
+		}
+		from, err := showtime.Start()
+		if err != nil {
+			return nil, err
+		}
+		movie := r.store.state.movies[showtime.MovieID]
+		to := r.store.state.halls[hallID].ShowtimeEnd(from, movie.Duration)
+		if from.Before(end) && to.After(start) {
+			showtimes = append(showtimes, showtime)
+		}
+	}
+	sort.Slice(showtimes, func(i, j int) bool { return showtimes[i].ShowtimeID < showtimes[j].ShowtimeID })
+	return showtimes, nil
+}
+
+func (r *ShowtimeRepository) ListForHall(hallID int, at time.Time) ([]models.Showtime, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var showtimes []models.Showtime
+	starts := map[int]time.Time{}
+	for _, showtime := range r.store.state.showtimes {
+		if showtime.HallID != hallID {
+			continue
+		}
+		start, err := showtime.Start()
+		if err != nil {
+			return nil, err
+		}
+		movie := r.store.state.movies[showtime.MovieID]
+		if r.store.state.halls[hallID].ShowtimeEnd(start, movie.Duration).After(at) {
+			showtimes = append(showtimes, showtime)
+			starts[showtime.ShowtimeID] = start
+		}
+	}
+	sort.Slice(showtimes, func(i, j int) bool {
+		a, b := starts[showtimes[i].ShowtimeID], starts[showtimes[j].ShowtimeID]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return showtimes[i].ShowtimeID < showtimes[j].ShowtimeID
+	})
+	return showtimes, nil
+}
+
+func (r *ShowtimeRepository) FirstShowing(movieID int) (time.Time, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var first time.Time
+	for _, showtime := range r.store.state.showtimes {
+		if showtime.MovieID != movieID {
+			continue
+		}
+		start, err := showtime.Start()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+	}
+	if first.IsZero() {
+		return time.Time{}, repository.ErrNotFound
+	}
+	return first, nil
+}
+
+func (r *ShowtimeRepository) Create(showtime *models.Showtime) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.check(*showtime); err != nil {
+		return err
+	}
+	showtime.ShowtimeID = r.store.state.nextShow
+	showtime.Version = 1
+	r.store.state.nextShow++
+	r.store.state.showtimes[showtime.ShowtimeID] = *showtime
+	return nil
+}
+
+func (r *ShowtimeRepository) Update(showtime *models.Showtime) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.state.showtimes[showtime.ShowtimeID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if existing.Version != showtime.Version {
+		return repository.ErrConflict
+	}
+	if err := r.check(*showtime); err != nil {
+		return err
+	}
+	showtime.Version++
+	r.store.state.showtimes[showtime.ShowtimeID] = *showtime
+	return nil
+}
+
+func (r *ShowtimeRepository) Delete(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.showtimes[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, booking := range r.store.state.bookings {
+		if booking.ShowtimeID == id {
+			return repository.ErrInvalidReference
+		}
+	}
+	for _, order := range r.store.state.orders {
+		if order.ShowtimeID == id {
+			return repository.ErrInvalidReference
+		}
+	}
+	for holdID, hold := range r.store.state.holds {
+		if hold.ShowtimeID == id {
+			delete(r.store.state.holds, holdID)
+		}
+	}
+	delete(r.store.state.showtimes, id)
+	return nil
+}
+
+func (r *ShowtimeRepository) check(showtime models.Showtime) error {
+	if _, err := showtime.Start(); err != nil {
+		return err
+	}
+	if _, ok := r.store.state.movies[showtime.MovieID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if _, ok := r.store.state.halls[showtime.HallID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"sync"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+// state holds the tables of the store, the IDs are assigned like SERIAL
+// columns, starting at 1 and never reused
+type state struct {
+	users     map[int]models.User
+	movies    map[int]models.Movie
+	halls     map[int]models.Hall
+	showtimes map[int]models.Showtime
+	bookings  map[int]models.Booking
+	orders    map[int]models.Order
+	holds     map[int]models.Hold
+	nextUser  int
+	nextMovie int
+	nextHall  int
+	nextShow  int
+	nextBook  int
+	nextOrder int
+	nextHold  int
+}
+
+func newState() *state {
+	return &state{
+		users:     map[int]models.User{},
+		movies:    map[int]models.Movie{},
+		halls:     map[int]models.Hall{},
+		showtimes: map[int]models.Showtime{},
+		bookings:  map[int]models.Booking{},
+		orders:    map[int]models.Order{},
+		holds:     map[int]models.Hold{},
+		nextUser:  1,
+		nextMovie: 1,
+		nextHall:  1,
+		nextShow:  1,
+		nextBook:  1,
+		nextOrder: 1,
+		nextHold:  1,
+	}
+}
+
+func (s *state) clone() *state {
+	c := *s
+	c.users = cloneMap(s.users)
+	c.movies = cloneMap(s.movies)
+	c.halls = cloneMap(s.halls)
+	c.showtimes = cloneMap(s.showtimes)
+	c.bookings = cloneMap(s.bookings)
+	c.orders = cloneMap(s.orders)
+	c.holds = cloneMap(s.holds)
+	return &c
+}
+
+func cloneMap[V any](m map[int]V) map[int]V {
+	c := make(map[int]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Store implements repository.Store in memory. It enforces the same unique
+// and foreign key constraints as the database schema, so that handlers see
+// the same errors as with Postgres. It is meant for tests.
+type Store struct {
+	mu    *sync.Mutex
+	state *state
+}
+
+// NewStore creates a new empty Store
+func NewStore() *Store {
+	return &Store{mu: &sync.Mutex{}, state: newState()}
+}
+
+func (s *Store) Users() repository.UserRepository {
+	return &UserRepository{s}
+}
+
+func (s *Store) Movies() repository.MovieRepository {
+	return &MovieRepository{s}
+}
+
+func (s *Store) Halls() repository.HallRepository {
+	return &HallRepository{s}
+}
+
+func (s *Store) Showtimes() repository.ShowtimeRepository {
+	return &ShowtimeRepository{s}
+}
+
+func (s *Store) Bookings() repository.BookingRepository {
+	return &BookingRepository{s}
+}
+
+func (s *Store) Orders() repository.OrderRepository {
+	return &OrderRepository{s}
+}
+
+func (s *Store) Holds() repository.HoldRepository {
+	return &HoldRepository{s}
+}
+
+// WithTx runs fn on a copy of the data and keeps the copy only when fn
+// succeeds. Transactions are serialized with every other operation.
+func (s *Store) WithTx(fn func(tx repository.Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Store{mu: &sync.Mutex{}, state: s.state.clone()}
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	*s.state = *tx.state
+	return nil
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/repositorytest"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Store {
+		return NewStore()
+	})
+}
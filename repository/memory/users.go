@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"sort"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type UserRepository struct {
+	store *Store
+}
+
+func (r *UserRepository) List() ([]models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	users := make([]models.User, 0, len(r.store.state.users))
+	for _, user := range r.store.state.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *UserRepository) ListPage(query repository.ListQuery) (repository.Page[models.User], error) {
+	users, _ := r.List()
+	return listPage(users, "user_id", repository.UserFields, query)
+}
+
+func (r *UserRepository) Get(id int) (models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.state.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *UserRepository) GetByUsername(username string) (models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range r.store.state.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r *UserRepository) CountByRole(role models.Role) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	count := 0
+	for _, user := range r.store.state.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *UserRepository) Create(user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.emailTaken(user.Email, 0) {
+		return repository.ErrDuplicate
+	}
+	if user.Role == "" {
+		user.Role = models.RoleCustomer
+	}
+	user.ID = uint(r.store.state.nextUser)
+	user.Version = 1
+	r.store.state.nextUser++
+	r.store.state.users[int(user.ID)] = *user
+	return nil
+}
+
+func (r *UserRepository) Update(user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.state.users[int(user.ID)]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if existing.Version != user.Version {
+		return repository.ErrConflict
+	}
+	if r.emailTaken(user.Email, user.ID) {
+		return repository.ErrDuplicate
+	}
+	user.Version++
+	r.store.state.users[int(user.ID)] = *user
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(id uint, hash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.state.users[int(id)]
+	if !ok {
+		return nil
+	}
+	user.Password = hash
+	r.store.state.users[int(id)] = user
+	return nil
+}
+
+func (r *UserRepository) Delete(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.state.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, booking := range r.store.state.bookings {
+		if booking.UserID == id {
+			return repository.ErrInvalidReference
+		}
+	}
+	for _, order := range r.store.state.orders {
+		if order.UserID == id {
+			return repository.ErrInvalidReference
+		}
+	}
+	for holdID, hold := range r.store.state.holds {
+		if hold.UserID == id {
+			delete(r.store.state.holds, holdID)
+		}
+	}
+	delete(r.store.state.users, id)
+	return nil
+}
+
+func (r *UserRepository) emailTaken(email string, exceptID uint) bool {
+	for _, user := range r.store.state.users {
+		if user.Email == email && user.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
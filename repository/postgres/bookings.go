@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"time"
+
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type BookingRepository struct {
+	db dbtx
+}
+
+func (r *BookingRepository) List() ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Select(&bookings, "SELECT * FROM bookings")
+	return bookings, err
+}
+
+func (r *BookingRepository) ListPage(query repository.ListQuery) (repository.Page[models.Booking], error) {
+	return listPage(r.db, "bookings", "booking_id", repository.BookingFields, query)
+}
+
+func (r *BookingRepository) Get(id int) (models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Get(&booking, "SELECT * FROM bookings WHERE booking_id=$1", id)
+	return booking, notFound(err)
+}
+
+func (r *BookingRepository) ListForShowtime(showtimeID int) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Select(&bookings, "SELECT * FROM bookings WHERE showtime_id=$1 AND status='active' ORDER BY booking_id", showtimeID)
+	return bookings, err
+}
+
+func (r *BookingRepository) CountForSeat(showtimeID int, seat string, excludeID int) (int, error) {
+	var count int
+	err := r.db.Get(&count, "SELECT COUNT(*) FROM bookings WHERE showtime_id=$1 AND seat=$2 AND booking_id<>$3 AND status='active'", showtimeID, seat, excludeID)
+	return count, err
+}
+
+func (r *BookingRepository) Create(booking *models.Booking) error {
+	booking.Status = models.BookingStatusActive
+	booking.Version = 1
+	query := `INSERT INTO bookings (user_id, showtime_id, seat, order_id, ticket_type, price, price_breakdown, status)
+		VALUES (:user_id, :showtime_id, :seat, :order_id, :ticket_type, :price, :price_breakdown, :status) RETURNING booking_id`
+	return insertReturningID(r.db, query, booking, &booking.BookingID)
+}
+
+func (r *BookingRepository) Update(booking *models.Booking) error {
+	result, err := r.db.NamedExec(`UPDATE bookings SET user_id=:user_id, showtime_id=:showtime_id, seat=:seat, ticket_type=:ticket_type,
+		price=:price, price_breakdown=:price_breakdown, version=version+1
+		WHERE booking_id=:booking_id AND version=:version`, booking)
+	return versioned(r.db, "bookings", "booking_id", booking.BookingID, &booking.Version, result, err)
+}
+
+func (r *BookingRepository) Cancel(id int, reason string, refund int, at time.Time) error {
+	result, err := r.db.Exec(`UPDATE bookings SET status='cancelled', cancelled_at=$2, cancellation_reason=$3, refund_amount=$4,
+		version=version+1 WHERE booking_id=$1 AND status='active'`, id, at, reason, refund)
+	if err != nil {
+		return constraintError(err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	var exists bool
+	err = r.db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM bookings WHERE booking_id=$1)", id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+	return repository.ErrConflict
+}
+
+func (r *BookingRepository) CheckIn(id int, at time.Time) error {
+	result, err := r.db.Exec("UPDATE bookings SET checked_in_at=$2, version=version+1 WHERE booking_id=$1 AND status='active' AND checked_in_at IS NULL", id, at)
+	if err != nil {
+		return constraintError(err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	var exists bool
+	err = r.db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM bookings WHERE booking_id=$1)", id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+	return repository.ErrConflict
+}
+
+func (r *BookingRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM bookings WHERE booking_id=$1", id)
+	return affected(result, err)
+}
+
+func (r *BookingRepository) DeleteForOrder(orderID int) error {
+	_, err := r.db.Exec("DELETE FROM bookings WHERE order_id=$1", orderID)
+	return constraintError(err)
+}
+
+func (r *BookingRepository) RecordPayment(orderID int) error {
+	_, err := r.db.Exec("UPDATE bookings SET paid_amount=price, version=version+1 WHERE order_id=$1 AND status='active'", orderID)
+	return err
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type HallRepository struct {
+	db dbtx
+}
+
+func (r *HallRepository) List() ([]models.Hall, error) {
+	var halls []models.Hall
+	err := r.db.Select(&halls, "SELECT * FROM halls ORDER BY hall_id")
+	return halls, err
+}
+
+func (r *HallRepository) ListPage(query repository.ListQuery) (repository.Page[models.Hall], error) {
+	return listPage(r.db, "halls", "hall_id", repository.HallFields, query)
+}
+
+func (r *HallRepository) Get(id int) (models.Hall, error) {
+	var hall models.Hall
+	err := r.db.Get(&hall, "SELECT * FROM halls WHERE hall_id=$1", id)
+	return hall, notFound(err)
+}
+
+func (r *HallRepository) Lock(id int) error {
+	var locked int
+	err := r.db.Get(&locked, "SELECT hall_id FROM halls WHERE hall_id=$1 FOR UPDATE", id)
+	return notFound(err)
+}
+
+func (r *HallRepository) Create(hall *models.Hall) error {
+	query := `INSERT INTO halls (name, capacity, layout, trailer_minutes, cleaning_minutes)
+		VALUES (:name, :capacity, :layout, :trailer_minutes, :cleaning_minutes) RETURNING hall_id`
+	hall.Version = 1
+	return insertReturningID(r.db, query, hall, &hall.HallID)
+}
+
+func (r *HallRepository) Update(hall *models.Hall) error {
+	result, err := r.db.NamedExec(`UPDATE halls SET name=:name, capacity=:capacity, layout=:layout,
+		trailer_minutes=:trailer_minutes, cleaning_minutes=:cleaning_minutes, version=version+1
+		WHERE hall_id=:hall_id AND version=:version`, hall)
+	return versioned(r.db, "halls", "hall_id", hall.HallID, &hall.Version, result, err)
+}
+
+func (r *HallRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM halls WHERE hall_id=$1", id)
+	return affected(result, err)
+}
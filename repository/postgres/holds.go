@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"errors"
+	"one-way-ticket/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type HoldRepository struct {
+	db dbtx
+}
+
+// holdRow is a hold with its seats aggregated into an array
+type holdRow struct {
+	models.Hold
+	Seats pq.StringArray `db:"seats"`
+}
+
+func (r *HoldRepository) Get(id int) (models.Hold, error) {
+	var row holdRow
+	err := r.db.Get(&row, `SELECT h.hold_id, h.user_id, h.showtime_id, h.expires_at, array_agg(hs.seat) AS seats
+		FROM holds h
+		JOIN hold_seats hs ON hs.hold_id = h.hold_id
+		WHERE h.hold_id = $1
+		GROUP BY h.hold_id`, id)
+	if err != nil {
+		return models.Hold{}, notFound(err)
+	}
+	hold := row.Hold
+	hold.Seats = row.Seats
+	return hold, nil
+}
+
+func (r *HoldRepository) IsHeld(showtimeID int, seat string, now time.Time) (bool, error) {
+	var held bool
+	err := r.db.Get(&held, `SELECT EXISTS (SELECT 1 FROM hold_seats hs JOIN holds h ON h.hold_id = hs.hold_id
+		WHERE hs.showtime_id = $1 AND hs.seat = $2 AND h.expires_at > $3)`, showtimeID, seat, now)
+	return held, err
+}
+
+// Create inserts the hold and its seats with a single statement, so that a
+// hold is never stored without its seats
+func (r *HoldRepository) Create(hold *models.Hold) error {
+	if len(hold.Seats) == 0 {
+		return errors.New("a hold needs at least one seat")
+	}
+	var ids []int
+	err := r.db.Select(&ids, `WITH hold AS (
+			INSERT INTO holds (user_id, showtime_id, expires_at) VALUES ($1, $2, $3) RETURNING hold_id
+		)
+		INSERT INTO hold_seats (hold_id, showtime_id, seat)
+		SELECT hold.hold_id, $2, seat FROM hold, unnest($4::text[]) AS seat
+		RETURNING hold_id`, hold.UserID, hold.ShowtimeID, hold.ExpiresAt, pq.StringArray(hold.Seats))
+	if err != nil {
+		return constraintError(err)
+	}
+	hold.HoldID = ids[0]
+	return nil
+}
+
+func (r *HoldRepository) Delete(id int) error {
+	_, err := r.db.Exec("DELETE FROM holds WHERE hold_id=$1", id)
+	return err
+}
+
+func (r *HoldRepository) DeleteForShowtime(showtimeID int) error {
+	_, err := r.db.Exec("DELETE FROM holds WHERE showtime_id=$1", showtimeID)
+	return err
+}
+
+func (r *HoldRepository) DeleteExpired(now time.Time) (int, error) {
+	result, err := r.db.Exec("DELETE FROM holds WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"one-way-ticket/repository"
+)
+
+// listPage selects a page of the records of table, whose ID column is idField.
+// Pages are read by keyset: the next page starts after the sort value and ID
+// of the cursor, so that reading far into a list costs no more than reading
+// its first page.
+func listPage[T any](db dbtx, table, idField string, fields repository.Fields[T], query repository.ListQuery) (repository.Page[T], error) {
+	var page repository.Page[T]
+	if err := fields.Check(query); err != nil {
+		return page, err
+	}
+	if query.Limit <= 0 {
+		return page, fmt.Errorf("invalid page size %d", query.Limit)
+	}
+
+	// field names were checked against fields, they are safe as column names
+	var conditions []string
+	var args []interface{}
+	for _, filter := range query.Filters {
+		args = append(args, filter.Value)
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", filter.Field, filter.Op, len(args)))
+	}
+
+	if query.Count {
+		var total int
+		err := db.Get(&total, "SELECT COUNT(*) FROM "+table+where(conditions), args...)
+		if err != nil {
+			return page, err
+		}
+		page.Total = &total
+	}
+
+	order, after := "ASC", ">"
+	if query.Desc {
+		order, after = "DESC", "<"
+	}
+	if query.After != nil {
+		args = append(args, query.After.Value, query.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, %s) %s ($%d, $%d)", query.Sort, idField, after, len(args)-1, len(args)))
+	}
+	// one more record than the page tells whether there is a next page
+	err := db.Select(&page.Items, fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s %s, %s %s LIMIT %d",
+		table, where(conditions), query.Sort, order, idField, order, query.Limit+1), args...)
+	if err != nil {
+		return page, err
+	}
+
+	if len(page.Items) > query.Limit {
+		page.Items = page.Items[:query.Limit]
+		last := page.Items[query.Limit-1]
+		cursor := repository.NewCursor(query.Sort, query.Desc, fields[query.Sort](last), fields[idField](last).(int))
+		page.Next = &cursor
+	}
+	return page, nil
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type MovieRepository struct {
+	db dbtx
+}
+
+func (r *MovieRepository) List() ([]models.Movie, error) {
+	var movies []models.Movie
+	err := r.db.Select(&movies, "SELECT * FROM movies")
+	return movies, err
+}
+
+func (r *MovieRepository) ListPage(query repository.ListQuery) (repository.Page[models.Movie], error) {
+	return listPage(r.db, "movies", "movie_id", repository.MovieFields, query)
+}
+
+func (r *MovieRepository) Get(id int) (models.Movie, error) {
+	var movie models.Movie
+	err := r.db.Get(&movie, "SELECT * FROM movies WHERE movie_id=$1", id)
+	return movie, notFound(err)
+}
+
+func (r *MovieRepository) Create(movie *models.Movie) error {
+	query := `INSERT INTO movies (title, duration, genre) VALUES (:title, :duration, :genre) RETURNING movie_id`
+	movie.Version = 1
+	return insertReturningID(r.db, query, movie, &movie.MovieID)
+}
+
+func (r *MovieRepository) Update(movie *models.Movie) error {
+	result, err := r.db.NamedExec(`UPDATE movies SET title=:title, duration=:duration, genre=:genre, version=version+1
+		WHERE movie_id=:movie_id AND version=:version`, movie)
+	return versioned(r.db, "movies", "movie_id", movie.MovieID, &movie.Version, result, err)
+}
+
+func (r *MovieRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM movies WHERE movie_id=$1", id)
+	return affected(result, err)
+}
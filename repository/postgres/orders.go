@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"time"
+)
+
+type OrderRepository struct {
+	db dbtx
+}
+
+// List returns the orders without their bookings
+func (r *OrderRepository) List() ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.Select(&orders, "SELECT * FROM orders ORDER BY order_id")
+	return orders, err
+}
+
+func (r *OrderRepository) ListPage(query repository.ListQuery) (repository.Page[models.Order], error) {
+	return listPage(r.db, "orders", "order_id", repository.OrderFields, query)
+}
+
+func (r *OrderRepository) Get(id int) (models.Order, error) {
+	var order models.Order
+	err := r.db.Get(&order, "SELECT * FROM orders WHERE order_id=$1", id)
+	if err != nil {
+		return order, notFound(err)
+	}
+	err = r.db.Select(&order.Bookings, "SELECT * FROM bookings WHERE order_id=$1 ORDER BY booking_id", id)
+	return order, err
+}
+
+func (r *OrderRepository) GetByPayment(paymentID string) (models.Order, error) {
+	var order models.Order
+	err := r.db.Get(&order, "SELECT * FROM orders WHERE payment_id=$1", paymentID)
+	return order, notFound(err)
+}
+
+func (r *OrderRepository) ListExpired(now time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.Select(&orders, "SELECT * FROM orders WHERE status=$1 AND expires_at <= $2 ORDER BY order_id", models.OrderPending, now)
+	return orders, err
+}
+
+func (r *OrderRepository) Create(order *models.Order) error {
+	err := r.db.Get(order, `INSERT INTO orders (user_id, showtime_id, status, amount, currency, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING *`,
+		order.UserID, order.ShowtimeID, order.Status, order.Amount, order.Currency, order.ExpiresAt)
+	return constraintError(err)
+}
+
+// Transition only updates the order while it is in status from, so that two
+// concurrent transitions cannot both succeed
+func (r *OrderRepository) Transition(id int, from, to models.OrderStatus, paymentID *string) error {
+	result, err := r.db.Exec("UPDATE orders SET status=$3, payment_id=COALESCE($4, payment_id) WHERE order_id=$1 AND status=$2", id, from, to, paymentID)
+	if err != nil {
+		return constraintError(err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil || updated > 0 {
+		return err
+	}
+
+	var exists bool
+	err = r.db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM orders WHERE order_id=$1)", id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+	return repository.ErrConflict
+}
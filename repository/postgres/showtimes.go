@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ShowtimeRepository struct {
+	db dbtx
+}
+
+func (r *ShowtimeRepository) List() ([]models.Showtime, error) {
+	var showtimes []models.Showtime
+	err := r.db.Select(&showtimes, "SELECT * FROM showtimes")
+	return showtimes, err
+}
+
+func (r *ShowtimeRepository) ListPage(query repository.ListQuery) (repository.Page[models.Showtime], error) {
+	return listPage(r.db, "showtimes", "showtime_id", repository.ShowtimeFields, query)
+}
+
+func (r *ShowtimeRepository) Get(id int) (models.Showtime, error) {
+	var showtime models.Showtime
+	err := r.db.Get(&showtime, "SELECT * FROM showtimes WHERE showtime_id=$1", id)
+	return showtime, notFound(err)
+}
+
+func (r *ShowtimeRepository) Lock(id int) error {
+	var locked int
+	err := r.db.Get(&locked, "SELECT showtime_id FROM showtimes WHERE showtime_id=$1 FOR UPDATE", id)
+	return notFound(err)
+}
+
+// Occupancy reads the hall and the taken seats with a single query, so that
+// the seat map of a showtime costs one round trip
+func (r *ShowtimeRepository) Occupancy(id int) (repository.Occupancy, error) {
+	var row struct {
+		ShowtimeID int               `db:"showtime_id"`
+		HallID     int               `db:"hall_id"`
+		Name       string            `db:"name"`
+		Capacity   int               `db:"capacity"`
+		Layout     models.HallLayout `db:"layout"`
+		Version    int               `db:"version"`
+		Booked     pq.StringArray    `db:"booked"`
+		Held       pq.StringArray    `db:"held"`
+	}
+	err := r.db.Get(&row, `SELECT s.showtime_id, h.hall_id, h.name, h.capacity, h.layout, h.version,
+		COALESCE(array_agg(b.seat) FILTER (WHERE b.seat IS NOT NULL), '{}') AS booked,
+		ARRAY(SELECT hs.seat FROM hold_seats hs JOIN holds ho ON ho.hold_id = hs.hold_id
+			WHERE ho.showtime_id = s.showtime_id AND ho.expires_at > now()) AS held
+		FROM showtimes s
+		JOIN halls h ON h.hall_id = s.hall_id
+		LEFT JOIN bookings b ON b.showtime_id = s.showtime_id AND b.status = 'active'
+		WHERE s.showtime_id = $1
+		GROUP BY s.showtime_id, h.hall_id`, id)
+	if err != nil {
+		return repository.Occupancy{}, notFound(err)
+	}
+	return repository.Occupancy{
+		ShowtimeID: row.ShowtimeID,
+		Hall:       models.Hall{HallID: row.HallID, Name: row.Name, Capacity: row.Capacity, Layout: row.Layout, Version: row.Version},
+		Booked:     row.Booked,
+		Held:       row.Held,
+	}, nil
+}
+
+func (r *ShowtimeRepository) ListOverlapping(hallID int, start, end time.Time, excludeID int) ([]models.Showtime, error) {
+	var showtimes []models.Showtime
+	err := r.db.Select(&showtimes, `SELECT s.* FROM showtimes s
+		JOIN movies m ON m.movie_id = s.movie_id
+		JOIN halls h ON h.hall_id = s.hall_id
+		WHERE s.hall_id = $1 AND s.showtime_id <> $4 AND s.showtime < $3
+		AND s.showtime + make_interval(mins => h.trailer_minutes + m.duration + h.cleaning_minutes) > $2
+		ORDER BY s.showtime`, hallID, start, end, excludeID)
+	return showtimes, err
+}
+
+func (r *ShowtimeRepository) ListForHall(hallID int, at time.Time) ([]models.Showtime, error) {
+	var showtimes []models.Showtime
+	err := r.db.Select(&showtimes, `SELECT s.* FROM showtimes s
+		JOIN movies m ON m.movie_id = s.movie_id
+		JOIN halls h ON h.hall_id = s.hall_id
+		WHERE s.hall_id = $1
+		AND s.showtime + make_interval(mins => h.trailer_minutes + m.duration + h.cleaning_minutes) > $2
+		ORDER BY s.showtime, s.showtime_id`, hallID, at)
+	return showtimes, err
+}
+
+func (r *ShowtimeRepository) FirstShowing(movieID int) (time.Time, error) {
+	var first *time.Time
+	err := r.db.Get(&first, "SELECT MIN(showtime) FROM showtimes WHERE movie_id=$1", movieID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if first == nil {
+		return time.Time{}, repository.ErrNotFound
+	}
+	return *first, nil
+}
+
+func (r *ShowtimeRepository) Create(showtime *models.Showtime) error {
+	query := `INSERT INTO showtimes (movie_id, showtime, hall_id) VALUES (:movie_id, :showtime, :hall_id) RETURNING showtime_id`
+	showtime.Version = 1
+	return insertReturningID(r.db, query, showtime, &showtime.ShowtimeID)
+}
+
+func (r *ShowtimeRepository) Update(showtime *models.Showtime) error {
+	result, err := r.db.NamedExec(`UPDATE showtimes SET movie_id=:movie_id, showtime=:showtime, hall_id=:hall_id, version=version+1
+		WHERE showtime_id=:showtime_id AND version=:version`, showtime)
+	return versioned(r.db, "showtimes", "showtime_id", showtime.ShowtimeID, &showtime.Version, result, err)
+}
+
+func (r *ShowtimeRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM showtimes WHERE showtime_id=$1", id)
+	return affected(result, err)
+}
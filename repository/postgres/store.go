@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"one-way-ticket/repository"
+)
+
+// dbtx is the part of sqlx.DB and sqlx.Tx used by the repositories, so that
+// they run the same way inside and outside of a transaction
+type dbtx interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedQuery(query string, arg interface{}) (*sqlx.Rows, error)
+}
+
+// Store implements repository.Store on a Postgres database
+type Store struct {
+	dbx *sqlx.DB
+	db  dbtx
+}
+
+// NewStore creates a new Store on the provided connection
+func NewStore(dbx *sqlx.DB) *Store {
+	return &Store{dbx: dbx, db: dbx}
+}
+
+func (s *Store) Users() repository.UserRepository {
+	return &UserRepository{db: s.db}
+}
+
+func (s *Store) Movies() repository.MovieRepository {
+	return &MovieRepository{db: s.db}
+}
+
+func (s *Store) Halls() repository.HallRepository {
+	return &HallRepository{db: s.db}
+}
+
+func (s *Store) Showtimes() repository.ShowtimeRepository {
+	return &ShowtimeRepository{db: s.db}
+}
+
+func (s *Store) Bookings() repository.BookingRepository {
+	return &BookingRepository{db: s.db}
+}
+
+func (s *Store) Orders() repository.OrderRepository {
+	return &OrderRepository{db: s.db}
+}
+
+func (s *Store) Holds() repository.HoldRepository {
+	return &HoldRepository{db: s.db}
+}
+
+func (s *Store) WithTx(fn func(tx repository.Store) error) error {
+	// already inside a transaction, join it
+	if _, ok := s.db.(*sqlx.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.dbx.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	err = fn(&Store{dbx: s.dbx, db: tx})
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertReturningID runs an INSERT ... RETURNING query and scans the new ID
+func insertReturningID(db dbtx, query string, arg interface{}, id interface{}) error {
+	rows, err := db.NamedQuery(query, arg)
+	if err != nil {
+		return constraintError(err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err = rows.Scan(id)
+		if err != nil {
+			return err
+		}
+	}
+	return constraintError(rows.Err())
+}
+
+// notFound translates the error of a single row query
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// affected translates the result of an update or delete of one record: the
+// write fails with ErrNotFound when it changed no row
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return constraintError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// versioned translates the result of an update of one record guarded by its
+// version, the record with the key id of the table: when the update changed
+// no row it fails with ErrNotFound if the record does not exist and with
+// ErrConflict if its version changed. On success the version is incremented
+// like the stored one.
+func versioned(db dbtx, table, key string, id int, version *int, result sql.Result, err error) error {
+	err = affected(result, err)
+	if err == nil {
+		*version++
+		return nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	var exists bool
+	err = db.Get(&exists, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s=$1)", table, key), id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+	return repository.ErrConflict
+}
+
+// constraintError translates the constraint violations reported by Postgres
+// to the errors of the repository package, keeping the original message
+func constraintError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return fmt.Errorf("%w: %v", repository.ErrDuplicate, err)
+	case "23503": // foreign_key_violation
+		return fmt.Errorf("%w: %v", repository.ErrInvalidReference, err)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+//go:build integration_test
+// +build integration_test
+
+package postgres
+
+import (
+	"os"
+	"testing"
+
+	"one-way-ticket/config"
+	"one-way-ticket/db"
+	"one-way-ticket/db/migrations"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/repositorytest"
+)
+
+func TestStore(t *testing.T) {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	err = db.Connect(cfg.Database)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db.Dbx)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	repositorytest.Run(t, func(t *testing.T) repository.Store {
+		db.Dbx.MustExec("TRUNCATE TABLE hold_seats, holds, bookings, orders, showtimes, halls, movies, users RESTART IDENTITY CASCADE")
+		return NewStore(db.Dbx)
+	})
+}
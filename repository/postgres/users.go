@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+type UserRepository struct {
+	db dbtx
+}
+
+func (r *UserRepository) List() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Select(&users, "SELECT * FROM users")
+	return users, err
+}
+
+func (r *UserRepository) ListPage(query repository.ListQuery) (repository.Page[models.User], error) {
+	return listPage(r.db, "users", "user_id", repository.UserFields, query)
+}
+
+func (r *UserRepository) Get(id int) (models.User, error) {
+	var user models.User
+	err := r.db.Get(&user, "SELECT * FROM users WHERE user_id=$1", id)
+	return user, notFound(err)
+}
+
+func (r *UserRepository) GetByUsername(username string) (models.User, error) {
+	var user models.User
+	err := r.db.Get(&user, "SELECT * FROM users WHERE username=$1", username)
+	return user, notFound(err)
+}
+
+func (r *UserRepository) CountByRole(role models.Role) (int, error) {
+	var count int
+	err := r.db.Get(&count, "SELECT COUNT(*) FROM users WHERE role=$1", role)
+	return count, err
+}
+
+func (r *UserRepository) Create(user *models.User) error {
+	query := `INSERT INTO users (username, password, email, role) VALUES (:username, :password, :email, :role) RETURNING user_id`
+	user.Version = 1
+	return insertReturningID(r.db, query, user, &user.ID)
+}
+
+func (r *UserRepository) Update(user *models.User) error {
+	result, err := r.db.NamedExec(`UPDATE users SET username=:username, password=:password, email=:email, role=:role, version=version+1
+		WHERE user_id=:user_id AND version=:version`, user)
+	return versioned(r.db, "users", "user_id", int(user.ID), &user.Version, result, err)
+}
+
+func (r *UserRepository) UpdatePassword(id uint, hash string) error {
+	_, err := r.db.Exec("UPDATE users SET password=$1 WHERE user_id=$2", hash, id)
+	return constraintError(err)
+}
+
+func (r *UserRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM users WHERE user_id=$1", id)
+	return affected(result, err)
+}
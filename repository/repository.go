@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"errors"
+	"one-way-ticket/models"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist,
+	// including by the Update and Delete methods
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a write violates a uniqueness rule
+	ErrDuplicate = errors.New("record already exists")
+	// ErrInvalidReference is returned when a write references a record that
+	// does not exist, or deletes a record that is still referenced
+	ErrInvalidReference = errors.New("invalid reference to another record")
+	// ErrConflict is returned when a record is no longer in the state a
+	// write expects, because another write changed it first
+	ErrConflict = errors.New("record was changed concurrently")
+)
+
+type UserRepository interface {
+	List() ([]models.User, error)
+	// ListPage returns the page of the users selected by the query, whose
+	// fields are the ones of UserFields
+	ListPage(query ListQuery) (Page[models.User], error)
+	Get(id int) (models.User, error)
+	GetByUsername(username string) (models.User, error)
+	CountByRole(role models.Role) (int, error)
+	// Create inserts the user and sets its ID and version
+	Create(user *models.User) error
+	// Update saves the user and sets its new version. It fails with
+	// ErrConflict when the version of the user is no longer the stored one.
+	Update(user *models.User) error
+	UpdatePassword(id uint, hash string) error
+	Delete(id int) error
+}
+
+type MovieRepository interface {
+	List() ([]models.Movie, error)
+	// ListPage returns the page of the movies selected by the query, whose
+	// fields are the ones of MovieFields
+	ListPage(query ListQuery) (Page[models.Movie], error)
+	Get(id int) (models.Movie, error)
+	// Create inserts the movie and sets its ID and version
+	Create(movie *models.Movie) error
+	// Update saves the movie and sets its new version. It fails with
+	// ErrConflict when the version of the movie is no longer the stored one.
+	Update(movie *models.Movie) error
+	Delete(id int) error
+}
+
+type HallRepository interface {
+	List() ([]models.Hall, error)
+	// ListPage returns the page of the halls selected by the query, whose
+	// fields are the ones of HallFields
+	ListPage(query ListQuery) (Page[models.Hall], error)
+	Get(id int) (models.Hall, error)
+	// Lock locks the hall until the end of the transaction, so that the
+	// showtimes of a hall are scheduled by one transaction at a time. It
+	// returns ErrNotFound when the hall does not exist.
+	Lock(id int) error
+	// Create inserts the hall and sets its ID and version
+	Create(hall *models.Hall) error
+	// Update saves the hall and sets its new version. It fails with
+	// ErrConflict when the version of the hall is no longer the stored one.
+	Update(hall *models.Hall) error
+	Delete(id int) error
+}
+
+// Occupancy is the hall of a showtime together with its taken seats
+type Occupancy struct {
+	ShowtimeID int
+	Hall       models.Hall
+	// Booked lists the labels of the booked seats
+	Booked []string
+	// Held lists the labels of the seats of holds that have not expired
+	Held []string
+}
+
+type ShowtimeRepository interface {
+	List() ([]models.Showtime, error)
+	// ListPage returns the page of the showtimes selected by the query, whose
+	// fields are the ones of ShowtimeFields
+	ListPage(query ListQuery) (Page[models.Showtime], error)
+	Get(id int) (models.Showtime, error)
+	// Occupancy returns the hall of the showtime and its booked and held seats
+	Occupancy(id int) (Occupancy, error)
+	// Lock locks the showtime until the end of the transaction, so that the
+	// seats of a showtime are changed by one transaction at a time. It
+	// returns ErrNotFound when the showtime does not exist.
+	Lock(id int) error
+	// ListOverlapping returns the showtimes of the hall that occupy it at some
+	// point between start and end. A showtime occupies its hall from its start
+	// until the end computed by Hall.ShowtimeEnd. The showtime excludeID is
+	// ignored so that a showtime being moved does not overlap itself.
+	ListOverlapping(hallID int, start, end time.Time, excludeID int) ([]models.Showtime, error)
+	// ListForHall returns the showtimes of the hall that are not over at at,
+	// ordered by start
+	ListForHall(hallID int, at time.Time) ([]models.Showtime, error)
+	// FirstShowing returns when the first showtime of the movie starts, or
+	// ErrNotFound when the movie has no showtime
+	FirstShowing(movieID int) (time.Time, error)
+	// Create inserts the showtime and sets its ID and version
+	Create(showtime *models.Showtime) error
+	// Update saves the showtime and sets its new version. It fails with
+	// ErrConflict when the version of the showtime is no longer the stored
+	// one.
+	Update(showtime *models.Showtime) error
+	Delete(id int) error
+}
+
+type BookingRepository interface {
+	List() ([]models.Booking, error)
+	// ListPage returns the page of the bookings selected by the query, whose
+	// fields are the ones of BookingFields
+	ListPage(query ListQuery) (Page[models.Booking], error)
+	Get(id int) (models.Booking, error)
+	// ListForShowtime returns the active bookings of the showtime ordered by
+	// ID
+	ListForShowtime(showtimeID int) ([]models.Booking, error)
+	// CountForSeat counts the active bookings of the seat, ignoring the
+	// booking excludeID so that a booking does not conflict with itself
+	CountForSeat(showtimeID int, seat string, excludeID int) (int, error)
+	// Create inserts the booking as active and sets its ID, status and
+	// version
+	Create(booking *models.Booking) error
+	// Update saves the booking, except for its order, its payment, its
+	// cancellation and its check-in which cannot change, and sets its new
+	// version. It fails with ErrConflict when the version of the booking is
+	// no longer the stored one.
+	Update(booking *models.Booking) error
+	// Cancel marks the active booking as cancelled at at, which frees its
+	// seat, and increments its version. It returns ErrNotFound for a missing
+	// booking and ErrConflict for one cancelled already.
+	Cancel(id int, reason string, refund int, at time.Time) error
+	// CheckIn marks the ticket of the active booking as used at at and
+	// increments its version. It returns ErrNotFound for a missing booking
+	// and ErrConflict for one checked in already or cancelled.
+	CheckIn(id int, at time.Time) error
+	Delete(id int) error
+	// DeleteForOrder deletes the bookings of the order
+	DeleteForOrder(orderID int) error
+	// RecordPayment records the price of the active bookings of the order
+	// as the amount paid for them and increments their version
+	RecordPayment(orderID int) error
+}
+
+type OrderRepository interface {
+	List() ([]models.Order, error)
+	// ListPage returns the page of the orders selected by the query, whose
+	// fields are the ones of OrderFields, without their bookings
+	ListPage(query ListQuery) (Page[models.Order], error)
+	// Get returns the order with its bookings
+	Get(id int) (models.Order, error)
+	// GetByPayment returns the order paid with the payment, without its
+	// bookings
+	GetByPayment(paymentID string) (models.Order, error)
+	// ListExpired returns the orders still pending after their expiry at now
+	ListExpired(now time.Time) ([]models.Order, error)
+	// Create inserts the order without its bookings and sets its ID and
+	// creation time, the bookings are created with the order ID afterwards
+	Create(order *models.Order) error
+	// Transition changes the status of the order from from to to, and sets
+	// its payment when paymentID is not nil. It fails with ErrConflict when
+	// the order is no longer in status from.
+	Transition(id int, from, to models.OrderStatus, paymentID *string) error
+}
+
+type HoldRepository interface {
+	Get(id int) (models.Hold, error)
+	// IsHeld reports whether the seat is part of a hold still valid at now
+	IsHeld(showtimeID int, seat string, now time.Time) (bool, error)
+	// Create inserts the hold with its seats and sets its ID. It fails with
+	// ErrDuplicate when a seat belongs to another hold, even an expired one
+	// that has not been deleted yet.
+	Create(hold *models.Hold) error
+	Delete(id int) error
+	// DeleteForShowtime deletes every hold of the showtime
+	DeleteForShowtime(showtimeID int) error
+	// DeleteExpired deletes the holds expired at now and returns their number
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// Store gives access to the repository of every aggregate
+type Store interface {
+	Users() UserRepository
+	Movies() MovieRepository
+	Halls() HallRepository
+	Showtimes() ShowtimeRepository
+	Bookings() BookingRepository
+	Orders() OrderRepository
+	Holds() HoldRepository
+	// WithTx runs fn with a Store whose repositories share one transaction.
+	// The transaction is committed when fn returns nil and rolled back otherwise.
+	WithTx(fn func(tx Store) error) error
+}
@@ -0,0 +1,746 @@
+// Package repositorytest holds the tests shared by every implementation of
+// repository.Store, so that the in-memory store used by the handler tests
+// behaves like the Postgres one.
+package repositorytest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+// Run runs the conformance tests, newStore must return an empty store
+func Run(t *testing.T, newStore func(t *testing.T) repository.Store) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("Movies", func(t *testing.T) { testMovies(t, newStore(t)) })
+	t.Run("Halls", func(t *testing.T) { testHalls(t, newStore(t)) })
+	t.Run("Showtimes", func(t *testing.T) { testShowtimes(t, newStore(t)) })
+	t.Run("Bookings", func(t *testing.T) { testBookings(t, newStore(t)) })
+	t.Run("Orders", func(t *testing.T) { testOrders(t, newStore(t)) })
+	t.Run("Holds", func(t *testing.T) { testHolds(t, newStore(t)) })
+	t.Run("Transactions", func(t *testing.T) { testTransactions(t, newStore(t)) })
+	t.Run("Pages", func(t *testing.T) { testPages(t, newStore(t)) })
+	t.Run("Missing Records", func(t *testing.T) { testMissingRecords(t, newStore(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, newStore(t)) })
+}
+
+// createHall creates a hall with two rows of ten seats
+func createHall(t *testing.T, store repository.Store, name string) models.Hall {
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10}, {Label: "B", Seats: 10}}}
+	hall := models.Hall{Name: name, Capacity: layout.Capacity(), Layout: layout}
+	if err := store.Halls().Create(&hall); err != nil {
+		t.Fatalf("Failed to create hall: %v", err)
+	}
+	return hall
+}
+
+func testUsers(t *testing.T, store repository.Store) {
+	users := store.Users()
+
+	alice := models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleAdmin}
+	assert.NoError(t, users.Create(&alice))
+	assert.NotZero(t, alice.ID)
+
+	bob := models.User{Username: "bob", Password: "hash", Email: "bob@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, users.Create(&bob))
+
+	duplicate := models.User{Username: "alice2", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}
+	assert.ErrorIs(t, users.Create(&duplicate), repository.ErrDuplicate)
+
+	found, err := users.GetByUsername("bob")
+	assert.NoError(t, err)
+	assert.Equal(t, bob, found)
+
+	_, err = users.GetByUsername("nobody")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	count, err := users.CountByRole(models.RoleAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, users.UpdatePassword(bob.ID, "new-hash"))
+	bob.Role = models.RoleStaff
+	bob.Password = "new-hash"
+	assert.NoError(t, users.Update(&bob))
+	found, err = users.Get(int(bob.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, bob, found)
+
+	list, err := users.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	assert.NoError(t, users.Delete(int(alice.ID)))
+	_, err = users.Get(int(alice.ID))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testMovies(t *testing.T, store repository.Store) {
+	movies := store.Movies()
+
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, movies.Create(&movie))
+	assert.NotZero(t, movie.MovieID)
+
+	movie.Title = "Inception Updated"
+	assert.NoError(t, movies.Update(&movie))
+	found, err := movies.Get(movie.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, movie, found)
+
+	// a movie with showtimes cannot be deleted
+	hall := createHall(t, store, "Hall 1")
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	assert.ErrorIs(t, movies.Delete(movie.MovieID), repository.ErrInvalidReference)
+
+	assert.NoError(t, store.Showtimes().Delete(showtime.ShowtimeID))
+	assert.NoError(t, movies.Delete(movie.MovieID))
+	_, err = movies.Get(movie.MovieID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testHalls(t *testing.T, store repository.Store) {
+	halls := store.Halls()
+	hall := createHall(t, store, "Hall 1")
+	assert.NotZero(t, hall.HallID)
+
+	duplicate := models.Hall{Name: "Hall 1", Layout: hall.Layout}
+	assert.ErrorIs(t, halls.Create(&duplicate), repository.ErrDuplicate)
+
+	hall.Layout.Rows = append(hall.Layout.Rows, models.HallRow{Label: "C", Seats: 12, Aisles: []int{6}, Disabled: []int{1}})
+	hall.Capacity = hall.Layout.Capacity()
+	assert.NoError(t, halls.Update(&hall))
+	found, err := halls.Get(hall.HallID)
+	assert.NoError(t, err)
+	assert.Equal(t, hall, found)
+	assert.Equal(t, 30, found.Capacity)
+
+	err = store.WithTx(func(tx repository.Store) error {
+		return tx.Halls().Lock(hall.HallID)
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, halls.Lock(hall.HallID+1), repository.ErrNotFound)
+
+	// a hall with showtimes cannot be deleted
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	assert.ErrorIs(t, halls.Delete(hall.HallID), repository.ErrInvalidReference)
+
+	assert.NoError(t, store.Showtimes().Delete(showtime.ShowtimeID))
+	assert.NoError(t, halls.Delete(hall.HallID))
+	list, err := halls.List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func testShowtimes(t *testing.T, store repository.Store) {
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	hall := createHall(t, store, "Hall 1")
+	otherHall := createHall(t, store, "Hall 2")
+	showtimes := store.Showtimes()
+
+	invalid := models.Showtime{MovieID: movie.MovieID + 1, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.ErrorIs(t, showtimes.Create(&invalid), repository.ErrInvalidReference)
+	invalid = models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: otherHall.HallID + 1}
+	assert.ErrorIs(t, showtimes.Create(&invalid), repository.ErrInvalidReference)
+
+	noon := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, showtimes.Create(&noon))
+	evening := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 20:00", HallID: hall.HallID}
+	assert.NoError(t, showtimes.Create(&evening))
+	other := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: otherHall.HallID}
+	assert.NoError(t, showtimes.Create(&other))
+
+	// the hall has no buffers, the noon showtime occupies it until 14:28
+	at := func(hour, minute int) time.Time { return time.Date(2024, 5, 30, hour, minute, 0, 0, time.UTC) }
+	overlapping, err := showtimes.ListOverlapping(hall.HallID, at(14, 0), at(15, 0), 0)
+	assert.NoError(t, err)
+	if assert.Len(t, overlapping, 1) {
+		assert.Equal(t, noon.ShowtimeID, overlapping[0].ShowtimeID)
+	}
+	overlapping, err = showtimes.ListOverlapping(hall.HallID, at(10, 0), at(12, 0), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, overlapping)
+	overlapping, err = showtimes.ListOverlapping(hall.HallID, at(14, 28), at(20, 0), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, overlapping)
+	overlapping, err = showtimes.ListOverlapping(hall.HallID, at(11, 0), at(21, 0), 0)
+	assert.NoError(t, err)
+	assert.Len(t, overlapping, 2)
+	// a showtime moved by a few minutes does not overlap itself
+	overlapping, err = showtimes.ListOverlapping(hall.HallID, at(12, 10), at(14, 38), noon.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Empty(t, overlapping)
+
+	forHall, err := showtimes.ListForHall(hall.HallID, at(9, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{noon.ShowtimeID, evening.ShowtimeID}, showtimeIDs(forHall))
+	forHall, err = showtimes.ListForHall(hall.HallID, at(14, 28))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{evening.ShowtimeID}, showtimeIDs(forHall))
+
+	first, err := showtimes.FirstShowing(movie.MovieID)
+	assert.NoError(t, err)
+	assert.True(t, at(12, 0).Equal(first))
+	_, err = showtimes.FirstShowing(movie.MovieID + 1)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	evening.HallID = otherHall.HallID
+	assert.NoError(t, showtimes.Update(&evening))
+	found, err := showtimes.Get(evening.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, otherHall.HallID, found.HallID)
+
+	list, err := showtimes.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+}
+
+func testBookings(t *testing.T, store repository.Store) {
+	user := models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, store.Users().Create(&user))
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	hall := createHall(t, store, "Hall 1")
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	bookings := store.Bookings()
+
+	booking := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A1"}
+	assert.NoError(t, bookings.Create(&booking))
+	assert.NotZero(t, booking.BookingID)
+
+	taken := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A1"}
+	assert.ErrorIs(t, bookings.Create(&taken), repository.ErrDuplicate)
+
+	invalid := models.Booking{UserID: int(user.ID) + 1, ShowtimeID: showtime.ShowtimeID, Seat: "A2"}
+	assert.ErrorIs(t, bookings.Create(&invalid), repository.ErrInvalidReference)
+
+	count, err := bookings.CountForSeat(showtime.ShowtimeID, "A1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = bookings.CountForSeat(showtime.ShowtimeID, "A1", booking.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// a booked showtime or user cannot be deleted
+	assert.ErrorIs(t, store.Showtimes().Delete(showtime.ShowtimeID), repository.ErrInvalidReference)
+	assert.ErrorIs(t, store.Users().Delete(int(user.ID)), repository.ErrInvalidReference)
+
+	booking.Seat = "A2"
+	assert.NoError(t, bookings.Update(&booking))
+	found, err := bookings.Get(booking.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, booking, found)
+
+	second := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "B3"}
+	assert.NoError(t, bookings.Create(&second))
+	forShowtime, err := bookings.ListForShowtime(showtime.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Booking{booking, second}, forShowtime)
+	forShowtime, err = bookings.ListForShowtime(showtime.ShowtimeID + 1)
+	assert.NoError(t, err)
+	assert.Empty(t, forShowtime)
+	occupancy, err := store.Showtimes().Occupancy(showtime.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, hall, occupancy.Hall)
+	assert.ElementsMatch(t, []string{"A2", "B3"}, occupancy.Booked)
+	_, err = store.Showtimes().Occupancy(showtime.ShowtimeID + 1)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	err = store.WithTx(func(tx repository.Store) error {
+		return tx.Showtimes().Lock(showtime.ShowtimeID)
+	})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, store.Showtimes().Lock(showtime.ShowtimeID+1), repository.ErrNotFound)
+
+	checkedInAt := time.Now()
+	assert.NoError(t, bookings.CheckIn(booking.BookingID, checkedInAt))
+	assert.ErrorIs(t, bookings.CheckIn(booking.BookingID, checkedInAt), repository.ErrConflict)
+	assert.ErrorIs(t, bookings.CheckIn(booking.BookingID+100, checkedInAt), repository.ErrNotFound)
+	found, err = bookings.Get(booking.BookingID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found.CheckedInAt) {
+		assert.WithinDuration(t, checkedInAt, *found.CheckedInAt, time.Millisecond)
+	}
+	assert.Equal(t, booking.Version+1, found.Version)
+	booking.CheckedInAt = found.CheckedInAt
+	booking.Version = found.Version
+
+	// a cancelled booking is kept but frees its seat
+	cancelledAt := time.Now()
+	assert.NoError(t, bookings.Cancel(second.BookingID, "Plans changed", 600, cancelledAt))
+	assert.ErrorIs(t, bookings.Cancel(second.BookingID, "", 0, cancelledAt), repository.ErrConflict)
+	assert.ErrorIs(t, bookings.Cancel(second.BookingID+100, "", 0, cancelledAt), repository.ErrNotFound)
+	assert.ErrorIs(t, bookings.CheckIn(second.BookingID, cancelledAt), repository.ErrConflict)
+	cancelled, err := bookings.Get(second.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusCancelled, cancelled.Status)
+	assert.Equal(t, "Plans changed", cancelled.CancellationReason)
+	if assert.NotNil(t, cancelled.RefundAmount) {
+		assert.Equal(t, 600, *cancelled.RefundAmount)
+	}
+	if assert.NotNil(t, cancelled.CancelledAt) {
+		assert.WithinDuration(t, cancelledAt, *cancelled.CancelledAt, time.Millisecond)
+	}
+	forShowtime, err = bookings.ListForShowtime(showtime.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Booking{booking}, forShowtime)
+	forUser, err := bookings.ListPage(repository.ListQuery{
+		Filters: []repository.Filter{{Field: "user_id", Op: repository.OpEqual, Value: int(user.ID)}},
+		Sort:    "booking_id",
+		Limit:   10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Booking{booking, cancelled}, forUser.Items)
+	assert.Nil(t, forUser.Next)
+	assert.Nil(t, forUser.Total)
+	count, err = bookings.CountForSeat(showtime.ShowtimeID, "B3", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	occupancy, err = store.Showtimes().Occupancy(showtime.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A2"}, occupancy.Booked)
+	rebooked := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "B3"}
+	assert.NoError(t, bookings.Create(&rebooked))
+	assert.Equal(t, models.BookingStatusActive, rebooked.Status)
+
+	assert.NoError(t, bookings.Delete(booking.BookingID))
+	assert.NoError(t, bookings.Delete(second.BookingID))
+	assert.NoError(t, bookings.Delete(rebooked.BookingID))
+	list, err := bookings.List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	occupancy, err = store.Showtimes().Occupancy(showtime.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Empty(t, occupancy.Booked)
+}
+
+func testOrders(t *testing.T, store repository.Store) {
+	user := models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, store.Users().Create(&user))
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	hall := createHall(t, store, "Hall 1")
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	orders := store.Orders()
+
+	expiry := time.Now().Add(time.Minute).Truncate(time.Second)
+	order := models.Order{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Status: models.OrderPending, Amount: 2400, Currency: "EUR", ExpiresAt: &expiry}
+	assert.NoError(t, orders.Create(&order))
+	assert.NotZero(t, order.OrderID)
+	assert.False(t, order.CreatedAt.IsZero())
+
+	invalid := models.Order{UserID: int(user.ID) + 1, ShowtimeID: showtime.ShowtimeID, Status: models.OrderPending, Currency: "EUR"}
+	assert.ErrorIs(t, orders.Create(&invalid), repository.ErrInvalidReference)
+
+	first := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A1", OrderID: &order.OrderID, Price: 1200}
+	assert.NoError(t, store.Bookings().Create(&first))
+	second := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A2", OrderID: &order.OrderID, Price: 1200}
+	assert.NoError(t, store.Bookings().Create(&second))
+	single := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A3"}
+	assert.NoError(t, store.Bookings().Create(&single))
+
+	unknownOrder := order.OrderID + 1
+	orphan := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A4", OrderID: &unknownOrder}
+	assert.ErrorIs(t, store.Bookings().Create(&orphan), repository.ErrInvalidReference)
+
+	found, err := orders.Get(order.OrderID)
+	assert.NoError(t, err)
+	assert.Equal(t, order.UserID, found.UserID)
+	assert.Equal(t, order.ShowtimeID, found.ShowtimeID)
+	assert.Equal(t, []models.Booking{first, second}, found.Bookings)
+	_, err = orders.Get(unknownOrder)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the order of a booking is kept when the booking is updated
+	second.Seat = "A5"
+	second.OrderID = nil
+	assert.NoError(t, store.Bookings().Update(&second))
+	updated, err := store.Bookings().Get(second.BookingID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, updated.OrderID) {
+		assert.Equal(t, order.OrderID, *updated.OrderID)
+	}
+
+	list, err := orders.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	expired, err := orders.ListExpired(expiry.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+	expired, err = orders.ListExpired(expiry)
+	assert.NoError(t, err)
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, order.OrderID, expired[0].OrderID)
+	}
+
+	// a status only changes from the status the caller expects
+	payment := "pay_1"
+	assert.NoError(t, orders.Transition(order.OrderID, models.OrderPending, models.OrderPaid, &payment))
+	assert.ErrorIs(t, orders.Transition(order.OrderID, models.OrderPending, models.OrderFailed, nil), repository.ErrConflict)
+	assert.ErrorIs(t, orders.Transition(unknownOrder, models.OrderPending, models.OrderPaid, nil), repository.ErrNotFound)
+	paid, err := orders.GetByPayment(payment)
+	assert.NoError(t, err)
+	assert.Equal(t, order.OrderID, paid.OrderID)
+	assert.Equal(t, models.OrderPaid, paid.Status)
+	assert.Equal(t, 2400, paid.Amount)
+	_, err = orders.GetByPayment("pay_2")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the bookings of the order record what was paid for them, which an
+	// update does not change
+	assert.NoError(t, store.Bookings().RecordPayment(order.OrderID))
+	paidBooking, err := store.Bookings().Get(first.BookingID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, paidBooking.PaidAmount) {
+		assert.Equal(t, 1200, *paidBooking.PaidAmount)
+	}
+	assert.Equal(t, first.Version+1, paidBooking.Version)
+	paidBooking.Price = 1500
+	paidBooking.PaidAmount = nil
+	assert.NoError(t, store.Bookings().Update(&paidBooking))
+	paidBooking, err = store.Bookings().Get(first.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1200, paidBooking.Paid())
+	single, err = store.Bookings().Get(single.BookingID)
+	assert.NoError(t, err)
+	assert.Nil(t, single.PaidAmount)
+	expired, err = orders.ListExpired(expiry)
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+
+	assert.NoError(t, store.Bookings().DeleteForOrder(order.OrderID))
+	found, err = orders.Get(order.OrderID)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Bookings)
+	_, err = store.Bookings().Get(single.BookingID)
+	assert.NoError(t, err)
+}
+
+func testHolds(t *testing.T, store repository.Store) {
+	user := models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, store.Users().Create(&user))
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	hall := createHall(t, store, "Hall 1")
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	holds := store.Holds()
+	now := time.Now().Truncate(time.Second)
+
+	hold := models.Hold{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seats: []string{"A1", "A2"}, ExpiresAt: now.Add(time.Minute)}
+	assert.NoError(t, holds.Create(&hold))
+	assert.NotZero(t, hold.HoldID)
+
+	found, err := holds.Get(hold.HoldID)
+	assert.NoError(t, err)
+	assert.Equal(t, hold.UserID, found.UserID)
+	assert.Equal(t, hold.ShowtimeID, found.ShowtimeID)
+	assert.ElementsMatch(t, hold.Seats, found.Seats)
+	assert.True(t, hold.ExpiresAt.Equal(found.ExpiresAt))
+	_, err = holds.Get(hold.HoldID + 1)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	held, err := holds.IsHeld(showtime.ShowtimeID, "A2", now)
+	assert.NoError(t, err)
+	assert.True(t, held)
+	held, err = holds.IsHeld(showtime.ShowtimeID, "A2", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, held)
+	held, err = holds.IsHeld(showtime.ShowtimeID, "A3", now)
+	assert.NoError(t, err)
+	assert.False(t, held)
+
+	taken := models.Hold{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seats: []string{"A3", "A2"}, ExpiresAt: now.Add(time.Minute)}
+	assert.ErrorIs(t, holds.Create(&taken), repository.ErrDuplicate)
+	invalid := models.Hold{UserID: int(user.ID) + 1, ShowtimeID: showtime.ShowtimeID, Seats: []string{"A3"}, ExpiresAt: now.Add(time.Minute)}
+	assert.ErrorIs(t, holds.Create(&invalid), repository.ErrInvalidReference)
+
+	expired := models.Hold{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seats: []string{"B1"}, ExpiresAt: now.Add(-time.Minute)}
+	assert.NoError(t, holds.Create(&expired))
+	occupancy, err := store.Showtimes().Occupancy(showtime.ShowtimeID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"A1", "A2"}, occupancy.Held)
+
+	deleted, err := holds.DeleteExpired(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = holds.Get(expired.HoldID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	assert.NoError(t, holds.Delete(hold.HoldID))
+	_, err = holds.Get(hold.HoldID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the seats of a deleted hold can be held again
+	assert.NoError(t, holds.Create(&taken))
+
+	assert.NoError(t, holds.DeleteForShowtime(showtime.ShowtimeID))
+	_, err = holds.Get(taken.HoldID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testTransactions(t *testing.T, store repository.Store) {
+	errAbort := errors.New("abort")
+
+	err := store.WithTx(func(tx repository.Store) error {
+		movie := models.Movie{Title: "Rolled Back", Duration: 90, Genre: "Drama"}
+		if err := tx.Movies().Create(&movie); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	list, err := store.Movies().List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	var movie models.Movie
+	hall := createHall(t, store, "Hall 1")
+	err = store.WithTx(func(tx repository.Store) error {
+		movie = models.Movie{Title: "Committed", Duration: 90, Genre: "Drama"}
+		if err := tx.Movies().Create(&movie); err != nil {
+			return err
+		}
+		showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+		return tx.Showtimes().Create(&showtime)
+	})
+	assert.NoError(t, err)
+
+	found, err := store.Movies().Get(movie.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Committed", found.Title)
+	showtimes, err := store.Showtimes().List()
+	assert.NoError(t, err)
+	assert.Len(t, showtimes, 1)
+}
+
+func showtimeIDs(showtimes []models.Showtime) []int {
+	ids := []int{}
+	for _, showtime := range showtimes {
+		ids = append(ids, showtime.ShowtimeID)
+	}
+	return ids
+}
+
+func testPages(t *testing.T, store repository.Store) {
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	hall := createHall(t, store, "Hall 1")
+	otherHall := createHall(t, store, "Hall 2")
+	// created out of order so that the order of the IDs is not the one of
+	// the start times
+	var ids []int
+	for _, s := range []struct {
+		start  string
+		hallID int
+	}{
+		{"2024-05-30 18:00", hall.HallID},
+		{"2024-05-30 12:00", hall.HallID},
+		{"2024-05-30 15:00", otherHall.HallID},
+		{"2024-05-30 21:00", hall.HallID},
+		{"2024-05-31 12:00", hall.HallID},
+	} {
+		showtime := models.Showtime{MovieID: movie.MovieID, Showtime: s.start, HallID: s.hallID}
+		assert.NoError(t, store.Showtimes().Create(&showtime))
+		ids = append(ids, showtime.ShowtimeID)
+	}
+	showtimes := store.Showtimes()
+
+	inHall := []repository.Filter{{Field: "hall_id", Op: repository.OpEqual, Value: hall.HallID}}
+	query := repository.ListQuery{Filters: inHall, Sort: "showtime", Limit: 3, Count: true}
+	page, err := showtimes.ListPage(query)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ids[1], ids[0], ids[3]}, showtimeIDs(page.Items))
+	if assert.NotNil(t, page.Total) {
+		assert.Equal(t, 4, *page.Total)
+	}
+	if assert.NotNil(t, page.Next) {
+		query.After = page.Next
+		page, err = showtimes.ListPage(query)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{ids[4]}, showtimeIDs(page.Items))
+		assert.Nil(t, page.Next)
+	}
+
+	page, err = showtimes.ListPage(repository.ListQuery{Sort: "showtime", Desc: true, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ids[4], ids[3]}, showtimeIDs(page.Items))
+	if assert.NotNil(t, page.Next) {
+		assert.True(t, page.Next.Desc)
+		page, err = showtimes.ListPage(repository.ListQuery{Sort: "showtime", Desc: true, Limit: 2, After: page.Next})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{ids[0], ids[2]}, showtimeIDs(page.Items))
+	}
+
+	page, err = showtimes.ListPage(repository.ListQuery{
+		Filters: []repository.Filter{
+			{Field: "showtime", Op: repository.OpFrom, Value: time.Date(2024, 5, 30, 15, 0, 0, 0, time.UTC)},
+			{Field: "showtime", Op: repository.OpBefore, Value: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		},
+		Sort:  "showtime_id",
+		Limit: 10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{ids[0], ids[2], ids[3]}, showtimeIDs(page.Items))
+	assert.Nil(t, page.Next)
+
+	page, err = showtimes.ListPage(repository.ListQuery{Filters: inHall, Sort: "showtime_id", Limit: 10, Count: true})
+	assert.NoError(t, err)
+	if assert.NotNil(t, page.Total) {
+		assert.Equal(t, 4, *page.Total)
+	}
+
+	// ties of the sort field are ordered by ID
+	titles := []string{"B", "A", "B"}
+	for _, title := range titles {
+		assert.NoError(t, store.Movies().Create(&models.Movie{Title: title, Duration: 90, Genre: "Drama"}))
+	}
+	drama := []repository.Filter{{Field: "genre", Op: repository.OpEqual, Value: "Drama"}}
+	movies, err := store.Movies().ListPage(repository.ListQuery{Filters: drama, Sort: "title", Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, movies.Items, 2) && assert.NotNil(t, movies.Next) {
+		assert.Equal(t, "A", movies.Items[0].Title)
+		assert.Equal(t, "B", movies.Items[1].Title)
+		rest, err := store.Movies().ListPage(repository.ListQuery{Filters: drama, Sort: "title", Limit: 2, After: movies.Next})
+		assert.NoError(t, err)
+		if assert.Len(t, rest.Items, 1) {
+			assert.Equal(t, "B", rest.Items[0].Title)
+			assert.Greater(t, rest.Items[0].MovieID, movies.Items[1].MovieID)
+		}
+	}
+
+	_, err = showtimes.ListPage(repository.ListQuery{Sort: "title", Limit: 10})
+	assert.Error(t, err)
+	_, err = showtimes.ListPage(repository.ListQuery{Sort: "showtime_id", Limit: 10, After: &repository.Cursor{Sort: "showtime", Value: "x"}})
+	assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+
+	halls, err := store.Halls().ListPage(repository.ListQuery{Sort: "name", Desc: true, Limit: 1, Count: true})
+	assert.NoError(t, err)
+	if assert.Len(t, halls.Items, 1) && assert.NotNil(t, halls.Next) {
+		assert.Equal(t, otherHall.HallID, halls.Items[0].HallID)
+		halls, err = store.Halls().ListPage(repository.ListQuery{Sort: "name", Desc: true, Limit: 1, After: halls.Next})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Hall{hall}, halls.Items)
+	}
+
+	users, err := store.Users().ListPage(repository.ListQuery{Sort: "username", Limit: 10, Count: true})
+	assert.NoError(t, err)
+	assert.Empty(t, users.Items)
+	if assert.NotNil(t, users.Total) {
+		assert.Equal(t, 0, *users.Total)
+	}
+
+	user := models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, store.Users().Create(&user))
+	for _, amount := range []int{2400, 1200} {
+		order := models.Order{UserID: int(user.ID), ShowtimeID: ids[0], Status: models.OrderPending, Amount: amount, Currency: "EUR"}
+		assert.NoError(t, store.Orders().Create(&order))
+	}
+	byAmount, err := store.Orders().ListPage(repository.ListQuery{Sort: "amount", Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, byAmount.Items, 2) {
+		assert.Equal(t, 1200, byAmount.Items[0].Amount)
+		assert.Equal(t, 2400, byAmount.Items[1].Amount)
+	}
+	paid := []repository.Filter{{Field: "status", Op: repository.OpEqual, Value: string(models.OrderPaid)}}
+	byDate, err := store.Orders().ListPage(repository.ListQuery{Filters: paid, Sort: "created_at", Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, byDate.Items)
+}
+
+// testMissingRecords checks that writes to records that do not exist fail
+// instead of changing nothing
+func testMissingRecords(t *testing.T, store repository.Store) {
+	assert.ErrorIs(t, store.Users().Update(&models.User{ID: 42, Username: "nobody", Email: "nobody@example.com", Role: models.RoleCustomer}), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Users().Delete(42), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Movies().Update(&models.Movie{MovieID: 42, Title: "Missing", Duration: 90, Genre: "Drama"}), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Movies().Delete(42), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Halls().Update(&models.Hall{HallID: 42, Name: "Missing"}), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Halls().Delete(42), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Showtimes().Delete(42), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Bookings().Delete(42), repository.ErrNotFound)
+}
+
+// testVersions checks that writes increment the version of records and that
+// updates of a stale version fail without changing the record
+func testVersions(t *testing.T, store repository.Store) {
+	user := models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}
+	assert.NoError(t, store.Users().Create(&user))
+	assert.Equal(t, 1, user.Version)
+	stale := user
+	user.Email = "alice@example.org"
+	assert.NoError(t, store.Users().Update(&user))
+	assert.Equal(t, 2, user.Version)
+	stale.Role = models.RoleAdmin
+	assert.ErrorIs(t, store.Users().Update(&stale), repository.ErrConflict)
+	found, err := store.Users().Get(int(user.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, user, found)
+
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	assert.Equal(t, 1, movie.Version)
+	staleMovie := movie
+	movie.Duration = 150
+	assert.NoError(t, store.Movies().Update(&movie))
+	assert.Equal(t, 2, movie.Version)
+	staleMovie.Title = "Stale"
+	assert.ErrorIs(t, store.Movies().Update(&staleMovie), repository.ErrConflict)
+	foundMovie, err := store.Movies().Get(movie.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, movie, foundMovie)
+
+	hall := createHall(t, store, "Hall 1")
+	assert.Equal(t, 1, hall.Version)
+	staleHall := hall
+	hall.CleaningMinutes = 20
+	assert.NoError(t, store.Halls().Update(&hall))
+	assert.Equal(t, 2, hall.Version)
+	staleHall.Name = "Stale"
+	assert.ErrorIs(t, store.Halls().Update(&staleHall), repository.ErrConflict)
+	foundHall, err := store.Halls().Get(hall.HallID)
+	assert.NoError(t, err)
+	assert.Equal(t, hall, foundHall)
+
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	assert.Equal(t, 1, showtime.Version)
+	staleShowtime := showtime
+	showtime.Showtime = "2024-05-30 18:00"
+	assert.NoError(t, store.Showtimes().Update(&showtime))
+	assert.Equal(t, 2, showtime.Version)
+	assert.ErrorIs(t, store.Showtimes().Update(&staleShowtime), repository.ErrConflict)
+
+	booking := models.Booking{UserID: int(user.ID), ShowtimeID: showtime.ShowtimeID, Seat: "A1"}
+	assert.NoError(t, store.Bookings().Create(&booking))
+	assert.Equal(t, 1, booking.Version)
+	staleBooking := booking
+	booking.Seat = "A2"
+	assert.NoError(t, store.Bookings().Update(&booking))
+	assert.Equal(t, 2, booking.Version)
+	staleBooking.Seat = "A3"
+	assert.ErrorIs(t, store.Bookings().Update(&staleBooking), repository.ErrConflict)
+
+	// checking a booking in and cancelling it are changes as well
+	assert.NoError(t, store.Bookings().CheckIn(booking.BookingID, time.Now()))
+	assert.NoError(t, store.Bookings().Cancel(booking.BookingID, "test", 0, time.Now()))
+	foundBooking, err := store.Bookings().Get(booking.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, "A2", foundBooking.Seat)
+	assert.Equal(t, 4, foundBooking.Version)
+	assert.ErrorIs(t, store.Bookings().Update(&booking), repository.ErrConflict)
+}
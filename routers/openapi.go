@@ -0,0 +1,129 @@
+package routers
+
+import (
+	"net/http"
+
+	"one-way-ticket/auth"
+	"one-way-ticket/models"
+	"one-way-ticket/openapi"
+	"one-way-ticket/payments"
+	"one-way-ticket/service/listing"
+)
+
+// page lists the query parameters reading a page of a list besides its
+// filters
+var page = []string{"sort", "limit", "cursor"}
+
+// operations documents every route of SetupRouter but the documentation
+// itself, the tests fail when a route is missing
+var operations = []openapi.Operation{
+	{Method: "POST", Path: "/login", Tag: "auth", Summary: "Log in with a username and a password", Public: true,
+		Form: []string{"username", "password"}, Status: http.StatusOK, Response: auth.Tokens{}},
+	{Method: "POST", Path: "/token/refresh", Tag: "auth", Summary: "Exchange a refresh token for new tokens", Public: true,
+		Form: []string{"refresh_token"}, Status: http.StatusOK, Response: auth.Tokens{}},
+	{Method: "POST", Path: "/logout", Tag: "auth", Summary: "Revoke the session of the access token",
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/logout/all", Tag: "auth", Summary: "Revoke every session of the user",
+		Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/users/", Tag: "users", Summary: "List users",
+		Query: append([]string{"role", "username", "email"}, page...), Status: http.StatusOK, Response: listing.Response[models.User]{}},
+	{Method: "GET", Path: "/users/:id", Tag: "users", Summary: "Get a user",
+		Status: http.StatusOK, Response: models.User{}, Versioned: true},
+	{Method: "POST", Path: "/users/", Tag: "users", Summary: "Create a user",
+		Request: models.UserInput{}, Status: http.StatusCreated, Response: models.User{}, Versioned: true},
+	{Method: "PUT", Path: "/users/:id", Tag: "users", Summary: "Replace a user",
+		Request: models.UserInput{}, Status: http.StatusOK, Response: models.User{}, Versioned: true},
+	{Method: "PATCH", Path: "/users/:id", Tag: "users", Summary: "Update fields of a user",
+		Request: models.UserPatch{}, Patch: true, Status: http.StatusOK, Response: models.User{}, Versioned: true},
+	{Method: "DELETE", Path: "/users/:id", Tag: "users", Summary: "Delete a user",
+		Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/movies/", Tag: "movies", Summary: "List movies",
+		Query: append([]string{"genre", "title"}, page...), Status: http.StatusOK, Response: listing.Response[models.Movie]{}},
+	{Method: "GET", Path: "/movies/:id", Tag: "movies", Summary: "Get a movie",
+		Status: http.StatusOK, Response: models.Movie{}, Versioned: true},
+	{Method: "POST", Path: "/movies/", Tag: "movies", Summary: "Create a movie",
+		Request: models.MovieInput{}, Status: http.StatusCreated, Response: models.Movie{}, Versioned: true},
+	{Method: "PUT", Path: "/movies/:id", Tag: "movies", Summary: "Replace a movie",
+		Request: models.MovieInput{}, Status: http.StatusOK, Response: models.Movie{}, Versioned: true},
+	{Method: "PATCH", Path: "/movies/:id", Tag: "movies", Summary: "Update fields of a movie",
+		Request: models.MovieInput{}, Patch: true, Status: http.StatusOK, Response: models.Movie{}, Versioned: true},
+	{Method: "DELETE", Path: "/movies/:id", Tag: "movies", Summary: "Delete a movie",
+		Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/halls/", Tag: "halls", Summary: "List halls",
+		Query: page, Status: http.StatusOK, Response: listing.Response[models.Hall]{}},
+	{Method: "GET", Path: "/halls/:id", Tag: "halls", Summary: "Get a hall",
+		Status: http.StatusOK, Response: models.Hall{}, Versioned: true},
+	{Method: "POST", Path: "/halls/", Tag: "halls", Summary: "Create a hall",
+		Request: models.HallInput{}, Status: http.StatusCreated, Response: models.Hall{}, Versioned: true},
+	{Method: "PUT", Path: "/halls/:id", Tag: "halls", Summary: "Update a hall",
+		Request: models.HallInput{}, Status: http.StatusOK, Response: models.Hall{}, Versioned: true},
+	{Method: "PATCH", Path: "/halls/:id", Tag: "halls", Summary: "Update fields of a hall",
+		Request: models.HallInput{}, Patch: true, Status: http.StatusOK, Response: models.Hall{}, Versioned: true},
+	{Method: "DELETE", Path: "/halls/:id", Tag: "halls", Summary: "Delete a hall",
+		Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/showtimes/", Tag: "showtimes", Summary: "List showtimes",
+		Query: append([]string{"movie_id", "hall_id", "from", "to"}, page...), Status: http.StatusOK, Response: listing.Response[models.Showtime]{}},
+	{Method: "GET", Path: "/showtimes/:id", Tag: "showtimes", Summary: "Get a showtime",
+		Status: http.StatusOK, Response: models.Showtime{}, Versioned: true},
+	{Method: "GET", Path: "/showtimes/:id/seats", Tag: "showtimes", Summary: "Get the seat map of a showtime",
+		Status: http.StatusOK, Response: models.SeatMap{}},
+	{Method: "POST", Path: "/showtimes/:id/quote", Tag: "showtimes", Summary: "Price seats of a showtime",
+		Request: models.QuoteInput{}, Status: http.StatusOK, Response: models.Quote{}},
+	{Method: "POST", Path: "/showtimes/:id/holds", Tag: "holds", Summary: "Hold seats of a showtime",
+		Request: models.HoldInput{}, Status: http.StatusCreated, Response: models.Hold{}},
+	{Method: "POST", Path: "/showtimes/", Tag: "showtimes", Summary: "Schedule a showtime",
+		Request: models.ShowtimeInput{}, Status: http.StatusCreated, Response: models.Showtime{}, Versioned: true},
+	{Method: "PUT", Path: "/showtimes/:id", Tag: "showtimes", Summary: "Move a showtime, rebooking its bookings with force",
+		Query: []string{"force"}, Request: models.ShowtimeInput{}, Status: http.StatusOK, Response: models.ShowtimeUpdate{}, Versioned: true},
+	{Method: "PATCH", Path: "/showtimes/:id", Tag: "showtimes", Summary: "Update fields of a showtime, rebooking its bookings with force",
+		Query: []string{"force"}, Request: models.ShowtimeInput{}, Patch: true, Status: http.StatusOK, Response: models.ShowtimeUpdate{}, Versioned: true},
+	{Method: "DELETE", Path: "/showtimes/:id", Tag: "showtimes", Summary: "Delete a showtime",
+		Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/bookings/", Tag: "bookings", Summary: "List bookings",
+		Query: append([]string{"showtime_id", "user_id", "status"}, page...), Status: http.StatusOK, Response: listing.Response[models.Booking]{}},
+	{Method: "GET", Path: "/bookings/:id", Tag: "bookings", Summary: "Get a booking",
+		Status: http.StatusOK, Response: models.Booking{}, Versioned: true},
+	{Method: "POST", Path: "/bookings/", Tag: "bookings", Summary: "Sell a seat at the box office",
+		Request: models.BookingInput{}, Status: http.StatusCreated, Response: models.Booking{}, Versioned: true},
+	{Method: "PUT", Path: "/bookings/:id", Tag: "bookings", Summary: "Replace a booking",
+		Request: models.BookingInput{}, Status: http.StatusOK, Response: models.Booking{}, Versioned: true},
+	{Method: "PATCH", Path: "/bookings/:id", Tag: "bookings", Summary: "Update fields of a booking",
+		Request: models.BookingInput{}, Patch: true, Status: http.StatusOK, Response: models.Booking{}, Versioned: true},
+	{Method: "DELETE", Path: "/bookings/:id", Tag: "bookings", Summary: "Delete a booking without refunding it",
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/bookings/:id/cancel", Tag: "bookings", Summary: "Cancel a booking and refund it",
+		Request: models.CancellationInput{}, Status: http.StatusOK, Response: models.Booking{}},
+	{Method: "GET", Path: "/bookings/:id/ticket", Tag: "tickets", Summary: "Get the ticket of a booking",
+		Status: http.StatusOK, Response: models.Ticket{}},
+	{Method: "GET", Path: "/bookings/:id/ticket/qr", Tag: "tickets", Summary: "Get the ticket of a booking as a QR code",
+		Status: http.StatusOK, ContentType: "image/png"},
+	{Method: "GET", Path: "/me/bookings", Tag: "bookings", Summary: "List the bookings of the caller",
+		Query: append([]string{"showtime_id", "status"}, page...), Status: http.StatusOK, Response: listing.Response[models.Booking]{}},
+	{Method: "POST", Path: "/checkin", Tag: "tickets", Summary: "Check a ticket in at the entrance",
+		Request: models.CheckInInput{}, Status: http.StatusOK, Response: models.Ticket{}},
+
+	{Method: "GET", Path: "/orders/", Tag: "orders", Summary: "List orders",
+		Query: append([]string{"showtime_id", "user_id", "status"}, page...), Status: http.StatusOK, Response: listing.Response[models.Order]{}},
+	{Method: "GET", Path: "/orders/:id", Tag: "orders", Summary: "Get an order",
+		Status: http.StatusOK, Response: models.Order{}},
+	{Method: "POST", Path: "/orders/", Tag: "orders", Summary: "Book seats of a showtime as one order",
+		Request: models.OrderInput{}, Status: http.StatusCreated, Response: models.Order{}},
+	{Method: "POST", Path: "/orders/:id/pay", Tag: "orders", Summary: "Pay an order",
+		Status: http.StatusOK, Response: models.Order{}},
+	{Method: "POST", Path: "/orders/:id/refund", Tag: "orders", Summary: "Refund an order",
+		Status: http.StatusOK, Response: models.Order{}},
+	{Method: "POST", Path: "/payments/webhook", Tag: "orders", Summary: "Receive a payment event of the provider", Public: true,
+		Request: payments.Event{}, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/holds/:id", Tag: "holds", Summary: "Get a hold",
+		Status: http.StatusOK, Response: models.Hold{}},
+	{Method: "POST", Path: "/holds/:id/confirm", Tag: "holds", Summary: "Book the seats of a hold as one order",
+		Request: models.ConfirmHoldInput{}, Status: http.StatusCreated, Response: models.Order{}},
+	{Method: "DELETE", Path: "/holds/:id", Tag: "holds", Summary: "Release a hold",
+		Status: http.StatusNoContent},
+}
@@ -2,59 +2,156 @@ package routers
 
 import (
 	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"one-way-ticket/apierror"
 	"one-way-ticket/auth"
-	"one-way-ticket/dynamo"
+	"one-way-ticket/config"
+	"one-way-ticket/openapi"
+	"one-way-ticket/payments"
+	"one-way-ticket/pricing"
+	"one-way-ticket/repository"
 	"one-way-ticket/service/bookings"
+	"one-way-ticket/service/halls"
 	"one-way-ticket/service/movies"
 	"one-way-ticket/service/showtimes"
+	"one-way-ticket/service/tickets"
 	"one-way-ticket/service/users"
+	"one-way-ticket/sessions"
 )
 
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
+// SetupRouter creates the router of the API, it fails when the pricing rules
+// of the configuration are invalid
+func SetupRouter(cfg *config.Config, store sessions.SessionStore, repos repository.Store, provider payments.PaymentProvider) (*gin.Engine, error) {
+	r := gin.New()
+	// errors and panics of the handlers are reported as problem details
+	r.Use(gin.Logger(), gin.CustomRecovery(apierror.Recovery), apierror.Middleware())
+	r.NoRoute(apierror.NoRoute)
+
+	handler := auth.NewHandler(store, repos.Users(), cfg.Auth)
+	userHandler := users.NewHandler(repos.Users())
+	movieHandler := movies.NewHandler(repos.Movies())
+	hallHandler := halls.NewHandler(repos)
+	showtimeHandler := showtimes.NewHandler(repos)
+	prices, err := pricing.NewEngine(cfg.Pricing.Rules())
+	if err != nil {
+		return nil, err
+	}
+	bookingHandler := bookings.NewHandler(repos, cfg.Holds, prices, provider, cfg.Cancellation.Policy())
+	ticketHandler := tickets.NewHandler(repos, cfg.Tickets, cfg.Tickets.Key(cfg.Auth))
+
+	// the operations are static, a document that cannot be built fails the
+	// tests already
+	doc, err := openapi.Document("one-way-ticket", "1.0.0", operations)
+	if err != nil {
+		panic(err)
+	}
+	r.GET("/openapi.json", openapi.Handler(doc))
+	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("/openapi.json")))
 
-	handler := auth.NewHandler(dynamo.NewDynamoClient())
 	r.POST("/login", handler.Login)
+	r.POST("/token/refresh", handler.Refresh)
+	// webhooks are authenticated by the signature of the provider
+	r.POST("/payments/webhook", bookingHandler.PaymentWebhook)
+
+	logoutRoutes := r.Group("/logout")
+	logoutRoutes.Use(handler.AuthenticateMiddleware())
+	{
+		logoutRoutes.POST("", handler.Logout)
+		logoutRoutes.POST("/all", handler.LogoutAll)
+	}
 
 	userRoutes := r.Group("/users")
 	userRoutes.Use(handler.AuthenticateMiddleware())
 	{
-		userRoutes.GET("/", users.GetUsers)
-		userRoutes.GET("/:id", users.GetUser)
-		userRoutes.POST("/", users.CreateUser)
-		userRoutes.PUT("/:id", users.UpdateUser)
-		userRoutes.DELETE("/:id", users.DeleteUser)
+		userRoutes.GET("/", auth.Authorize(auth.ReadUsers), userHandler.GetUsers)
+		userRoutes.GET("/:id", auth.Authorize(auth.ReadUsers), userHandler.GetUser)
+		userRoutes.POST("/", auth.Authorize(auth.WriteUsers), userHandler.CreateUser)
+		userRoutes.PUT("/:id", auth.Authorize(auth.WriteUsers), userHandler.UpdateUser)
+		userRoutes.PATCH("/:id", auth.Authorize(auth.WriteUsers), userHandler.PatchUser)
+		userRoutes.DELETE("/:id", auth.Authorize(auth.WriteUsers), userHandler.DeleteUser)
 	}
 
 	moviesRoutes := r.Group("/movies")
 	moviesRoutes.Use(handler.AuthenticateMiddleware())
 	{
-		moviesRoutes.GET("/", movies.GetMovies)
-		moviesRoutes.GET("/:id", movies.GetMovie)
-		moviesRoutes.POST("/", movies.CreateMovie)
-		moviesRoutes.PUT("/:id", movies.UpdateMovie)
-		moviesRoutes.DELETE("/:id", movies.DeleteMovie)
+		moviesRoutes.GET("/", auth.Authorize(auth.ReadMovies), movieHandler.GetMovies)
+		moviesRoutes.GET("/:id", auth.Authorize(auth.ReadMovies), movieHandler.GetMovie)
+		moviesRoutes.POST("/", auth.Authorize(auth.WriteMovies), movieHandler.CreateMovie)
+		moviesRoutes.PUT("/:id", auth.Authorize(auth.WriteMovies), movieHandler.UpdateMovie)
+		moviesRoutes.PATCH("/:id", auth.Authorize(auth.WriteMovies), movieHandler.PatchMovie)
+		moviesRoutes.DELETE("/:id", auth.Authorize(auth.WriteMovies), movieHandler.DeleteMovie)
+	}
+
+	hallsRoutes := r.Group("/halls")
+	hallsRoutes.Use(handler.AuthenticateMiddleware())
+	{
+		hallsRoutes.GET("/", auth.Authorize(auth.ReadHalls), hallHandler.GetHalls)
+		hallsRoutes.GET("/:id", auth.Authorize(auth.ReadHalls), hallHandler.GetHall)
+		hallsRoutes.POST("/", auth.Authorize(auth.WriteHalls), hallHandler.CreateHall)
+		hallsRoutes.PUT("/:id", auth.Authorize(auth.WriteHalls), hallHandler.UpdateHall)
+		hallsRoutes.PATCH("/:id", auth.Authorize(auth.WriteHalls), hallHandler.PatchHall)
+		hallsRoutes.DELETE("/:id", auth.Authorize(auth.WriteHalls), hallHandler.DeleteHall)
 	}
 
 	showTimesRoutes := r.Group("/showtimes")
 	showTimesRoutes.Use(handler.AuthenticateMiddleware())
 	{
-		showTimesRoutes.GET("/", showtimes.GetShowtimes)
-		showTimesRoutes.GET("/:id", showtimes.GetShowtime)
-		showTimesRoutes.POST("/", showtimes.CreateShowtime)
-		showTimesRoutes.PUT("/:id", showtimes.UpdateShowtime)
-		showTimesRoutes.DELETE("/:id", showtimes.DeleteShowtime)
+		showTimesRoutes.GET("/", auth.Authorize(auth.ReadShowtimes), showtimeHandler.GetShowtimes)
+		showTimesRoutes.GET("/:id", auth.Authorize(auth.ReadShowtimes), showtimeHandler.GetShowtime)
+		showTimesRoutes.GET("/:id/seats", auth.Authorize(auth.ReadShowtimes), showtimeHandler.GetSeatMap)
+		showTimesRoutes.POST("/:id/quote", auth.Authorize(auth.ReadShowtimes), bookingHandler.QuoteShowtime)
+		showTimesRoutes.POST("/:id/holds", auth.Authorize(auth.WriteBookings), bookingHandler.CreateHold)
+		showTimesRoutes.POST("/", auth.Authorize(auth.WriteShowtimes), showtimeHandler.CreateShowtime)
+		showTimesRoutes.PUT("/:id", auth.Authorize(auth.WriteShowtimes), showtimeHandler.UpdateShowtime)
+		showTimesRoutes.PATCH("/:id", auth.Authorize(auth.WriteShowtimes), showtimeHandler.PatchShowtime)
+		showTimesRoutes.DELETE("/:id", auth.Authorize(auth.WriteShowtimes), showtimeHandler.DeleteShowtime)
 	}
 
+	// customers may manage their own bookings, the handlers check that they
+	// own them; listing every booking, selling seats without an order at the
+	// box office and deleting bookings without a refund are reserved to staff
 	bookingsRoutes := r.Group("/bookings")
 	bookingsRoutes.Use(handler.AuthenticateMiddleware())
 	{
-		bookingsRoutes.GET("/", bookings.GetBookings)
-		bookingsRoutes.GET("/:id", bookings.GetBooking)
-		bookingsRoutes.POST("/", bookings.CreateBooking)
-		bookingsRoutes.PUT("/:id", bookings.UpdateBooking)
-		bookingsRoutes.DELETE("/:id", bookings.DeleteBooking)
+		bookingsRoutes.GET("/", auth.Authorize(auth.ManageBookings), bookingHandler.GetBookings)
+		bookingsRoutes.GET("/:id", auth.Authorize(auth.ReadBookings), bookingHandler.GetBooking)
+		bookingsRoutes.POST("/", auth.Authorize(auth.ManageBookings), bookingHandler.CreateBooking)
+		bookingsRoutes.PUT("/:id", auth.Authorize(auth.WriteBookings), bookingHandler.UpdateBooking)
+		bookingsRoutes.PATCH("/:id", auth.Authorize(auth.WriteBookings), bookingHandler.PatchBooking)
+		bookingsRoutes.DELETE("/:id", auth.Authorize(auth.ManageBookings), bookingHandler.DeleteBooking)
+		bookingsRoutes.POST("/:id/cancel", auth.Authorize(auth.WriteBookings), bookingHandler.CancelBooking)
+		bookingsRoutes.GET("/:id/ticket", auth.Authorize(auth.ReadBookings), ticketHandler.GetTicket)
+		bookingsRoutes.GET("/:id/ticket/qr", auth.Authorize(auth.ReadBookings), ticketHandler.GetTicketQR)
+	}
+
+	meRoutes := r.Group("/me")
+	meRoutes.Use(handler.AuthenticateMiddleware())
+	{
+		meRoutes.GET("/bookings", auth.Authorize(auth.ReadBookings), bookingHandler.GetMyBookings)
+	}
+
+	// tickets are scanned by staff at the entrance
+	r.POST("/checkin", handler.AuthenticateMiddleware(), auth.Authorize(auth.CheckInTickets), ticketHandler.CheckIn)
+
+	ordersRoutes := r.Group("/orders")
+	ordersRoutes.Use(handler.AuthenticateMiddleware())
+	{
+		ordersRoutes.GET("/", auth.Authorize(auth.ManageBookings), bookingHandler.GetOrders)
+		ordersRoutes.GET("/:id", auth.Authorize(auth.ReadBookings), bookingHandler.GetOrder)
+		ordersRoutes.POST("/", auth.Authorize(auth.WriteBookings), bookingHandler.CreateOrder)
+		ordersRoutes.POST("/:id/pay", auth.Authorize(auth.WriteBookings), bookingHandler.PayOrder)
+		ordersRoutes.POST("/:id/refund", auth.Authorize(auth.ManageBookings), bookingHandler.RefundOrder)
+	}
+
+	// holds are checked against their owner by the handlers
+	holdsRoutes := r.Group("/holds")
+	holdsRoutes.Use(handler.AuthenticateMiddleware())
+	{
+		holdsRoutes.GET("/:id", auth.Authorize(auth.ReadBookings), bookingHandler.GetHold)
+		holdsRoutes.POST("/:id/confirm", auth.Authorize(auth.WriteBookings), bookingHandler.ConfirmHold)
+		holdsRoutes.DELETE("/:id", auth.Authorize(auth.WriteBookings), bookingHandler.ReleaseHold)
 	}
 
-	return r
+	return r, nil
 }
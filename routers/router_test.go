@@ -0,0 +1,96 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/config"
+	"one-way-ticket/openapi"
+	"one-way-ticket/payments"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/sessions"
+	"testing"
+)
+
+func setupRouter(t *testing.T) *gin.Engine {
+	cfg := config.Default()
+	provider := payments.NewFakeProvider(cfg.Payments.Fake, "test-webhook-secret")
+	router, err := SetupRouter(cfg, sessions.NewMemoryStore(), memory.NewStore(), provider)
+	require.NoError(t, err)
+	return router
+}
+
+func TestSetupRouterInvalidPricing(t *testing.T) {
+	cfg := config.Default()
+	cfg.Pricing.BasePrice = 0
+	provider := payments.NewFakeProvider(cfg.Payments.Fake, "test-webhook-secret")
+
+	_, err := SetupRouter(cfg, sessions.NewMemoryStore(), memory.NewStore(), provider)
+
+	assert.ErrorContains(t, err, "base price must be positive")
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	router := setupRouter(t)
+
+	documented := map[string]bool{}
+	for _, operation := range operations {
+		documented[operation.Key()] = true
+	}
+	// the documentation does not document itself
+	undocumented := map[string]bool{"GET /openapi.json": true, "GET /docs/*any": true}
+
+	routes := map[string]bool{}
+	for _, route := range router.Routes() {
+		key := openapi.Operation{Method: route.Method, Path: route.Path}.Key()
+		routes[key] = true
+		assert.True(t, documented[key] || undocumented[key], "route %s is not documented", key)
+	}
+	for key := range documented {
+		assert.True(t, routes[key], "operation %s has no route", key)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	router := setupRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	require.NoError(t, err)
+	assert.NoError(t, doc.Validate(context.Background()))
+
+	movie := doc.Paths.Find("/movies/{id}")
+	require.NotNil(t, movie)
+	assert.NotNil(t, movie.Put.RequestBody)
+	require.NotNil(t, movie.Patch)
+	assert.NotNil(t, movie.Patch.RequestBody.Value.Content.Get("application/merge-patch+json"))
+	assert.NotNil(t, movie.Patch.Parameters.GetByInAndName(openapi3.ParameterInHeader, "If-Match"))
+
+	var input openapi3.Schema
+	data, _ := json.Marshal(doc.Components.Schemas["MovieInput"].Value)
+	require.NoError(t, json.Unmarshal(data, &input))
+	assert.ElementsMatch(t, []string{"title", "duration", "genre"}, input.Required)
+	assert.Contains(t, doc.Components.Schemas, "MovieList")
+	assert.NotContains(t, doc.Components.Schemas["User"].Value.Properties, "password")
+}
+
+func TestSwaggerUI(t *testing.T) {
+	router := setupRouter(t)
+
+	// the UI matches the request URI, which only httptest.NewRequest sets
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/docs/index.html", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "swagger-ui")
+}
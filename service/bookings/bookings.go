@@ -1,152 +1,400 @@
 package bookings
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"net/http"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth"
+	"one-way-ticket/config"
 	"one-way-ticket/models"
+	"one-way-ticket/payments"
+	"one-way-ticket/pricing"
+	"one-way-ticket/repository"
+	"one-way-ticket/service/etag"
+	"one-way-ticket/service/listing"
+	"one-way-ticket/validation"
 	"strconv"
+	"time"
 )
 
 var log = logrus.New()
 
 const (
-	InvalidBookingID          = "Invalid booking ID"
-	SeatNumberOutOfRangeError = "Seat number must be between 1 and 100"
-	OverlappingSeatError      = "Seat number is already booked for this showtime"
+	InvalidBookingID     = "Invalid booking ID"
+	BookingNotFoundError = "Booking not found"
+	InvalidSeatError     = "Seat does not exist in the hall of this showtime"
+	DisabledSeatError    = "Seat is disabled and cannot be booked"
+	OverlappingSeatError = "Seat is already booked for this showtime"
+	HeldSeatError        = "Seat is held by another customer"
+	InvalidShowtimeError = "Showtime does not exist"
+	BookingOwnerError    = "Booking belongs to another user"
+	OtherUserError       = "Only staff can book for other users"
+	OrderPriceError      = "Booking belongs to an order, its price cannot change"
 )
 
-func GetBookings(c *gin.Context) {
-	var bookings []models.Booking
-	err := db.Dbx.Select(&bookings, "SELECT * FROM bookings")
+var (
+	errInvalidBookingID = apierror.Validation("invalid_booking_id", InvalidBookingID)
+	errInvalidSeat      = apierror.Validation("invalid_seat", InvalidSeatError)
+	errDisabledSeat     = apierror.Validation("seat_disabled", DisabledSeatError)
+	errSeatTaken        = apierror.Conflict("seat_taken", OverlappingSeatError)
+	errSeatHeld         = apierror.Conflict("seat_held", HeldSeatError)
+	errInvalidShowtime  = apierror.Unprocessable("invalid_showtime", InvalidShowtimeError)
+	errInvalidReference = apierror.Unprocessable("invalid_reference", InvalidReferenceError)
+	errBookingNotFound  = apierror.NotFound("booking_not_found", BookingNotFoundError)
+	errBookingOwner     = apierror.Forbidden("booking_owner", BookingOwnerError)
+	errOtherUser        = apierror.Forbidden("other_user", OtherUserError)
+	errOrderPrice       = apierror.Conflict("order_price", OrderPriceError)
+)
+
+type Handler struct {
+	repos    repository.Store
+	holds    config.HoldsConfig
+	pricing  *pricing.Engine
+	payments payments.PaymentProvider
+	refunds  pricing.RefundPolicy
+}
+
+// NewHandler creates a new Handler, bookings are validated against the
+// showtimes and halls of the store, priced by the engine, their orders paid
+// through the provider and refunded following the policy when cancelled
+func NewHandler(repos repository.Store, holds config.HoldsConfig, engine *pricing.Engine, provider payments.PaymentProvider, refunds pricing.RefundPolicy) *Handler {
+	return &Handler{repos: repos, holds: holds, pricing: engine, payments: provider, refunds: refunds}
+}
+
+// seatError returns err naming the seat it is about
+func seatError(err *apierror.Error, seat string) error {
+	return err.WithDetail(seat + ": " + err.Detail)
+}
+
+// ownBooking loads the booking and checks that the caller may act on it:
+// bookings belong to the user they were made for, staff may act on any of them
+func ownBooking(c *gin.Context, bookings repository.BookingRepository, id int) (models.Booking, error) {
+	booking, err := bookings.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return booking, errBookingNotFound.Wrap(err)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return booking, err
+	}
+	if !auth.ActsFor(c, booking.UserID) {
+		return booking, errBookingOwner
 	}
-	c.JSON(http.StatusOK, bookings)
+	return booking, nil
 }
 
-func GetBooking(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// bookingUser returns the user a booking or an order of the request is made
+// for: the caller unless staff name another user
+func bookingUser(c *gin.Context, userID int) (int, error) {
+	if userID == 0 {
+		return int(auth.CurrentUserID(c)), nil
+	}
+	if !auth.ActsFor(c, userID) {
+		return 0, errOtherUser
+	}
+	return userID, nil
+}
+
+// lockShowtime locks the showtime until the end of the transaction, the seats
+// of a showtime are then checked and written by one transaction at a time
+func lockShowtime(tx repository.Store, showtimeID int) error {
+	err := tx.Showtimes().Lock(showtimeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidShowtime.Wrap(err)
+	}
+	return err
+}
+
+// checkSeat verifies that the seat can be booked in the hall of the showtime
+// and that no hold valid at now covers it. It returns the error reported to
+// the client when the seat cannot be booked, or the error of the repository
+// when the check itself failed. The showtime must be locked, bookings of the
+// seat are left to the unique constraint of the database.
+func checkSeat(repos repository.Store, showtimeID int, seat string, now time.Time) error {
+	showtime, err := repos.Showtimes().Get(showtimeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidShowtime.Wrap(err)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidBookingID})
-		return
+		return err
 	}
 
-	var booking models.Booking
-	err = db.Dbx.Get(&booking, "SELECT * FROM bookings WHERE booking_id=$1", id)
+	hall, err := repos.Halls().Get(showtime.HallID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
-	c.JSON(http.StatusOK, booking)
+	if !hall.Layout.HasSeat(seat) {
+		return seatError(errInvalidSeat, seat)
+	}
+	if !hall.Layout.IsBookable(seat) {
+		return seatError(errDisabledSeat, seat)
+	}
+
+	held, err := repos.Holds().IsHeld(showtimeID, seat, now)
+	if err != nil {
+		return err
+	}
+	if held {
+		return seatError(errSeatHeld, seat)
+	}
+	return nil
 }
 
-func CreateBooking(c *gin.Context) {
-	var bookingInput models.BookingInput
-	if err := c.ShouldBindJSON(&bookingInput); err != nil {
-		log.Error("Error binding JSON: ", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// writeError translates the constraint violations of a booking write: a seat
+// booked concurrently is a conflict, a missing user or showtime makes the
+// request unprocessable
+func writeError(err error, seat string) error {
+	switch {
+	case errors.Is(err, repository.ErrDuplicate):
+		return seatError(errSeatTaken, seat)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errInvalidReference.Wrap(err)
+	}
+	return err
+}
+
+// listSpec whitelists the filters and sort fields of the booking list. The
+// bookings are not counted as their history grows without bounds.
+var listSpec = listing.Spec{
+	Filters: map[string]listing.Param{
+		"showtime_id": {Field: "showtime_id", Op: repository.OpEqual, Parse: listing.Int},
+		"user_id":     {Field: "user_id", Op: repository.OpEqual, Parse: listing.Int},
+		"status":      {Field: "status", Op: repository.OpEqual, Parse: listing.String},
+	},
+	Sorts: []string{"booking_id", "showtime_id", "price"},
+}
+
+// myListSpec is listSpec for the bookings of the caller, which are filtered
+// by user already
+var myListSpec = listing.Spec{
+	Filters: map[string]listing.Param{
+		"showtime_id": listSpec.Filters["showtime_id"],
+		"status":      listSpec.Filters["status"],
+	},
+	Sorts: listSpec.Sorts,
+}
+
+// listBookings responds with the page of bookings selected by the query
+// parameters of spec and by filters
+func (h *Handler) listBookings(c *gin.Context, spec listing.Spec, filters ...repository.Filter) {
+	query, err := spec.Parse(c)
+	if err != nil {
+		apierror.Abort(c, err)
 		return
 	}
+	query.Filters = append(query.Filters, filters...)
+	page, err := h.repos.Bookings().ListPage(query)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	listing.Respond(c, page)
+}
+
+func (h *Handler) GetBookings(c *gin.Context) {
+	h.listBookings(c, listSpec)
+}
 
-	if bookingInput.SeatNumber < 1 || bookingInput.SeatNumber > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": SeatNumberOutOfRangeError})
+func (h *Handler) GetBooking(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidBookingID)
 		return
 	}
 
-	var count int
-	err := db.Dbx.Get(&count, "SELECT COUNT(*) FROM bookings WHERE showtime_id=$1 AND seat_number=$2", bookingInput.ShowtimeID, bookingInput.SeatNumber)
+	booking, err := ownBooking(c, h.repos.Bookings(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": OverlappingSeatError})
+	etag.Set(c, booking.Version)
+	c.JSON(http.StatusOK, booking)
+}
+
+// GetMyBookings returns the bookings of the caller, cancelled ones included
+func (h *Handler) GetMyBookings(c *gin.Context) {
+	h.listBookings(c, myListSpec, repository.Filter{Field: "user_id", Op: repository.OpEqual, Value: int(auth.CurrentUserID(c))})
+}
+
+// CreateBooking books a single seat. Two requests for the same seat cannot
+// both succeed: the unique constraint on the seat rejects the second one,
+// which is reported as 409 Conflict.
+func (h *Handler) CreateBooking(c *gin.Context) {
+	var bookingInput models.BookingInput
+	if err := validation.BindJSON(c, &bookingInput); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
 
-	query := `INSERT INTO bookings (user_id, showtime_id, seat_number) VALUES (:user_id, :showtime_id, :seat_number) RETURNING booking_id`
-	rows, err := db.Dbx.NamedQuery(query, &bookingInput)
+	userID, err := bookingUser(c, bookingInput.UserID)
 	if err != nil {
-		log.Error("Error inserting booking: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
 
-	var booking models.Booking
-	if rows.Next() {
-		err = rows.Scan(&booking.BookingID)
+	booking := models.Booking{
+		UserID:     userID,
+		ShowtimeID: bookingInput.ShowtimeID,
+		Seat:       bookingInput.Seat,
+	}
+
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		if err := lockShowtime(tx, booking.ShowtimeID); err != nil {
+			return err
+		}
+		if err := checkSeat(tx, booking.ShowtimeID, booking.Seat, time.Now()); err != nil {
+			return err
+		}
+		quote, err := h.quoteSeats(tx, booking.ShowtimeID, []string{booking.Seat}, map[string]string{booking.Seat: bookingInput.TicketType})
 		if err != nil {
-			log.Error("Error retrieving new booking ID: ", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return err
 		}
+		priceBooking(&booking, quote.Tickets[0])
+		return writeError(tx.Bookings().Create(&booking), booking.Seat)
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
 	}
 
-	booking.UserID = bookingInput.UserID
-	booking.ShowtimeID = bookingInput.ShowtimeID
-	booking.SeatNumber = bookingInput.SeatNumber
-
 	log.Info("Booking created successfully with ID:", booking.BookingID)
+	etag.Set(c, booking.Version)
 	c.JSON(http.StatusCreated, booking)
 }
 
-func UpdateBooking(c *gin.Context) {
+// UpdateBooking moves the booking to another seat or showtime, unless it
+// changed since the client read the version of its If-Match header
+func (h *Handler) UpdateBooking(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidBookingID})
+		apierror.Abort(c, errInvalidBookingID)
 		return
 	}
 
 	var bookingInput models.BookingInput
-	if err := c.BindJSON(&bookingInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := validation.BindJSON(c, &bookingInput); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
+	h.update(c, id, bookingInput, 0)
+}
 
-	if bookingInput.SeatNumber < 1 || bookingInput.SeatNumber > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": SeatNumberOutOfRangeError})
+// PatchBooking applies a JSON merge patch to the booking, which is then moved
+// like UpdateBooking moves it
+func (h *Handler) PatchBooking(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidBookingID)
 		return
 	}
 
-	var count int
-	err = db.Dbx.Get(&count, "SELECT COUNT(*) FROM bookings WHERE showtime_id=$1 AND seat_number=$2 AND booking_id<>$3", bookingInput.ShowtimeID, bookingInput.SeatNumber, id)
+	existing, err := ownBooking(c, h.repos.Bookings(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
+		return
+	}
+	if err := etag.Check(c, existing.Version); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": OverlappingSeatError})
+	bookingInput := models.BookingInput{
+		UserID:     existing.UserID,
+		ShowtimeID: existing.ShowtimeID,
+		Seat:       existing.Seat,
+		TicketType: existing.TicketType,
+	}
+	if err := validation.BindMergePatch(c, &bookingInput); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	h.update(c, id, bookingInput, existing.Version)
+}
+
+// update saves the booking id with the input. The input of a patch was
+// computed from version of the booking, the update then fails with a
+// conflict when the booking changed since.
+func (h *Handler) update(c *gin.Context, id int, bookingInput models.BookingInput, version int) {
+	userID, err := bookingUser(c, bookingInput.UserID)
+	if err != nil {
+		apierror.Abort(c, err)
 		return
 	}
 
 	booking := models.Booking{
 		BookingID:  id,
-		UserID:     bookingInput.UserID,
+		UserID:     userID,
 		ShowtimeID: bookingInput.ShowtimeID,
-		SeatNumber: bookingInput.SeatNumber,
+		Seat:       bookingInput.Seat,
 	}
 
-	_, err = db.Dbx.NamedExec("UPDATE bookings SET user_id=:user_id, showtime_id=:showtime_id, seat_number=:seat_number WHERE booking_id=:booking_id", &booking)
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		if err := lockShowtime(tx, booking.ShowtimeID); err != nil {
+			return err
+		}
+		// cancelled bookings are history and cannot change, neither can
+		// bookings whose ticket was used
+		existing, err := ownBooking(c, tx.Bookings(), id)
+		if err != nil {
+			return err
+		}
+		if existing.Status == models.BookingStatusCancelled {
+			return errBookingCancelled
+		}
+		if existing.CheckedInAt != nil {
+			return errCheckedIn
+		}
+		if err := etag.Check(c, existing.Version); err != nil {
+			return err
+		}
+		if version != 0 && existing.Version != version {
+			return repository.ErrConflict
+		}
+		booking.OrderID = existing.OrderID
+		booking.Status = existing.Status
+		booking.Version = existing.Version
+
+		if err := checkSeat(tx, booking.ShowtimeID, booking.Seat, time.Now()); err != nil {
+			return err
+		}
+		quote, err := h.quoteSeats(tx, booking.ShowtimeID, []string{booking.Seat}, map[string]string{booking.Seat: bookingInput.TicketType})
+		if err != nil {
+			return err
+		}
+		priceBooking(&booking, quote.Tickets[0])
+		// the order was charged its total, nothing would charge or refund
+		// the difference
+		if booking.OrderID != nil && booking.Price != existing.Price {
+			return errOrderPrice
+		}
+		return writeError(tx.Bookings().Update(&booking), booking.Seat)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
+	etag.Set(c, booking.Version)
 	c.JSON(http.StatusOK, booking)
 }
 
-func DeleteBooking(c *gin.Context) {
+func (h *Handler) DeleteBooking(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidBookingID})
+		apierror.Abort(c, errInvalidBookingID)
 		return
 	}
 
-	_, err = db.Dbx.Exec("DELETE FROM bookings WHERE booking_id=$1", id)
+	if _, err := ownBooking(c, h.repos.Bookings(), id); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	err = h.repos.Bookings().Delete(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		apierror.Abort(c, errBookingNotFound.Wrap(err))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{})
@@ -3,62 +3,116 @@ package bookings
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth"
+	"one-way-ticket/config"
 	"one-way-ticket/models"
-	"os"
+	"one-way-ticket/payments"
+	"one-way-ticket/pricing"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/service/listing"
 	"strconv"
 	"testing"
+	"time"
 )
 
-func setupRouter() *gin.Engine {
-	r := gin.Default()
-	r.GET("/bookings", GetBookings)
-	r.GET("/bookings/:id", GetBooking)
-	r.POST("/bookings", CreateBooking)
-	r.PUT("/bookings/:id", UpdateBooking)
-	r.DELETE("/bookings/:id", DeleteBooking)
-	return r
-}
-
-func TestMain(m *testing.M) {
-	err := db.Connect()
+// newStore creates a store holding one movie, one user, one hall and one
+// showtime, all with ID 1. The hall has two rows of ten seats, B5 is an aisle
+// and B1 is disabled.
+func newStore(t *testing.T) *memory.Store {
+	store := memory.NewStore()
+	err := store.Movies().Create(&models.Movie{Title: "Sample Movie", Duration: 120, Genre: "Action"})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create movie: %v", err)
 	}
-	_, err = db.Dbx.Exec("TRUNCATE TABLE bookings, showtimes, movies, users RESTART IDENTITY CASCADE")
+	err = store.Users().Create(&models.User{Username: "testuser", Password: "password", Email: "test@example.com"})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create user: %v", err)
 	}
-	_, err = db.Dbx.Exec("INSERT INTO movies (title, duration, genre) VALUES ('Sample Movie', 120, 'Action')")
+	layout := models.HallLayout{Rows: []models.HallRow{
+		{Label: "A", Seats: 10},
+		{Label: "B", Seats: 10, Aisles: []int{5}, Disabled: []int{1}},
+	}}
+	err = store.Halls().Create(&models.Hall{Name: "Hall 1", Capacity: layout.Capacity(), Layout: layout})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create hall: %v", err)
 	}
-	_, err = db.Dbx.Exec("INSERT INTO users (username, password, email) VALUES ('testuser', 'password', 'test@example.com')")
+	err = store.Showtimes().Create(&models.Showtime{MovieID: 1, Showtime: "2024-05-30 12:00:00", HallID: 1})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create showtime: %v", err)
 	}
-	_, err = db.Dbx.Exec("INSERT INTO showtimes (movie_id, showtime, hall) VALUES (1, '2024-05-30 12:00:00', 'Hall 1')")
+	return store
+}
+
+// testWebhookSecret signs the webhooks of the fake payment provider
+const testWebhookSecret = "test-webhook-secret"
+
+// newHandler creates a Handler holding seats for ten minutes, selling and
+// refunding tickets with the default rules and taking payments with
+// provider, or with a fake provider accepting every payment when provider is
+// nil
+func newHandler(t *testing.T, store repository.Store, provider ...payments.PaymentProvider) *Handler {
+	engine, err := pricing.NewEngine(pricing.DefaultRules)
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create pricing engine: %v", err)
+	}
+	var paymentProvider payments.PaymentProvider = payments.NewFakeProvider(config.FakePaymentsConfig{}, testWebhookSecret)
+	if len(provider) > 0 {
+		paymentProvider = provider[0]
+	}
+	return NewHandler(store, config.HoldsConfig{Duration: 10 * time.Minute}, engine, paymentProvider, pricing.DefaultRefundPolicy)
+}
+
+// actAs authenticates every request as the user userID with role, like the
+// authentication middleware does from the token claims
+func actAs(userID uint, role models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(auth.UserIDKey, userID)
+		c.Set(auth.RoleKey, role)
 	}
-	code := m.Run()
-	err = db.Dbx.Close()
+}
+
+// setupRouter serves the booking routes as the customer with ID 1
+func setupRouter(t *testing.T) (*gin.Engine, repository.BookingRepository) {
+	r, store := setupRouterAs(t, models.RoleCustomer)
+	return r, store.Bookings()
+}
+
+// setupRouterAs serves the booking routes as user 1 with role
+func setupRouterAs(t *testing.T, role models.Role) (*gin.Engine, *memory.Store) {
+	store := newStore(t)
+	handler := newHandler(t, store)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.Use(actAs(1, role))
+	r.GET("/me/bookings", handler.GetMyBookings)
+	r.GET("/bookings", handler.GetBookings)
+	r.GET("/bookings/:id", handler.GetBooking)
+	r.POST("/bookings", handler.CreateBooking)
+	r.PUT("/bookings/:id", handler.UpdateBooking)
+	r.PATCH("/bookings/:id", handler.PatchBooking)
+	r.DELETE("/bookings/:id", handler.DeleteBooking)
+	return r, store
+}
+
+func createBooking(t *testing.T, bookings repository.BookingRepository, seat string) models.Booking {
+	booking := models.Booking{UserID: 1, ShowtimeID: 1, Seat: seat}
+	err := bookings.Create(&booking)
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create booking: %v", err)
 	}
-	os.Exit(code)
+	return booking
 }
 
 func TestGetBookings(t *testing.T) {
-	router := setupRouter()
-
-	// Insert test data
-	db.Dbx.MustExec("INSERT INTO bookings (user_id, showtime_id, seat_number) VALUES (1, 1, 1)")
+	router, bookings := setupRouter(t)
+	createBooking(t, bookings, "A1")
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/bookings", nil)
@@ -66,45 +120,49 @@ func TestGetBookings(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var bookings []models.Booking
-	err := json.Unmarshal(w.Body.Bytes(), &bookings)
+	var list listing.Response[models.Booking]
+	err := json.Unmarshal(w.Body.Bytes(), &list)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, bookings)
+	assert.NotEmpty(t, list.Items)
+	// the booking history is not counted
+	assert.Nil(t, list.Total)
 }
 
 func TestGetBooking(t *testing.T) {
-	router := setupRouter()
-
-	// Insert test data
-	db.Dbx.MustExec("INSERT INTO bookings (user_id, showtime_id, seat_number) VALUES (1, 1, 2)")
-
-	var bookingID int
-	err := db.Dbx.Get(&bookingID, "SELECT booking_id FROM bookings WHERE user_id=1 AND showtime_id=1 AND seat_number=2")
-	if err != nil {
-		t.Fatalf("Failed to get booking ID: %v", err)
-	}
+	router, bookings := setupRouter(t)
+	created := createBooking(t, bookings, "A2")
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/bookings/"+strconv.Itoa(bookingID), nil)
+	req, _ := http.NewRequest("GET", "/bookings/"+strconv.Itoa(created.BookingID), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var booking models.Booking
-	err = json.Unmarshal(w.Body.Bytes(), &booking)
+	err := json.Unmarshal(w.Body.Bytes(), &booking)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, booking.UserID)
 	assert.Equal(t, 1, booking.ShowtimeID)
-	assert.Equal(t, 2, booking.SeatNumber)
+	assert.Equal(t, "A2", booking.Seat)
+}
+
+func TestGetBookingNotFound(t *testing.T) {
+	router, _ := setupRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/bookings/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestCreateBooking(t *testing.T) {
-	router := setupRouter()
+	router, _ := setupRouter(t)
 
 	bookingInput := models.BookingInput{
 		UserID:     1,
 		ShowtimeID: 1,
-		SeatNumber: 3,
+		Seat:       "A3",
 	}
 	jsonValue, _ := json.Marshal(bookingInput)
 
@@ -120,18 +178,17 @@ func TestCreateBooking(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, booking.UserID)
 	assert.Equal(t, 1, booking.ShowtimeID)
-	assert.Equal(t, 3, booking.SeatNumber)
+	assert.Equal(t, "A3", booking.Seat)
 }
 
 func TestCreateBookingOverlap(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO bookings (user_id, showtime_id, seat_number) VALUES (1, 1, 4)")
+	router, bookings := setupRouter(t)
+	createBooking(t, bookings, "A4")
 
 	bookingInput := models.BookingInput{
 		UserID:     1,
 		ShowtimeID: 1,
-		SeatNumber: 4,
+		Seat:       "A4",
 	}
 	jsonValue, _ := json.Marshal(bookingInput)
 
@@ -140,59 +197,312 @@ func TestCreateBookingOverlap(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 	assert.Contains(t, w.Body.String(), OverlappingSeatError)
 }
 
-func TestUpdateBooking(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO bookings (user_id, showtime_id, seat_number) VALUES (1, 1, 10)")
+func TestCreateBookingInvalidSeat(t *testing.T) {
+	tests := []struct {
+		name       string
+		showtimeID int
+		seat       string
+		status     int
+		message    string
+	}{
+		{"Unknown Row", 1, "C1", http.StatusBadRequest, InvalidSeatError},
+		{"Beyond Row", 1, "A11", http.StatusBadRequest, InvalidSeatError},
+		{"Aisle", 1, "B5", http.StatusBadRequest, InvalidSeatError},
+		{"Malformed", 1, "12", http.StatusBadRequest, InvalidSeatError},
+		{"Leading Zero", 1, "A01", http.StatusBadRequest, InvalidSeatError},
+		{"Disabled", 1, "B1", http.StatusBadRequest, DisabledSeatError},
+		{"Unknown Showtime", 2, "A1", http.StatusUnprocessableEntity, InvalidShowtimeError},
+	}
 
-	var bookingID int
-	err := db.Dbx.Get(&bookingID, "SELECT booking_id FROM bookings WHERE user_id=1 AND showtime_id=1 AND seat_number=10")
-	if err != nil {
-		t.Fatalf("Failed to get booking ID: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := setupRouter(t)
+
+			bookingInput := models.BookingInput{
+				UserID:     1,
+				ShowtimeID: tt.showtimeID,
+				Seat:       tt.seat,
+			}
+			jsonValue, _ := json.Marshal(bookingInput)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+		})
 	}
+}
 
-	fmt.Println(bookingID)
+func TestUpdateBooking(t *testing.T) {
+	router, bookings := setupRouter(t)
+	created := createBooking(t, bookings, "A10")
 
 	bookingInput := models.BookingInput{
 		UserID:     1,
 		ShowtimeID: 1,
-		SeatNumber: 6,
+		Seat:       "A6",
 	}
 	jsonValue, _ := json.Marshal(bookingInput)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("PUT", "/bookings/"+strconv.Itoa(bookingID), bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("PUT", "/bookings/"+strconv.Itoa(created.BookingID), bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var booking models.Booking
-	err = json.Unmarshal(w.Body.Bytes(), &booking)
+	err := json.Unmarshal(w.Body.Bytes(), &booking)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, booking.UserID)
 	assert.Equal(t, 1, booking.ShowtimeID)
-	assert.Equal(t, 6, booking.SeatNumber)
+	assert.Equal(t, "A6", booking.Seat)
 }
 
-func TestDeleteBooking(t *testing.T) {
-	router := setupRouter()
+func TestPatchBooking(t *testing.T) {
+	router, bookings := setupRouter(t)
+	created := createBooking(t, bookings, "A10")
 
-	db.Dbx.MustExec("INSERT INTO bookings (user_id, showtime_id, seat_number) VALUES (1, 1, 9)")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/bookings/"+strconv.Itoa(created.BookingID), bytes.NewBufferString(`{"seat":"A6"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
 
-	var bookingID int
-	err := db.Dbx.Get(&bookingID, "SELECT booking_id FROM bookings WHERE user_id=1 AND showtime_id=1 AND seat_number=9")
-	if err != nil {
-		t.Fatalf("Failed to get booking ID: %v", err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	stored, err := bookings.Get(created.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, "A6", stored.Seat)
+	assert.Equal(t, created.ShowtimeID, stored.ShowtimeID)
+}
+
+func TestUpdateBookingStale(t *testing.T) {
+	router, bookings := setupRouter(t)
+	created := createBooking(t, bookings, "A10")
+
+	// somebody else moved the booking since the client read it
+	moved := created
+	moved.Seat = "A8"
+	assert.NoError(t, bookings.Update(&moved))
+
+	jsonValue, _ := json.Marshal(models.BookingInput{UserID: 1, ShowtimeID: 1, Seat: "A6"})
+	for _, method := range []string{"PUT", "PATCH"} {
+		t.Run(method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, "/bookings/"+strconv.Itoa(created.BookingID), bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", `"1"`)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		})
 	}
 
+	stored, err := bookings.Get(created.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, "A8", stored.Seat)
+}
+
+func TestDeleteBooking(t *testing.T) {
+	router, bookings := setupRouter(t)
+	created := createBooking(t, bookings, "A9")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/bookings/"+strconv.Itoa(created.BookingID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := bookings.Get(created.BookingID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestDeleteBookingNotFound(t *testing.T) {
+	router, _ := setupRouter(t)
+
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("DELETE", "/bookings/"+strconv.Itoa(bookingID), nil)
+	req, _ := http.NewRequest("DELETE", "/bookings/42", nil)
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"booking_not_found"`)
+}
+
+func TestCreateBookingForCaller(t *testing.T) {
+	router, store := setupRouterAs(t, models.RoleCustomer)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	assert.NoError(t, err)
+
+	w := postBooking(router, models.BookingInput{ShowtimeID: 1, Seat: "A1"})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var booking models.Booking
+	err = json.Unmarshal(w.Body.Bytes(), &booking)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, booking.UserID)
+
+	w = postBooking(router, models.BookingInput{UserID: 2, ShowtimeID: 1, Seat: "A2"})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), OtherUserError)
+}
+
+func TestBookingOfOtherUser(t *testing.T) {
+	router, store := setupRouterAs(t, models.RoleCustomer)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	assert.NoError(t, err)
+	other := models.Booking{UserID: 2, ShowtimeID: 1, Seat: "A1"}
+	assert.NoError(t, store.Bookings().Create(&other))
+	jsonValue, _ := json.Marshal(models.BookingInput{ShowtimeID: 1, Seat: "A2"})
+
+	tests := []struct {
+		name   string
+		method string
+		body   []byte
+	}{
+		{"Get", "GET", nil},
+		{"Update", "PUT", jsonValue},
+		{"Delete", "DELETE", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/bookings/"+strconv.Itoa(other.BookingID), bytes.NewBuffer(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Contains(t, w.Body.String(), BookingOwnerError)
+		})
+	}
+
+	found, err := store.Bookings().Get(other.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, other, found)
+}
+
+func TestStaffManagesBookingsOfOtherUsers(t *testing.T) {
+	router, store := setupRouterAs(t, models.RoleStaff)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	assert.NoError(t, err)
+
+	w := postBooking(router, models.BookingInput{UserID: 2, ShowtimeID: 1, Seat: "A1"})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var booking models.Booking
+	err = json.Unmarshal(w.Body.Bytes(), &booking)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, booking.UserID)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/bookings/"+strconv.Itoa(booking.BookingID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/bookings/"+strconv.Itoa(booking.BookingID), nil)
+	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusNoContent, w.Code)
 }
+
+func TestGetMyBookings(t *testing.T) {
+	router, store := setupRouterAs(t, models.RoleCustomer)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	assert.NoError(t, err)
+	own := createBooking(t, store.Bookings(), "A1")
+	other := models.Booking{UserID: 2, ShowtimeID: 1, Seat: "A2"}
+	assert.NoError(t, store.Bookings().Create(&other))
+	cancelled := createBooking(t, store.Bookings(), "A3")
+	assert.NoError(t, store.Bookings().Cancel(cancelled.BookingID, "", 0, time.Now()))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me/bookings", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list listing.Response[models.Booking]
+	err = json.Unmarshal(w.Body.Bytes(), &list)
+	assert.NoError(t, err)
+	if assert.Len(t, list.Items, 2) {
+		assert.Equal(t, own.BookingID, list.Items[0].BookingID)
+		assert.Equal(t, cancelled.BookingID, list.Items[1].BookingID)
+		assert.Equal(t, models.BookingStatusCancelled, list.Items[1].Status)
+	}
+
+	// user_id is not a filter of the bookings of the caller
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me/bookings?user_id=2&status=active", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &list)
+	assert.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, own.BookingID, list.Items[0].BookingID)
+	}
+}
+
+func TestGetBookingsPages(t *testing.T) {
+	router, store := setupRouterAs(t, models.RoleStaff)
+	err := store.Showtimes().Create(&models.Showtime{MovieID: 1, Showtime: "2024-05-31 12:00:00", HallID: 1})
+	assert.NoError(t, err)
+	var ids []int
+	for _, seat := range []string{"A1", "A2", "A3"} {
+		ids = append(ids, createBooking(t, store.Bookings(), seat).BookingID)
+	}
+	other := models.Booking{UserID: 1, ShowtimeID: 2, Seat: "A1"}
+	assert.NoError(t, store.Bookings().Create(&other))
+
+	// the pages follow each other through their cursor until the last one
+	var seen []int
+	path := "/bookings?showtime_id=1&sort=-booking_id&limit=2"
+	for page := 0; page < 2; page++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var list listing.Response[models.Booking]
+		err := json.Unmarshal(w.Body.Bytes(), &list)
+		assert.NoError(t, err)
+		for _, booking := range list.Items {
+			seen = append(seen, booking.BookingID)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		path = "/bookings?showtime_id=1&sort=-booking_id&limit=2&cursor=" + list.NextCursor
+	}
+	assert.Equal(t, []int{ids[2], ids[1], ids[0]}, seen)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Unknown Sort Field", "sort=seat"},
+		{"Invalid Filter", "showtime_id=first"},
+		{"Limit Too Large", "limit=1000"},
+		{"Invalid Cursor", "cursor=garbage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/bookings?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package bookings
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/validation"
+)
+
+const (
+	BookingCancelledError = "Booking is cancelled already"
+	UnpaidOrderError      = "Booking belongs to an order waiting for its payment"
+	CheckedInError        = "Ticket of the booking was used already"
+)
+
+var (
+	errBookingCancelled = apierror.Conflict("booking_cancelled", BookingCancelledError)
+	errUnpaidOrder      = apierror.Conflict("unpaid_order", UnpaidOrderError)
+	errCheckedIn        = apierror.Conflict("booking_checked_in", CheckedInError)
+)
+
+// cancellation is a booking cancelled by CancelBooking with the payment its
+// refund goes to, if it was paid
+type cancellation struct {
+	booking   models.Booking
+	paymentID *string
+}
+
+// cancel cancels the active booking id at now and computes its refund from
+// the amount paid for it and the start of its showtime. Only bookings that
+// were paid are refunded, the bookings of a pending order must be released
+// with the order instead. A booking whose ticket was used cannot be cancelled.
+func (h *Handler) cancel(c *gin.Context, tx repository.Store, id int, reason string, now time.Time) (cancellation, error) {
+	booking, err := ownBooking(c, tx.Bookings(), id)
+	if err != nil {
+		return cancellation{}, err
+	}
+	if booking.Status == models.BookingStatusCancelled {
+		return cancellation{}, errBookingCancelled
+	}
+	if booking.CheckedInAt != nil {
+		return cancellation{}, errCheckedIn
+	}
+
+	showtime, err := tx.Showtimes().Get(booking.ShowtimeID)
+	if err != nil {
+		return cancellation{}, err
+	}
+	start, err := showtime.Start()
+	if err != nil {
+		return cancellation{}, err
+	}
+	refund := h.refunds.Refund(booking.Paid(), start, now)
+
+	var paymentID *string
+	if booking.OrderID != nil {
+		order, err := tx.Orders().Get(*booking.OrderID)
+		if err != nil {
+			return cancellation{}, err
+		}
+		if order.Status == models.OrderPending {
+			return cancellation{}, errUnpaidOrder
+		}
+		paymentID = order.PaymentID
+	}
+
+	err = tx.Bookings().Cancel(id, reason, refund, now)
+	if errors.Is(err, repository.ErrConflict) {
+		return cancellation{}, errBookingCancelled.Wrap(err)
+	}
+	if err != nil {
+		return cancellation{}, err
+	}
+	booking, err = tx.Bookings().Get(id)
+	return cancellation{booking: booking, paymentID: paymentID}, err
+}
+
+// CancelBooking cancels a booking and frees its seat. The booking is kept with
+// the amount refunded following the refund policy, which is paid back through
+// the payment provider when the booking was paid with an order.
+func (h *Handler) CancelBooking(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidBookingID)
+		return
+	}
+
+	// the body is optional, it only carries the reason of the cancellation
+	var input models.CancellationInput
+	if c.Request.ContentLength != 0 {
+		if err := validation.BindJSON(c, &input); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+	}
+
+	var cancelled cancellation
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		var err error
+		cancelled, err = h.cancel(c, tx, id, input.Reason, time.Now())
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	booking := cancelled.booking
+	if cancelled.paymentID != nil && *booking.RefundAmount > 0 {
+		// the booking stays cancelled when the refund fails, the amount owed
+		// is recorded with it
+		err := h.payments.Refund(c.Request.Context(), *cancelled.paymentID, *booking.RefundAmount)
+		if err != nil {
+			log.Error("Error refunding cancelled booking ", id, ": ", err)
+		}
+	}
+
+	log.Info("Booking cancelled successfully with ID:", id)
+	c.JSON(http.StatusOK, booking)
+}
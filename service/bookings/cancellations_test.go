@@ -0,0 +1,238 @@
+package bookings
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/config"
+	"one-way-ticket/models"
+	"one-way-ticket/payments"
+	"one-way-ticket/repository/memory"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// setupCancellations serves the booking, order and payment routes as user 1
+// with role
+func setupCancellations(t *testing.T, role models.Role) (*gin.Engine, *memory.Store, *payments.FakeProvider) {
+	store := newStore(t)
+	provider := payments.NewFakeProvider(config.FakePaymentsConfig{}, testWebhookSecret)
+	handler := newHandler(t, store, provider)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.Use(actAs(1, role))
+	r.POST("/bookings", handler.CreateBooking)
+	r.PATCH("/bookings/:id", handler.PatchBooking)
+	r.POST("/bookings/:id/cancel", handler.CancelBooking)
+	r.POST("/orders", handler.CreateOrder)
+	r.POST("/orders/:id/pay", handler.PayOrder)
+	r.POST("/orders/:id/refund", handler.RefundOrder)
+	return r, store, provider
+}
+
+// createShowtimeIn creates a showtime of the test hall starting after d,
+// which is negative for a showtime that started already
+func createShowtimeIn(t *testing.T, store *memory.Store, d time.Duration) models.Showtime {
+	showtime := models.Showtime{MovieID: 1, HallID: 1, Showtime: time.Now().UTC().Add(d).Format("2006-01-02 15:04:05")}
+	if err := store.Showtimes().Create(&showtime); err != nil {
+		t.Fatalf("Failed to create showtime: %v", err)
+	}
+	return showtime
+}
+
+func postBooking(router *gin.Engine, input models.BookingInput) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func postCancel(router *gin.Engine, bookingID int, reason string) *httptest.ResponseRecorder {
+	var body *bytes.Buffer
+	if reason != "" {
+		jsonValue, _ := json.Marshal(models.CancellationInput{Reason: reason})
+		body = bytes.NewBuffer(jsonValue)
+	} else {
+		body = &bytes.Buffer{}
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/bookings/"+strconv.Itoa(bookingID)+"/cancel", body)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCancelBooking(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  time.Duration
+		refund func(price int) int
+	}{
+		{"Days Before", 72 * time.Hour, func(price int) int { return price }},
+		{"Hours Before", 2 * time.Hour, func(price int) int { return (price*50 + 50) / 100 }},
+		{"Started", -time.Hour, func(price int) int { return 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store, _ := setupCancellations(t, models.RoleCustomer)
+			showtime := createShowtimeIn(t, store, tt.start)
+			w := postBooking(router, models.BookingInput{UserID: 1, ShowtimeID: showtime.ShowtimeID, Seat: "A1"})
+			assert.Equal(t, http.StatusCreated, w.Code)
+			var booking models.Booking
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &booking))
+			assert.Equal(t, models.BookingStatusActive, booking.Status)
+
+			w = postCancel(router, booking.BookingID, "Plans changed")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var cancelled models.Booking
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cancelled))
+			assert.Equal(t, models.BookingStatusCancelled, cancelled.Status)
+			assert.Equal(t, "Plans changed", cancelled.CancellationReason)
+			assert.NotNil(t, cancelled.CancelledAt)
+			if assert.NotNil(t, cancelled.RefundAmount) {
+				assert.Equal(t, tt.refund(booking.Price), *cancelled.RefundAmount)
+			}
+
+			// the booking is kept and its seat can be booked again
+			found, err := store.Bookings().Get(booking.BookingID)
+			assert.NoError(t, err)
+			assert.Equal(t, models.BookingStatusCancelled, found.Status)
+			w = postBooking(router, models.BookingInput{UserID: 1, ShowtimeID: showtime.ShowtimeID, Seat: "A1"})
+			assert.Equal(t, http.StatusCreated, w.Code)
+		})
+	}
+}
+
+func TestCancelBookingRejected(t *testing.T) {
+	router, store, _ := setupCancellations(t, models.RoleCustomer)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	assert.NoError(t, err)
+	showtime := createShowtimeIn(t, store, 72*time.Hour)
+	other := models.Booking{UserID: 2, ShowtimeID: showtime.ShowtimeID, Seat: "A2"}
+	assert.NoError(t, store.Bookings().Create(&other))
+	order := createPendingOrder(t, router)
+
+	w := postCancel(router, other.BookingID, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), BookingOwnerError)
+
+	w = postCancel(router, order.Bookings[0].BookingID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), UnpaidOrderError)
+
+	w = postCancel(router, 42, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	booking := createBooking(t, store.Bookings(), "A9")
+	w = postCancel(router, booking.BookingID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postCancel(router, booking.BookingID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), BookingCancelledError)
+}
+
+func TestUpdateCancelledBooking(t *testing.T) {
+	router, bookings := setupRouter(t)
+	created := createBooking(t, bookings, "A9")
+	assert.NoError(t, bookings.Cancel(created.BookingID, "", 0, time.Now()))
+
+	jsonValue, _ := json.Marshal(models.BookingInput{UserID: 1, ShowtimeID: 1, Seat: "A6"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/bookings/"+strconv.Itoa(created.BookingID), bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), BookingCancelledError)
+}
+
+func TestCancelPaidBooking(t *testing.T) {
+	router, store, provider := setupCancellations(t, models.RoleStaff)
+	showtime := createShowtimeIn(t, store, 72*time.Hour)
+	w := postOrder(router, models.OrderInput{UserID: 1, ShowtimeID: showtime.ShowtimeID, Seats: []string{"A1", "A2"}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var order models.Order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	w = postOrderAction(router, order.OrderID, "pay")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	w = postCancel(router, order.Bookings[0].BookingID, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, order.Bookings[0].Price, provider.Refunded(*order.PaymentID))
+
+	// refunding the order pays back the rest only
+	w = postOrderAction(router, order.OrderID, "refund")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, order.Amount, provider.Refunded(*order.PaymentID))
+}
+
+func TestRepricePaidBooking(t *testing.T) {
+	router, store, provider := setupCancellations(t, models.RoleCustomer)
+	showtime := createShowtimeIn(t, store, 72*time.Hour)
+	w := postOrder(router, models.OrderInput{ShowtimeID: showtime.ShowtimeID, Seats: []string{"A1", "A2"},
+		TicketTypes: map[string]string{"A1": "child", "A2": "child"}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var order models.Order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	w = postOrderAction(router, order.OrderID, "pay")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	booking := order.Bookings[0]
+
+	// an adult ticket costs more than what was paid for the child ticket
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/bookings/"+strconv.Itoa(booking.BookingID), bytes.NewBufferString(`{"ticket_type":"adult"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", "*")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), OrderPriceError)
+
+	// the refund is computed from what was paid, whatever the price says
+	stored, err := store.Bookings().Get(booking.BookingID)
+	assert.NoError(t, err)
+	stored.Price *= 2
+	assert.NoError(t, store.Bookings().Update(&stored))
+	w = postCancel(router, booking.BookingID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, booking.Price, provider.Refunded(*order.PaymentID))
+}
+
+func TestCheckedInBooking(t *testing.T) {
+	router, store, _ := setupCancellations(t, models.RoleCustomer)
+	showtime := createShowtimeIn(t, store, 30*time.Minute)
+	w := postBooking(router, models.BookingInput{ShowtimeID: showtime.ShowtimeID, Seat: "A1"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var booking models.Booking
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &booking))
+	assert.NoError(t, store.Bookings().CheckIn(booking.BookingID, time.Now()))
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/bookings/"+strconv.Itoa(booking.BookingID), bytes.NewBufferString(`{"seat":"A2"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", "*")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), CheckedInError)
+
+	w = postCancel(router, booking.BookingID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), CheckedInError)
+
+	found, err := store.Bookings().Get(booking.BookingID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusActive, found.Status)
+	assert.Equal(t, "A1", found.Seat)
+}
@@ -0,0 +1,130 @@
+package bookings
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/config"
+	"one-way-ticket/db"
+	"one-way-ticket/db/migrations"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/postgres"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrentRequests is the number of parallel bookings of the same seat
+const concurrentRequests = 300
+
+// testConcurrentBookings books seat A1 of the showtime from many goroutines at
+// once: exactly one request must succeed and every other one must conflict
+func testConcurrentBookings(t *testing.T, store repository.Store, userID, showtimeID int) {
+	handler := newHandler(t, store)
+	r := gin.New()
+	r.Use(apierror.Middleware())
+	r.Use(actAs(uint(userID), models.RoleCustomer))
+	r.POST("/bookings", handler.CreateBooking)
+	r.POST("/orders", handler.CreateOrder)
+
+	booking, _ := json.Marshal(models.BookingInput{UserID: userID, ShowtimeID: showtimeID, Seat: "A1"})
+	order, _ := json.Marshal(models.OrderInput{UserID: userID, ShowtimeID: showtimeID, Seats: []string{"A2", "A1"}})
+
+	statuses := make(chan int, concurrentRequests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentRequests; i++ {
+		// every tenth request is an order also claiming the seat
+		path, body := "/bookings", booking
+		if i%10 == 0 {
+			path, body = "/orders", order
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			statuses <- w.Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, map[int]int{http.StatusCreated: 1, http.StatusConflict: concurrentRequests - 1}, counts)
+
+	count, err := store.Bookings().CountForSeat(showtimeID, "A1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestConcurrentBookings(t *testing.T) {
+	testConcurrentBookings(t, newStore(t), 1, 1)
+}
+
+func TestConcurrentBookingsPostgres(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("database is not configured")
+	}
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Connect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// stay below the connection limit of the server
+	db.Dbx.SetMaxOpenConns(20)
+
+	migrator, err := migrations.New(db.Dbx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fixtures are unique to the run as the tables are shared with other tests
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	store := postgres.NewStore(db.Dbx)
+	user := models.User{Username: "concurrent", Password: "password", Email: "concurrent-" + suffix + "@example.com", Role: models.RoleCustomer}
+	movie := models.Movie{Title: "Concurrent Movie", Duration: 120, Genre: "Action"}
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10}}}
+	hall := models.Hall{Name: "Concurrent Hall " + suffix, Capacity: layout.Capacity(), Layout: layout}
+	var showtime models.Showtime
+	err = store.WithTx(func(tx repository.Store) error {
+		if err := tx.Users().Create(&user); err != nil {
+			return err
+		}
+		if err := tx.Movies().Create(&movie); err != nil {
+			return err
+		}
+		if err := tx.Halls().Create(&hall); err != nil {
+			return err
+		}
+		showtime = models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: hall.HallID}
+		return tx.Showtimes().Create(&showtime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testConcurrentBookings(t, store, int(user.ID), showtime.ShowtimeID)
+}
@@ -0,0 +1,222 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/validation"
+)
+
+const (
+	InvalidHoldID     = "Invalid hold ID"
+	InvalidShowtimeID = "Invalid showtime ID"
+	HoldNotFoundError = "Hold not found"
+	HoldOwnerError    = "Hold belongs to another user"
+	HoldExpiredError  = "Hold has expired"
+)
+
+var (
+	errInvalidHoldID     = apierror.Validation("invalid_hold_id", InvalidHoldID)
+	errInvalidShowtimeID = apierror.Validation("invalid_showtime_id", InvalidShowtimeID)
+	errShowtimeNotFound  = apierror.NotFound("showtime_not_found", InvalidShowtimeError)
+	errHoldNotFound      = apierror.NotFound("hold_not_found", HoldNotFoundError)
+	errHoldOwner         = apierror.Forbidden("hold_owner", HoldOwnerError)
+	errHoldExpired       = apierror.Gone("hold_expired", HoldExpiredError)
+)
+
+// ownHold loads the hold and checks that the caller may act on it: holds
+// belong to the user who created them, staff may act on any of them
+func ownHold(c *gin.Context, holds repository.HoldRepository, id int) (models.Hold, error) {
+	hold, err := holds.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return hold, errHoldNotFound.Wrap(err)
+	}
+	if err != nil {
+		return hold, err
+	}
+	if !auth.ActsFor(c, hold.UserID) {
+		return hold, errHoldOwner
+	}
+	return hold, nil
+}
+
+// CreateHold reserves the seats of the showtime for the caller during the
+// configured hold duration. Either every seat is held or none is.
+func (h *Handler) CreateHold(c *gin.Context) {
+	showtimeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidShowtimeID)
+		return
+	}
+
+	var holdInput models.HoldInput
+	if err := validation.BindJSON(c, &holdInput); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	now := time.Now()
+	hold := models.Hold{
+		UserID:     int(auth.CurrentUserID(c)),
+		ShowtimeID: showtimeID,
+		Seats:      holdInput.Seats,
+		ExpiresAt:  now.Add(h.holds.Duration),
+	}
+
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		err := tx.Showtimes().Lock(showtimeID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errShowtimeNotFound.Wrap(err)
+		}
+		if err != nil {
+			return err
+		}
+
+		// expired holds keep their seats until they are deleted
+		if _, err := tx.Holds().DeleteExpired(now); err != nil {
+			return err
+		}
+
+		if err := checkSeats(tx, showtimeID, hold.Seats, now); err != nil {
+			return err
+		}
+		// no constraint spans bookings and holds, booked seats are found
+		// while the showtime is locked
+		for _, seat := range hold.Seats {
+			count, err := tx.Bookings().CountForSeat(showtimeID, seat, 0)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return seatError(errSeatTaken, seat)
+			}
+		}
+
+		err = tx.Holds().Create(&hold)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return errSeatHeld.Wrap(err)
+		}
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	log.Info("Hold created successfully with ID:", hold.HoldID)
+	c.JSON(http.StatusCreated, hold)
+}
+
+func (h *Handler) GetHold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidHoldID)
+		return
+	}
+
+	hold, err := ownHold(c, h.repos.Holds(), id)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+// ConfirmHold books every seat of the hold as one order and deletes the
+// hold, in a single transaction. The seats stay reserved by the pending
+// order until it is paid or expires.
+func (h *Handler) ConfirmHold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidHoldID)
+		return
+	}
+
+	// the body is optional, without it every seat is sold as an adult ticket
+	var confirmInput models.ConfirmHoldInput
+	if c.Request.ContentLength != 0 {
+		if err := validation.BindJSON(c, &confirmInput); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+	}
+
+	var order models.Order
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		hold, err := ownHold(c, tx.Holds(), id)
+		if err != nil {
+			return err
+		}
+		if hold.Expired(time.Now()) {
+			return errHoldExpired
+		}
+
+		quote, err := h.quoteSeats(tx, hold.ShowtimeID, hold.Seats, confirmInput.TicketTypes)
+		if err != nil {
+			return err
+		}
+		order = models.Order{UserID: hold.UserID, ShowtimeID: hold.ShowtimeID}
+		if err := bookSeats(tx, &order, quote, time.Now().Add(h.holds.Duration)); err != nil {
+			return err
+		}
+		return tx.Holds().Delete(id)
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	log.Info("Hold confirmed successfully with ID:", id)
+	c.JSON(http.StatusCreated, order)
+}
+
+// ReleaseHold deletes the hold, making its seats available again
+func (h *Handler) ReleaseHold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidHoldID)
+		return
+	}
+
+	_, err = ownHold(c, h.repos.Holds(), id)
+	if err == nil {
+		err = h.repos.Holds().Delete(id)
+	}
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+// StartHoldReaper deletes expired holds every interval until ctx is done
+func StartHoldReaper(ctx context.Context, holds repository.HoldRepository, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				deleted, err := holds.DeleteExpired(now)
+				if err != nil {
+					log.Error("Error deleting expired holds: ", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Info("Released expired holds: ", deleted)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,236 @@
+package bookings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// setupHolds serves the hold and booking routes as customer 1, a second
+// customer with ID 2 owns the holds created by createHold with user 2
+func setupHolds(t *testing.T) (*gin.Engine, *memory.Store) {
+	store := newStore(t)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	handler := newHandler(t, store)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.Use(actAs(1, models.RoleCustomer))
+	r.POST("/bookings", handler.CreateBooking)
+	r.POST("/showtimes/:id/holds", handler.CreateHold)
+	r.GET("/holds/:id", handler.GetHold)
+	r.POST("/holds/:id/confirm", handler.ConfirmHold)
+	r.DELETE("/holds/:id", handler.ReleaseHold)
+	return r, store
+}
+
+func createHold(t *testing.T, holds repository.HoldRepository, userID int, expiresAt time.Time, seats ...string) models.Hold {
+	hold := models.Hold{UserID: userID, ShowtimeID: 1, Seats: seats, ExpiresAt: expiresAt}
+	err := holds.Create(&hold)
+	if err != nil {
+		t.Fatalf("Failed to create hold: %v", err)
+	}
+	return hold
+}
+
+func postHold(router *gin.Engine, showtimeID int, seats ...string) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(models.HoldInput{Seats: seats})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/showtimes/"+strconv.Itoa(showtimeID)+"/holds", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateHold(t *testing.T) {
+	router, store := setupHolds(t)
+
+	w := postHold(router, 1, "A1", "A2")
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var hold models.Hold
+	err := json.Unmarshal(w.Body.Bytes(), &hold)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hold.UserID)
+	assert.Equal(t, []string{"A1", "A2"}, hold.Seats)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), hold.ExpiresAt, time.Minute)
+
+	held, err := store.Holds().IsHeld(1, "A2", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, held)
+}
+
+func TestCreateHoldRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		showtimeID int
+		seats      []string
+		status     int
+		message    string
+	}{
+		{"Booked Seat", 1, []string{"A1", "A3"}, http.StatusConflict, OverlappingSeatError},
+		{"Held Seat", 1, []string{"A4"}, http.StatusConflict, HeldSeatError},
+		{"Disabled Seat", 1, []string{"B1"}, http.StatusBadRequest, DisabledSeatError},
+		{"Unknown Seat", 1, []string{"C1"}, http.StatusBadRequest, InvalidSeatError},
+		{"Seat Listed Twice", 1, []string{"A1", "A1"}, http.StatusBadRequest, DuplicateSeatError},
+		{"Unknown Showtime", 2, []string{"A1"}, http.StatusNotFound, InvalidShowtimeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store := setupHolds(t)
+			createBooking(t, store.Bookings(), "A3")
+			createHold(t, store.Holds(), 2, time.Now().Add(time.Minute), "A4")
+
+			w := postHold(router, tt.showtimeID, tt.seats...)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+
+			// nothing is held when a seat is rejected
+			held, err := store.Holds().IsHeld(1, "A1", time.Now())
+			assert.NoError(t, err)
+			assert.False(t, held)
+		})
+	}
+}
+
+func TestCreateHoldReplacesExpiredHold(t *testing.T) {
+	router, store := setupHolds(t)
+	expired := createHold(t, store.Holds(), 2, time.Now().Add(-time.Minute), "A1")
+
+	w := postHold(router, 1, "A1")
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	_, err := store.Holds().Get(expired.HoldID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestCreateBookingHeldSeat(t *testing.T) {
+	router, store := setupHolds(t)
+	createHold(t, store.Holds(), 2, time.Now().Add(time.Minute), "A1")
+
+	jsonValue, _ := json.Marshal(models.BookingInput{UserID: 1, ShowtimeID: 1, Seat: "A1"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), HeldSeatError)
+}
+
+func TestGetHold(t *testing.T) {
+	router, store := setupHolds(t)
+	own := createHold(t, store.Holds(), 1, time.Now().Add(time.Minute), "A1")
+	other := createHold(t, store.Holds(), 2, time.Now().Add(time.Minute), "A2")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/holds/"+strconv.Itoa(own.HoldID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/holds/"+strconv.Itoa(other.HoldID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/holds/42", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestConfirmHold(t *testing.T) {
+	router, store := setupHolds(t)
+	hold := createHold(t, store.Holds(), 1, time.Now().Add(time.Minute), "A1", "A2")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/holds/"+strconv.Itoa(hold.HoldID)+"/confirm", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &order)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, order.UserID)
+	if assert.Len(t, order.Bookings, 2) {
+		assert.Equal(t, "A1", order.Bookings[0].Seat)
+		assert.Equal(t, "A2", order.Bookings[1].Seat)
+		assert.Equal(t, &order.OrderID, order.Bookings[0].OrderID)
+	}
+
+	_, err = store.Holds().Get(hold.HoldID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestConfirmHoldRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    int
+		expiresAt time.Time
+		status    int
+	}{
+		{"Expired", 1, time.Now().Add(-time.Minute), http.StatusGone},
+		{"Other User", 2, time.Now().Add(time.Minute), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store := setupHolds(t)
+			hold := createHold(t, store.Holds(), tt.userID, tt.expiresAt, "A1")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/holds/"+strconv.Itoa(hold.HoldID)+"/confirm", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			list, err := store.Bookings().List()
+			assert.NoError(t, err)
+			assert.Empty(t, list)
+		})
+	}
+}
+
+func TestReleaseHold(t *testing.T) {
+	router, store := setupHolds(t)
+	hold := createHold(t, store.Holds(), 1, time.Now().Add(time.Minute), "A1")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/holds/"+strconv.Itoa(hold.HoldID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err := store.Holds().Get(hold.HoldID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestStartHoldReaper(t *testing.T) {
+	store := newStore(t)
+	hold := createHold(t, store.Holds(), 1, time.Now().Add(-time.Minute), "A1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartHoldReaper(ctx, store.Holds(), 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		_, err := store.Holds().Get(hold.HoldID)
+		return err == repository.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,146 @@
+package bookings
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/service/listing"
+	"one-way-ticket/validation"
+)
+
+const (
+	InvalidOrderID        = "Invalid order ID"
+	DuplicateSeatError    = "Seat is listed more than once"
+	InvalidReferenceError = "User or showtime does not exist"
+)
+
+var (
+	errInvalidOrderID = apierror.Validation("invalid_order_id", InvalidOrderID)
+	errDuplicateSeat  = apierror.Validation("duplicate_seat", DuplicateSeatError)
+)
+
+// checkSeats verifies every seat with checkSeat and reports the first one
+// that cannot be booked. The showtime must be locked.
+func checkSeats(repos repository.Store, showtimeID int, seats []string, now time.Time) error {
+	for i, seat := range seats {
+		for _, previous := range seats[:i] {
+			if previous == seat {
+				return seatError(errDuplicateSeat, seat)
+			}
+		}
+		if err := checkSeat(repos, showtimeID, seat, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bookSeats creates the order and one booking per ticket of the quote, it
+// must run in a transaction so that no booking is kept when a seat is taken.
+// The order is pending until it is paid, its seats are released when it is
+// still pending at expiresAt.
+func bookSeats(tx repository.Store, order *models.Order, quote models.Quote, expiresAt time.Time) error {
+	order.Status = models.OrderPending
+	order.Amount = quote.Total
+	order.Currency = quote.Currency
+	order.ExpiresAt = &expiresAt
+	err := tx.Orders().Create(order)
+	if err != nil {
+		return writeError(err, "")
+	}
+
+	order.Bookings = make([]models.Booking, 0, len(quote.Tickets))
+	for _, ticket := range quote.Tickets {
+		booking := models.Booking{UserID: order.UserID, ShowtimeID: order.ShowtimeID, Seat: ticket.Seat, OrderID: &order.OrderID}
+		priceBooking(&booking, ticket)
+		err := tx.Bookings().Create(&booking)
+		if err != nil {
+			return writeError(err, ticket.Seat)
+		}
+		order.Bookings = append(order.Bookings, booking)
+	}
+	return nil
+}
+
+// orderListSpec whitelists the filters and sort fields of the order list. The
+// orders are not counted as they grow with the history of the bookings.
+var orderListSpec = listing.Spec{
+	Filters: map[string]listing.Param{
+		"showtime_id": {Field: "showtime_id", Op: repository.OpEqual, Parse: listing.Int},
+		"user_id":     {Field: "user_id", Op: repository.OpEqual, Parse: listing.Int},
+		"status":      {Field: "status", Op: repository.OpEqual, Parse: listing.String},
+	},
+	Sorts: []string{"order_id", "created_at", "amount"},
+}
+
+func (h *Handler) GetOrders(c *gin.Context) {
+	query, err := orderListSpec.Parse(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	page, err := h.repos.Orders().ListPage(query)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	listing.Respond(c, page)
+}
+
+func (h *Handler) GetOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidOrderID)
+		return
+	}
+
+	order, err := ownOrder(c, h.repos.Orders(), id)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// CreateOrder books every seat of the request for one showtime, either all
+// of them are booked or none is. The order then waits for its payment.
+func (h *Handler) CreateOrder(c *gin.Context) {
+	var orderInput models.OrderInput
+	if err := validation.BindJSON(c, &orderInput); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	userID, err := bookingUser(c, orderInput.UserID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	order := models.Order{UserID: userID, ShowtimeID: orderInput.ShowtimeID}
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		if err := lockShowtime(tx, orderInput.ShowtimeID); err != nil {
+			return err
+		}
+		if err := checkSeats(tx, orderInput.ShowtimeID, orderInput.Seats, time.Now()); err != nil {
+			return err
+		}
+		quote, err := h.quoteSeats(tx, orderInput.ShowtimeID, orderInput.Seats, orderInput.TicketTypes)
+		if err != nil {
+			return err
+		}
+		return bookSeats(tx, &order, quote, time.Now().Add(h.holds.Duration))
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	log.Info("Order created successfully with ID:", order.OrderID)
+	c.JSON(http.StatusCreated, order)
+}
@@ -0,0 +1,149 @@
+package bookings
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/service/listing"
+	"strconv"
+	"testing"
+)
+
+// setupOrders serves the order routes as staff, who may order for any user
+func setupOrders(t *testing.T) (*gin.Engine, *memory.Store) {
+	store := newStore(t)
+	handler := newHandler(t, store)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.Use(actAs(1, models.RoleStaff))
+	r.GET("/orders", handler.GetOrders)
+	r.GET("/orders/:id", handler.GetOrder)
+	r.POST("/orders", handler.CreateOrder)
+	return r, store
+}
+
+func postOrder(router *gin.Engine, input models.OrderInput) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateOrder(t *testing.T) {
+	router, store := setupOrders(t)
+
+	w := postOrder(router, models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: []string{"A1", "A2", "A3", "A4"}})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &order)
+	assert.NoError(t, err)
+	assert.NotZero(t, order.OrderID)
+	assert.Equal(t, models.OrderPending, order.Status)
+	assert.Len(t, order.Bookings, 4)
+	for _, booking := range order.Bookings {
+		assert.Equal(t, &order.OrderID, booking.OrderID)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/orders/"+strconv.Itoa(order.OrderID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var found models.Order
+	err = json.Unmarshal(w.Body.Bytes(), &found)
+	assert.NoError(t, err)
+	assert.Equal(t, order.Bookings, found.Bookings)
+
+	list, err := store.Bookings().List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 4)
+}
+
+func TestCreateOrderIsAtomic(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   models.OrderInput
+		status  int
+		message string
+	}{
+		{"Booked Seat", models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: []string{"A1", "A2", "A9"}}, http.StatusConflict, OverlappingSeatError},
+		{"Disabled Seat", models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: []string{"A1", "B1"}}, http.StatusBadRequest, DisabledSeatError},
+		{"Seat Listed Twice", models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: []string{"A1", "A2", "A1"}}, http.StatusBadRequest, DuplicateSeatError},
+		{"Unknown Showtime", models.OrderInput{UserID: 1, ShowtimeID: 2, Seats: []string{"A1"}}, http.StatusUnprocessableEntity, InvalidShowtimeError},
+		{"Unknown User", models.OrderInput{UserID: 2, ShowtimeID: 1, Seats: []string{"A1"}}, http.StatusUnprocessableEntity, InvalidReferenceError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store := setupOrders(t)
+			createBooking(t, store.Bookings(), "A9")
+
+			w := postOrder(router, tt.input)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+
+			list, err := store.Bookings().List()
+			assert.NoError(t, err)
+			assert.Len(t, list, 1)
+			orders, err := store.Orders().List()
+			assert.NoError(t, err)
+			assert.Empty(t, orders)
+		})
+	}
+}
+
+func TestGetOrders(t *testing.T) {
+	router, _ := setupOrders(t)
+	for _, seats := range [][]string{{"A1"}, {"A2", "A3"}, {"A4"}} {
+		w := postOrder(router, models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: seats})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/orders?sort=-order_id&limit=2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page listing.Response[models.Order]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, 3, page.Items[0].OrderID)
+		assert.Equal(t, 2, page.Items[1].OrderID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Nil(t, page.Total)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/orders?sort=-order_id&limit=2&cursor="+page.NextCursor, nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	page = listing.Response[models.Order]{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, 1, page.Items[0].OrderID)
+	}
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	router, _ := setupOrders(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/orders/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
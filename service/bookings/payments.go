@@ -0,0 +1,312 @@
+package bookings
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth"
+	"one-way-ticket/models"
+	"one-way-ticket/payments"
+	"one-way-ticket/repository"
+)
+
+const (
+	OrderNotFoundError   = "Order not found"
+	OrderOwnerError      = "Order belongs to another user"
+	OrderNotPendingError = "Order is not waiting for its payment"
+	OrderNotPaidError    = "Order is not paid"
+	OrderExpiredError    = "Order has expired"
+	PaymentDeclinedError = "Payment was declined"
+	PaymentProviderError = "Payment provider is unavailable"
+	InvalidWebhookError  = "Invalid webhook signature"
+	InvalidEventError    = "Invalid webhook event"
+)
+
+var (
+	errOrderNotFound    = apierror.NotFound("order_not_found", OrderNotFoundError)
+	errOrderOwner       = apierror.Forbidden("order_owner", OrderOwnerError)
+	errOrderNotPending  = apierror.Conflict("order_not_pending", OrderNotPendingError)
+	errOrderNotPaid     = apierror.Conflict("order_not_paid", OrderNotPaidError)
+	errOrderExpired     = apierror.Gone("order_expired", OrderExpiredError)
+	errPaymentDeclined  = apierror.New(http.StatusPaymentRequired, "payment_declined", PaymentDeclinedError)
+	errPaymentProvider  = apierror.New(http.StatusBadGateway, "payment_provider_unavailable", PaymentProviderError)
+	errInvalidSignature = apierror.Unauthorized("invalid_webhook_signature", InvalidWebhookError)
+	errInvalidEvent     = apierror.Validation("invalid_webhook_event", InvalidEventError)
+)
+
+// SignatureHeader carries the signature of the webhooks of the provider
+const SignatureHeader = "X-Signature"
+
+// ownOrder loads the order and checks that the caller may act on it: orders
+// belong to the user who placed them, staff may act on any of them
+func ownOrder(c *gin.Context, orders repository.OrderRepository, id int) (models.Order, error) {
+	order, err := orders.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return order, errOrderNotFound.Wrap(err)
+	}
+	if err != nil {
+		return order, err
+	}
+	if !auth.ActsFor(c, order.UserID) {
+		return order, errOrderOwner
+	}
+	return order, nil
+}
+
+// refundedOrderReason is the reason of the bookings cancelled by the refund
+// of their order
+const refundedOrderReason = "The order was refunded"
+
+// releaseOrder moves the order from status from to to and frees its seats so
+// that they can be sold again. The bookings of a failed order were never paid
+// and are deleted, those of a refunded order are kept as cancelled with a
+// full refund.
+func releaseOrder(repos repository.Store, orderID int, from, to models.OrderStatus) error {
+	return repos.WithTx(func(tx repository.Store) error {
+		if err := tx.Orders().Transition(orderID, from, to, nil); err != nil {
+			return err
+		}
+		if to != models.OrderRefunded {
+			return tx.Bookings().DeleteForOrder(orderID)
+		}
+		order, err := tx.Orders().Get(orderID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, booking := range order.Bookings {
+			if booking.Status != models.BookingStatusActive {
+				continue
+			}
+			if err := tx.Bookings().Cancel(booking.BookingID, refundedOrderReason, booking.Paid(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// unrefunded returns the amount paid for the bookings of the order that are
+// still active, cancelled bookings were refunded following the refund policy
+// already
+func unrefunded(order models.Order) int {
+	amount := 0
+	for _, booking := range order.Bookings {
+		if booking.Status == models.BookingStatusActive {
+			amount += booking.Paid()
+		}
+	}
+	return amount
+}
+
+// PayOrder takes the payment of a pending order. The amount is authorized
+// and captured before the order is marked as paid; when the order expired in
+// the meantime the payment is refunded. A declined payment fails the order
+// and releases its seats.
+func (h *Handler) PayOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidOrderID)
+		return
+	}
+
+	order, err := ownOrder(c, h.repos.Orders(), id)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	if order.Status != models.OrderPending {
+		apierror.Abort(c, errOrderNotPending)
+		return
+	}
+	if order.Expired(time.Now()) {
+		err := releaseOrder(h.repos, id, models.OrderPending, models.OrderFailed)
+		if err != nil && !errors.Is(err, repository.ErrConflict) {
+			apierror.Abort(c, err)
+			return
+		}
+		apierror.Abort(c, errOrderExpired)
+		return
+	}
+
+	ctx := c.Request.Context()
+	paymentID, err := h.payments.Authorize(ctx, payments.Charge{OrderID: order.OrderID, Amount: order.Amount, Currency: order.Currency})
+	if err == nil {
+		err = h.payments.Capture(ctx, paymentID)
+	}
+	if errors.Is(err, payments.ErrDeclined) {
+		err = releaseOrder(h.repos, id, models.OrderPending, models.OrderFailed)
+		if err != nil && !errors.Is(err, repository.ErrConflict) {
+			apierror.Abort(c, err)
+			return
+		}
+		apierror.Abort(c, errPaymentDeclined)
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, errPaymentProvider.Wrap(err))
+		return
+	}
+
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		if err := tx.Orders().Transition(id, models.OrderPending, models.OrderPaid, &paymentID); err != nil {
+			return err
+		}
+		return tx.Bookings().RecordPayment(id)
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		// the order failed while it was being paid, its seats may be sold
+		// again so the money goes back
+		if err := h.payments.Refund(context.Background(), paymentID, order.Amount); err != nil {
+			log.Error("Error refunding payment ", paymentID, ": ", err)
+		}
+		apierror.Abort(c, errOrderNotPending.Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	order, err = h.repos.Orders().Get(id)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	log.Info("Order paid successfully with ID:", id)
+	c.JSON(http.StatusOK, order)
+}
+
+// RefundOrder pays back the bookings of a paid order that are still active
+// and releases their seats
+func (h *Handler) RefundOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidOrderID)
+		return
+	}
+
+	order, err := h.repos.Orders().Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		apierror.Abort(c, errOrderNotFound.Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	if order.Status != models.OrderPaid || order.PaymentID == nil {
+		apierror.Abort(c, errOrderNotPaid)
+		return
+	}
+
+	// the provider refuses to refund more than was paid, which also stops a
+	// concurrent second refund of the order
+	if amount := unrefunded(order); amount > 0 {
+		err = h.payments.Refund(c.Request.Context(), *order.PaymentID, amount)
+		if errors.Is(err, payments.ErrInvalidState) {
+			apierror.Abort(c, errOrderNotPaid.Wrap(err))
+			return
+		}
+		if err != nil {
+			apierror.Abort(c, errPaymentProvider.Wrap(err))
+			return
+		}
+	}
+
+	err = releaseOrder(h.repos, id, models.OrderPaid, models.OrderRefunded)
+	if err != nil && !errors.Is(err, repository.ErrConflict) {
+		apierror.Abort(c, err)
+		return
+	}
+
+	order, err = h.repos.Orders().Get(id)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	log.Info("Order refunded successfully with ID:", id)
+	c.JSON(http.StatusOK, order)
+}
+
+// PaymentWebhook applies the changes the provider reports for a payment.
+// Events that do not change the order, e.g. a repeated delivery, are
+// acknowledged as well so that the provider stops sending them.
+func (h *Handler) PaymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		apierror.Abort(c, apierror.InvalidBody(err))
+		return
+	}
+
+	event, err := h.payments.VerifyWebhook(payload, c.GetHeader(SignatureHeader))
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		apierror.Abort(c, errInvalidSignature.Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, errInvalidEvent.Wrap(err))
+		return
+	}
+
+	order, err := h.repos.Orders().GetByPayment(event.PaymentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		apierror.Abort(c, errOrderNotFound.Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	switch event.Type {
+	case payments.EventFailed:
+		err = releaseOrder(h.repos, order.OrderID, models.OrderPending, models.OrderFailed)
+	case payments.EventRefunded:
+		err = releaseOrder(h.repos, order.OrderID, models.OrderPaid, models.OrderRefunded)
+	}
+	if err != nil && !errors.Is(err, repository.ErrConflict) {
+		apierror.Abort(c, err)
+		return
+	}
+
+	log.Info("Payment webhook ", event.Type, " applied to order ", order.OrderID)
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+// StartOrderReaper fails the orders still pending after their expiry every
+// interval, releasing their seats, until ctx is cancelled
+func StartOrderReaper(ctx context.Context, repos repository.Store, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				expired, err := repos.Orders().ListExpired(now)
+				if err != nil {
+					log.Error("Error listing expired orders: ", err)
+					continue
+				}
+				for _, order := range expired {
+					err := releaseOrder(repos, order.OrderID, models.OrderPending, models.OrderFailed)
+					if err != nil && !errors.Is(err, repository.ErrConflict) {
+						log.Error("Error failing expired order: ", err)
+					}
+				}
+				if len(expired) > 0 {
+					log.Info("Failed expired orders: ", len(expired))
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,193 @@
+package bookings
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/config"
+	"one-way-ticket/models"
+	"one-way-ticket/payments"
+	"one-way-ticket/repository/memory"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// setupPayments serves the order and payment routes as user 1 with role,
+// payments are taken by a fake provider declining failureRate of them
+func setupPayments(t *testing.T, failureRate float64, role models.Role) (*gin.Engine, *memory.Store, *payments.FakeProvider) {
+	store := newStore(t)
+	provider := payments.NewFakeProvider(config.FakePaymentsConfig{FailureRate: failureRate}, testWebhookSecret)
+	handler := newHandler(t, store, provider)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.Use(actAs(1, role))
+	r.POST("/orders", handler.CreateOrder)
+	r.POST("/orders/:id/pay", handler.PayOrder)
+	r.POST("/orders/:id/refund", handler.RefundOrder)
+	r.POST("/payments/webhook", handler.PaymentWebhook)
+	return r, store, provider
+}
+
+func createPendingOrder(t *testing.T, router *gin.Engine) models.Order {
+	w := postOrder(router, models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: []string{"A1", "A2"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create order: %s", w.Body.String())
+	}
+	var order models.Order
+	if err := json.Unmarshal(w.Body.Bytes(), &order); err != nil {
+		t.Fatalf("Failed to decode order: %v", err)
+	}
+	return order
+}
+
+func postOrderAction(router *gin.Engine, orderID int, action string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/orders/"+strconv.Itoa(orderID)+"/"+action, nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func postWebhook(router *gin.Engine, event payments.Event, secret string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(event)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/payments/webhook", bytes.NewBuffer(payload))
+	req.Header.Set(SignatureHeader, payments.Sign(payload, secret))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPayOrder(t *testing.T) {
+	router, store, _ := setupPayments(t, 0, models.RoleCustomer)
+	order := createPendingOrder(t, router)
+	assert.Equal(t, models.OrderPending, order.Status)
+	assert.Equal(t, "EUR", order.Currency)
+	assert.Positive(t, order.Amount)
+
+	w := postOrderAction(router, order.OrderID, "pay")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var paid models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &paid)
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderPaid, paid.Status)
+	assert.NotNil(t, paid.PaymentID)
+
+	w = postOrderAction(router, order.OrderID, "pay")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	list, err := store.Bookings().List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+}
+
+func TestPayOrderDeclined(t *testing.T) {
+	router, store, _ := setupPayments(t, 1, models.RoleCustomer)
+	order := createPendingOrder(t, router)
+
+	w := postOrderAction(router, order.OrderID, "pay")
+
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	assert.Contains(t, w.Body.String(), PaymentDeclinedError)
+
+	failed, err := store.Orders().Get(order.OrderID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderFailed, failed.Status)
+	list, err := store.Bookings().List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestPayOrderExpired(t *testing.T) {
+	router, store, _ := setupPayments(t, 0, models.RoleCustomer)
+	expiresAt := time.Now().Add(-time.Minute)
+	order := models.Order{UserID: 1, ShowtimeID: 1, Status: models.OrderPending, Amount: 1200, Currency: "EUR", ExpiresAt: &expiresAt}
+	err := store.Orders().Create(&order)
+	assert.NoError(t, err)
+
+	w := postOrderAction(router, order.OrderID, "pay")
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	failed, err := store.Orders().Get(order.OrderID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderFailed, failed.Status)
+}
+
+func TestPayOrderOfOtherUser(t *testing.T) {
+	router, store, _ := setupPayments(t, 0, models.RoleCustomer)
+	err := store.Users().Create(&models.User{Username: "other", Password: "password", Email: "other@example.com"})
+	assert.NoError(t, err)
+	expiresAt := time.Now().Add(time.Minute)
+	order := models.Order{UserID: 2, ShowtimeID: 1, Status: models.OrderPending, Amount: 1200, Currency: "EUR", ExpiresAt: &expiresAt}
+	err = store.Orders().Create(&order)
+	assert.NoError(t, err)
+
+	w := postOrderAction(router, order.OrderID, "pay")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), OrderOwnerError)
+}
+
+func TestRefundOrder(t *testing.T) {
+	router, store, provider := setupPayments(t, 0, models.RoleStaff)
+	order := createPendingOrder(t, router)
+
+	w := postOrderAction(router, order.OrderID, "refund")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postOrderAction(router, order.OrderID, "pay")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postOrderAction(router, order.OrderID, "refund")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var refunded models.Order
+	err := json.Unmarshal(w.Body.Bytes(), &refunded)
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderRefunded, refunded.Status)
+	assert.Equal(t, refunded.Amount, provider.Refunded(*refunded.PaymentID))
+	for _, booking := range refunded.Bookings {
+		assert.Equal(t, models.BookingStatusCancelled, booking.Status)
+		assert.Equal(t, booking.Price, *booking.RefundAmount)
+	}
+	active, err := store.Bookings().ListForShowtime(1)
+	assert.NoError(t, err)
+	assert.Empty(t, active)
+
+	w = postOrderAction(router, order.OrderID, "refund")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	router, store, _ := setupPayments(t, 0, models.RoleCustomer)
+	order := createPendingOrder(t, router)
+	w := postOrderAction(router, order.OrderID, "pay")
+	assert.Equal(t, http.StatusOK, w.Code)
+	paid, err := store.Orders().Get(order.OrderID)
+	assert.NoError(t, err)
+	event := payments.Event{Type: payments.EventRefunded, PaymentID: *paid.PaymentID}
+
+	w = postWebhook(router, event, "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postWebhook(router, payments.Event{Type: payments.EventRefunded, PaymentID: "unknown"}, testWebhookSecret)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = postWebhook(router, event, testWebhookSecret)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	refunded, err := store.Orders().Get(order.OrderID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderRefunded, refunded.Status)
+	active, err := store.Bookings().ListForShowtime(1)
+	assert.NoError(t, err)
+	assert.Empty(t, active)
+
+	// a repeated delivery is acknowledged
+	w = postWebhook(router, event, testWebhookSecret)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,137 @@
+package bookings
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/pricing"
+	"one-way-ticket/repository"
+	"one-way-ticket/validation"
+)
+
+const (
+	UnknownTicketTypeError = "Unknown ticket type"
+	UnpricedCategoryError  = "Seat category has no price"
+	TicketTypeSeatError    = "Ticket type is given for a seat that is not requested"
+)
+
+var (
+	errUnknownTicketType = apierror.Validation("unknown_ticket_type", UnknownTicketTypeError)
+	errUnpricedCategory  = apierror.Unprocessable("unpriced_category", UnpricedCategoryError)
+	errTicketTypeSeat    = apierror.Validation("ticket_type_seat", TicketTypeSeatError)
+)
+
+// quoteSeats prices the seats of the showtime, the seats missing from
+// ticketTypes are sold as models.DefaultTicketType
+func (h *Handler) quoteSeats(repos repository.Store, showtimeID int, seats []string, ticketTypes map[string]string) (models.Quote, error) {
+	showtime, err := repos.Showtimes().Get(showtimeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Quote{}, errInvalidShowtime.Wrap(err)
+	}
+	if err != nil {
+		return models.Quote{}, err
+	}
+	start, err := showtime.Start()
+	if err != nil {
+		return models.Quote{}, err
+	}
+	hall, err := repos.Halls().Get(showtime.HallID)
+	if err != nil {
+		return models.Quote{}, err
+	}
+	first, err := repos.Showtimes().FirstShowing(showtime.MovieID)
+	if err != nil {
+		return models.Quote{}, err
+	}
+	showing := pricing.Showing{Start: start, OpeningNight: sameDay(first, start)}
+
+	for seat := range ticketTypes {
+		if !containsSeat(seats, seat) {
+			return models.Quote{}, seatError(errTicketTypeSeat, seat)
+		}
+	}
+
+	quote := models.Quote{ShowtimeID: showtimeID, Currency: h.pricing.Currency(), Tickets: []models.TicketQuote{}}
+	for _, seat := range seats {
+		category := hall.Layout.SeatCategory(seat)
+		if category == "" {
+			return models.Quote{}, seatError(errInvalidSeat, seat)
+		}
+		ticketType := ticketTypes[seat]
+		if ticketType == "" {
+			ticketType = models.DefaultTicketType
+		}
+
+		price, err := h.pricing.Price(showing, ticketType, category)
+		switch {
+		case errors.Is(err, pricing.ErrUnknownTicketType):
+			return models.Quote{}, seatError(errUnknownTicketType, seat)
+		case errors.Is(err, pricing.ErrUnknownCategory):
+			return models.Quote{}, seatError(errUnpricedCategory, seat)
+		case err != nil:
+			return models.Quote{}, err
+		}
+		quote.Tickets = append(quote.Tickets, models.TicketQuote{Seat: seat, TicketType: ticketType, Category: category, Price: price})
+		quote.Total += price.Total
+	}
+	return quote, nil
+}
+
+// priceBooking stores the price of the ticket on the booking
+func priceBooking(booking *models.Booking, ticket models.TicketQuote) {
+	price := ticket.Price
+	booking.TicketType = ticket.TicketType
+	booking.Price = price.Total
+	booking.PriceBreakdown = &price
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func containsSeat(seats []string, seat string) bool {
+	for _, s := range seats {
+		if s == seat {
+			return true
+		}
+	}
+	return false
+}
+
+// QuoteShowtime prices seats of the showtime without booking them, the seats
+// do not need to be free
+func (h *Handler) QuoteShowtime(c *gin.Context) {
+	showtimeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidShowtimeID)
+		return
+	}
+
+	var quoteInput models.QuoteInput
+	if err := validation.BindJSON(c, &quoteInput); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	_, err = h.repos.Showtimes().Get(showtimeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		apierror.Abort(c, errShowtimeNotFound.Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	quote, err := h.quoteSeats(h.repos, showtimeID, quoteInput.Seats, quoteInput.TicketTypes)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, quote)
+}
@@ -0,0 +1,94 @@
+package bookings
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"testing"
+)
+
+func postQuote(router *gin.Engine, showtimeID string, input models.QuoteInput) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/showtimes/"+showtimeID+"/quote", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestQuoteShowtime(t *testing.T) {
+	store := newStore(t)
+	router := gin.Default()
+	router.Use(apierror.Middleware())
+	router.POST("/showtimes/:id/quote", newHandler(t, store).QuoteShowtime)
+
+	// the showtime is a Thursday matinee on the first day of the movie
+	w := postQuote(router, "1", models.QuoteInput{Seats: []string{"A1", "A2"}, TicketTypes: map[string]string{"A2": "child"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var quote models.Quote
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &quote))
+	assert.Equal(t, "EUR", quote.Currency)
+	if assert.Len(t, quote.Tickets, 2) {
+		assert.Equal(t, models.TicketQuote{Seat: "A1", TicketType: "adult", Category: "standard", Price: models.PriceBreakdown{
+			Currency: "EUR",
+			Base:     1200,
+			Adjustments: []models.PriceAdjustment{
+				{Rule: "matinee", Amount: -240},
+				{Rule: "opening_night", Amount: 144},
+			},
+			Total: 1104,
+		}}, quote.Tickets[0])
+		assert.Equal(t, "child", quote.Tickets[1].TicketType)
+		assert.Equal(t, 662, quote.Tickets[1].Price.Total)
+	}
+	assert.Equal(t, 1766, quote.Total)
+
+	tests := []struct {
+		name     string
+		showtime string
+		input    models.QuoteInput
+		status   int
+		message  string
+	}{
+		{"Unknown Ticket Type", "1", models.QuoteInput{Seats: []string{"A1"}, TicketTypes: map[string]string{"A1": "pensioner"}}, http.StatusBadRequest, UnknownTicketTypeError},
+		{"Type Of Other Seat", "1", models.QuoteInput{Seats: []string{"A1"}, TicketTypes: map[string]string{"A2": "child"}}, http.StatusBadRequest, TicketTypeSeatError},
+		{"Invalid Seat", "1", models.QuoteInput{Seats: []string{"Z1"}}, http.StatusBadRequest, InvalidSeatError},
+		{"Unknown Showtime", "2", models.QuoteInput{Seats: []string{"A1"}}, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postQuote(router, tt.showtime, tt.input)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+		})
+	}
+}
+
+func TestCreateOrderPrices(t *testing.T) {
+	router, store := setupOrders(t)
+
+	w := postOrder(router, models.OrderInput{UserID: 1, ShowtimeID: 1, Seats: []string{"A1", "A2"}, TicketTypes: map[string]string{"A2": "senior"}})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var order models.Order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	if assert.Len(t, order.Bookings, 2) {
+		assert.Equal(t, "adult", order.Bookings[0].TicketType)
+		assert.Equal(t, 1104, order.Bookings[0].Price)
+		assert.Equal(t, "senior", order.Bookings[1].TicketType)
+		assert.Equal(t, 773, order.Bookings[1].Price)
+	}
+
+	booking, err := store.Bookings().Get(order.Bookings[1].BookingID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, booking.PriceBreakdown) {
+		assert.Equal(t, booking.Price, booking.PriceBreakdown.Total)
+		assert.Len(t, booking.PriceBreakdown.Adjustments, 3)
+	}
+}
@@ -0,0 +1,50 @@
+// Package etag exposes the version of records as their entity tag, so that
+// clients update a record only if nobody changed it since they read it: they
+// send the ETag back in If-Match and a stale write fails with 412
+// Precondition Failed. A write without If-Match fails with 428 Precondition
+// Required, clients that mean to overwrite the record send If-Match: *.
+package etag
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+)
+
+var (
+	errPreconditionFailed = apierror.New(http.StatusPreconditionFailed, "precondition_failed",
+		"Resource was changed since it was read, fetch it again and retry")
+	errPreconditionRequired = apierror.New(http.StatusPreconditionRequired, "precondition_required",
+		"Send the ETag of the resource in If-Match, or * to overwrite it")
+)
+
+// Of returns the entity tag of a record at version
+func Of(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Set writes the entity tag of the record of the response
+func Set(c *gin.Context, version int) {
+	c.Header("ETag", Of(version))
+}
+
+// Check compares the If-Match header of the request with the record at
+// version, and fails when it lists other tags only or is missing, * matches
+// every version. Weak tags never match as If-Match compares tags strongly.
+func Check(c *gin.Context, version int) error {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return errPreconditionRequired
+	}
+	current := Of(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return nil
+		}
+	}
+	return errPreconditionFailed
+}
@@ -0,0 +1,43 @@
+package etag
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		err     error
+	}{
+		{"No If-Match", "", errPreconditionRequired},
+		{"Current", `"3"`, nil},
+		{"Stale", `"2"`, errPreconditionFailed},
+		{"Any", "*", nil},
+		{"List", `"1", "3"`, nil},
+		{"Weak", `W/"3"`, errPreconditionFailed},
+		{"Unquoted", "3", errPreconditionFailed},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest("PUT", "/", nil)
+			if tt.ifMatch != "" {
+				c.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			err := Check(c, 3)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package halls
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/service/etag"
+	"one-way-ticket/service/listing"
+	"one-way-ticket/validation"
+)
+
+var log = logrus.New()
+
+const (
+	InvalidHallID     = "Invalid hall ID"
+	HallNotFoundError = "Hall not found"
+	DuplicateHallName = "A hall with this name already exists"
+	HallInUseError    = "Hall has showtimes and cannot be deleted"
+	BookedSeatError   = "Layout removes or disables a booked seat"
+	OverlapError      = "Buffers make showtimes of the hall overlap"
+)
+
+var (
+	errInvalidHallID = apierror.Validation("invalid_hall_id", InvalidHallID)
+	errHallNotFound  = apierror.NotFound("hall_not_found", HallNotFoundError)
+	errDuplicateHall = apierror.Conflict("hall_exists", DuplicateHallName)
+	errHallInUse     = apierror.Conflict("hall_in_use", HallInUseError)
+	errBookedSeat    = apierror.Conflict("booked_seat", BookedSeatError)
+	errOverlap       = apierror.Conflict("showtime_overlap", OverlapError)
+)
+
+type Handler struct {
+	repos repository.Store
+}
+
+// NewHandler creates a new Handler, the halls of the store are changed
+// against their showtimes and bookings
+func NewHandler(repos repository.Store) *Handler {
+	return &Handler{repos: repos}
+}
+
+// hallError reports the errors of the hall repository
+func hallError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return errHallNotFound.Wrap(err)
+	case errors.Is(err, repository.ErrDuplicate):
+		return errDuplicateHall.Wrap(err)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errHallInUse.Wrap(err)
+	}
+	return err
+}
+
+// listSpec whitelists the sort fields of the hall list
+var listSpec = listing.Spec{
+	Sorts: []string{"hall_id", "name", "capacity"},
+	Count: true,
+}
+
+func (h *Handler) GetHalls(c *gin.Context) {
+	query, err := listSpec.Parse(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	page, err := h.repos.Halls().ListPage(query)
+	if err != nil {
+		apierror.Abort(c, hallError(err))
+		return
+	}
+	listing.Respond(c, page)
+}
+
+func (h *Handler) GetHall(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidHallID)
+		return
+	}
+
+	hall, err := h.repos.Halls().Get(id)
+	if err != nil {
+		apierror.Abort(c, hallError(err))
+		return
+	}
+	etag.Set(c, hall.Version)
+	c.JSON(http.StatusOK, hall)
+}
+
+func (h *Handler) CreateHall(c *gin.Context) {
+	var hallInput models.HallInput
+	if err := validation.BindJSON(c, &hallInput); err != nil {
+		log.Error("Error binding JSON: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+	hall, err := newHall(hallInput)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	err = h.repos.Halls().Create(&hall)
+	if err != nil {
+		log.Error("Error inserting hall: ", err)
+		apierror.Abort(c, hallError(err))
+		return
+	}
+
+	log.Info("Hall created successfully with ID:", hall.HallID)
+	etag.Set(c, hall.Version)
+	c.JSON(http.StatusCreated, hall)
+}
+
+// UpdateHall replaces the hall, unless it changed since the client read the
+// version of its If-Match header
+func (h *Handler) UpdateHall(c *gin.Context) {
+	var hallInput models.HallInput
+	if err := validation.BindJSON(c, &hallInput); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	h.update(c, func(models.Hall) (models.HallInput, error) {
+		return hallInput, nil
+	})
+}
+
+// PatchHall applies a JSON merge patch to the hall, which is then changed
+// like UpdateHall changes it
+func (h *Handler) PatchHall(c *gin.Context) {
+	patch, err := validation.ReadMergePatch(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	h.update(c, func(existing models.Hall) (models.HallInput, error) {
+		hallInput := models.HallInput{
+			Name:            existing.Name,
+			Layout:          existing.Layout,
+			TrailerMinutes:  &existing.TrailerMinutes,
+			CleaningMinutes: &existing.CleaningMinutes,
+		}
+		return hallInput, validation.MergePatch(patch, &hallInput)
+	})
+}
+
+// update changes the hall of the request to the input returned by hallInput
+// for the stored hall, unless the hall changed since the client read the
+// version of its If-Match header or its showtimes cannot follow the change
+func (h *Handler) update(c *gin.Context, hallInput func(existing models.Hall) (models.HallInput, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidHallID)
+		return
+	}
+
+	var hall models.Hall
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		// showtimes of the hall are not scheduled while it changes
+		if err := tx.Halls().Lock(id); err != nil {
+			return err
+		}
+		existing, err := tx.Halls().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := etag.Check(c, existing.Version); err != nil {
+			return err
+		}
+		input, err := hallInput(existing)
+		if err != nil {
+			return err
+		}
+		hall, err = newHall(input)
+		if err != nil {
+			return err
+		}
+		hall.HallID = id
+		hall.Version = existing.Version
+		if err := tx.Halls().Update(&hall); err != nil {
+			return err
+		}
+		return checkShowtimes(tx, hall, time.Now())
+	})
+	if err != nil {
+		apierror.Abort(c, hallError(err))
+		return
+	}
+	etag.Set(c, hall.Version)
+	c.JSON(http.StatusOK, hall)
+}
+
+func (h *Handler) DeleteHall(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidHallID)
+		return
+	}
+
+	err = h.repos.Halls().Delete(id)
+	if err != nil {
+		apierror.Abort(c, hallError(err))
+		return
+	}
+	c.JSON(http.StatusNoContent, gin.H{})
+}
+
+// checkShowtimes refuses the change of the hall, already saved in the
+// transaction, when a showtime that is not over at now cannot follow it: one
+// of its booked seats is removed or disabled, or the buffers of the hall make
+// it overlap another showtime
+func checkShowtimes(tx repository.Store, hall models.Hall, now time.Time) error {
+	showtimes, err := tx.Showtimes().ListForHall(hall.HallID, now)
+	if err != nil {
+		return err
+	}
+	for _, showtime := range showtimes {
+		bookings, err := tx.Bookings().ListForShowtime(showtime.ShowtimeID)
+		if err != nil {
+			return err
+		}
+		for _, booking := range bookings {
+			if !hall.Layout.IsBookable(booking.Seat) {
+				return errBookedSeat.WithDetail(fmt.Sprintf("Seat %s is booked for showtime %d and cannot be removed or disabled", booking.Seat, showtime.ShowtimeID))
+			}
+		}
+
+		start, err := showtime.Start()
+		if err != nil {
+			return err
+		}
+		movie, err := tx.Movies().Get(showtime.MovieID)
+		if err != nil {
+			return err
+		}
+		overlapping, err := tx.Showtimes().ListOverlapping(hall.HallID, start, hall.ShowtimeEnd(start, movie.Duration), showtime.ShowtimeID)
+		if err != nil {
+			return err
+		}
+		if len(overlapping) > 0 {
+			return errOverlap.WithDetail(fmt.Sprintf("Buffers make showtimes %d and %d of the hall overlap", showtime.ShowtimeID, overlapping[0].ShowtimeID))
+		}
+	}
+	return nil
+}
+
+// newHall validates the layout of the input and returns the hall it
+// describes, the buffers the input leaves out take their default
+func newHall(hallInput models.HallInput) (models.Hall, error) {
+	if err := hallInput.Layout.Validate(); err != nil {
+		return models.Hall{}, apierror.Validation("invalid_layout", err.Error())
+	}
+
+	hall := models.Hall{
+		Name:            hallInput.Name,
+		Capacity:        hallInput.Layout.Capacity(),
+		Layout:          hallInput.Layout,
+		TrailerMinutes:  models.DefaultTrailerMinutes,
+		CleaningMinutes: models.DefaultCleaningMinutes,
+	}
+	if hallInput.TrailerMinutes != nil {
+		hall.TrailerMinutes = *hallInput.TrailerMinutes
+	}
+	if hallInput.CleaningMinutes != nil {
+		hall.CleaningMinutes = *hallInput.CleaningMinutes
+	}
+	return hall, nil
+}
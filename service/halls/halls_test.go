@@ -0,0 +1,365 @@
+package halls
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/service/listing"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupRouter() (*gin.Engine, *memory.Store) {
+	store := memory.NewStore()
+	handler := NewHandler(store)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.GET("/halls", handler.GetHalls)
+	r.GET("/halls/:id", handler.GetHall)
+	r.POST("/halls", handler.CreateHall)
+	r.PUT("/halls/:id", handler.UpdateHall)
+	r.PATCH("/halls/:id", handler.PatchHall)
+	r.DELETE("/halls/:id", handler.DeleteHall)
+	return r, store
+}
+
+var testLayout = models.HallLayout{Rows: []models.HallRow{
+	{Label: "A", Seats: 10},
+	{Label: "B", Seats: 12, Aisles: []int{6}, Disabled: []int{1}},
+}}
+
+func createHall(t *testing.T, halls repository.HallRepository) models.Hall {
+	hall := models.Hall{Name: "Hall 1", Capacity: testLayout.Capacity(), Layout: testLayout}
+	err := halls.Create(&hall)
+	if err != nil {
+		t.Fatalf("Failed to create hall: %v", err)
+	}
+	return hall
+}
+
+// sendHall sends the hall input, with ifMatch as If-Match unless it is empty
+func sendHall(router *gin.Engine, method, path, ifMatch string, input interface{}) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetHalls(t *testing.T) {
+	router, store := setupRouter()
+	createHall(t, store.Halls())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/halls", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page listing.Response[models.Hall]
+	err := json.Unmarshal(w.Body.Bytes(), &page)
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	if assert.NotNil(t, page.Total) {
+		assert.Equal(t, 1, *page.Total)
+	}
+}
+
+func TestGetHall(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/halls/"+strconv.Itoa(created.HallID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	var hall models.Hall
+	err := json.Unmarshal(w.Body.Bytes(), &hall)
+	assert.NoError(t, err)
+	assert.Equal(t, created, hall)
+}
+
+func TestCreateHall(t *testing.T) {
+	router, _ := setupRouter()
+
+	w := sendHall(router, "POST", "/halls", "", models.HallInput{Name: "Hall 1", Layout: testLayout})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var hall models.Hall
+	err := json.Unmarshal(w.Body.Bytes(), &hall)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hall 1", hall.Name)
+	// 10 seats in row A, 12 positions minus an aisle and a disabled seat in row B
+	assert.Equal(t, 20, hall.Capacity)
+	assert.Equal(t, models.DefaultTrailerMinutes, hall.TrailerMinutes)
+	assert.Equal(t, models.DefaultCleaningMinutes, hall.CleaningMinutes)
+}
+
+func TestCreateHallBuffers(t *testing.T) {
+	router, _ := setupRouter()
+
+	trailers, cleaning := 0, 25
+	w := sendHall(router, "POST", "/halls", "", models.HallInput{Name: "Hall 1", Layout: testLayout, TrailerMinutes: &trailers, CleaningMinutes: &cleaning})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var hall models.Hall
+	err := json.Unmarshal(w.Body.Bytes(), &hall)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, hall.TrailerMinutes)
+	assert.Equal(t, 25, hall.CleaningMinutes)
+
+	cleaning = -5
+	w = sendHall(router, "POST", "/halls", "", models.HallInput{Name: "Hall 2", Layout: testLayout, CleaningMinutes: &cleaning})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateHallInvalidLayout(t *testing.T) {
+	router, _ := setupRouter()
+
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10, Aisles: []int{11}}}}
+	w := sendHall(router, "POST", "/halls", "", models.HallInput{Name: "Hall 1", Layout: layout})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "outside of row A")
+}
+
+func TestCreateHallTooLarge(t *testing.T) {
+	tests := []struct {
+		name  string
+		input models.HallInput
+		err   string
+	}{
+		{"Long Name", models.HallInput{Name: strings.Repeat("H", 51), Layout: testLayout}, "name must be at most 50 characters long"},
+		{"Long Row", models.HallInput{Name: "Hall 1", Layout: models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 2000000000}}}}, "row A must have at most 100 seats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store := setupRouter()
+
+			w := sendHall(router, "POST", "/halls", "", tt.input)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.err)
+			halls, err := store.Halls().List()
+			assert.NoError(t, err)
+			assert.Empty(t, halls)
+		})
+	}
+}
+
+func TestCreateHallDuplicateName(t *testing.T) {
+	router, store := setupRouter()
+	createHall(t, store.Halls())
+
+	w := sendHall(router, "POST", "/halls", "", models.HallInput{Name: "Hall 1", Layout: testLayout})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), DuplicateHallName)
+}
+
+func TestUpdateHall(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 8}}}
+	w := sendHall(router, "PUT", "/halls/"+strconv.Itoa(created.HallID), `"1"`, models.HallInput{Name: "Small Hall", Layout: layout})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	stored, err := store.Halls().Get(created.HallID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Small Hall", stored.Name)
+	assert.Equal(t, 8, stored.Capacity)
+}
+
+func TestPatchHall(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/halls/"+strconv.Itoa(created.HallID), bytes.NewBufferString(`{"cleaning_minutes":20}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	stored, err := store.Halls().Get(created.HallID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hall 1", stored.Name)
+	assert.Equal(t, testLayout, stored.Layout)
+	assert.Equal(t, 0, stored.TrailerMinutes)
+	assert.Equal(t, 20, stored.CleaningMinutes)
+	assert.Equal(t, 2, stored.Version)
+}
+
+func TestPatchHallInvalid(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"Required Field Removed", `{"name":null}`, "invalid_fields"},
+		{"Broken Rule", `{"trailer_minutes":-1}`, "invalid_fields"},
+		{"Invalid Layout", `{"layout":{"rows":[{"label":"a","seats":10}]}}`, "invalid_layout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PATCH", "/halls/"+strconv.Itoa(created.HallID), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.Header.Set("If-Match", `"1"`)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+
+	stored, err := store.Halls().Get(created.HallID)
+	assert.NoError(t, err)
+	assert.Equal(t, created, stored)
+}
+
+func TestUpdateHallStale(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+
+	// somebody else updated the hall since the client read it
+	changed := created
+	changed.CleaningMinutes = 20
+	assert.NoError(t, store.Halls().Update(&changed))
+
+	for _, method := range []string{"PUT", "PATCH"} {
+		t.Run(method, func(t *testing.T) {
+			jsonValue, _ := json.Marshal(models.HallInput{Name: "Small Hall", Layout: testLayout})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, "/halls/"+strconv.Itoa(created.HallID), bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", `"1"`)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"precondition_failed"`)
+		})
+	}
+
+	stored, err := store.Halls().Get(created.HallID)
+	assert.NoError(t, err)
+	assert.Equal(t, changed, stored)
+}
+
+func TestUpdateHallShowtimes(t *testing.T) {
+	minutes := func(n int) *int { return &n }
+	tests := []struct {
+		name   string
+		input  models.HallInput
+		status int
+		err    string
+	}{
+		{"Booked Seat Removed", models.HallInput{Name: "Hall 1", Layout: models.HallLayout{Rows: []models.HallRow{
+			{Label: "A", Seats: 9},
+			{Label: "B", Seats: 12, Aisles: []int{6}, Disabled: []int{1}},
+		}}, TrailerMinutes: minutes(0), CleaningMinutes: minutes(0)}, http.StatusConflict, "Seat A10 is booked"},
+		{"Booked Seat Disabled", models.HallInput{Name: "Hall 1", Layout: models.HallLayout{Rows: []models.HallRow{
+			{Label: "A", Seats: 10, Disabled: []int{10}},
+			{Label: "B", Seats: 12, Aisles: []int{6}, Disabled: []int{1}},
+		}}, TrailerMinutes: minutes(0), CleaningMinutes: minutes(0)}, http.StatusConflict, "Seat A10 is booked"},
+		{"Seat Of Past Showtime Removed", models.HallInput{Name: "Hall 1", Layout: models.HallLayout{Rows: []models.HallRow{
+			{Label: "A", Seats: 10},
+			{Label: "B", Seats: 11, Aisles: []int{6}, Disabled: []int{1}},
+		}}, TrailerMinutes: minutes(0), CleaningMinutes: minutes(0)}, http.StatusOK, ""},
+		{"Overlapping Buffers", models.HallInput{Name: "Hall 1", Layout: testLayout, TrailerMinutes: minutes(15), CleaningMinutes: minutes(15)}, http.StatusConflict, "overlap"},
+		{"Fitting Buffers", models.HallInput{Name: "Hall 1", Layout: testLayout, TrailerMinutes: minutes(5), CleaningMinutes: minutes(5)}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store := setupRouter()
+			// the hall has no buffers, the showtimes of tomorrow are 10 minutes
+			// apart, a hall sent without buffers gets the default ones
+			hall := createHall(t, store.Halls())
+			assert.NoError(t, store.Users().Create(&models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}))
+			movie := models.Movie{Title: "Inception", Duration: 120, Genre: "Sci-Fi"}
+			assert.NoError(t, store.Movies().Create(&movie))
+			tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+			yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+			for _, booked := range []struct{ start, seat string }{
+				{tomorrow + " 12:00", "A10"},
+				{tomorrow + " 14:10", "A1"},
+				{yesterday + " 12:00", "B12"},
+			} {
+				showtime := models.Showtime{MovieID: movie.MovieID, Showtime: booked.start, HallID: hall.HallID}
+				assert.NoError(t, store.Showtimes().Create(&showtime))
+				assert.NoError(t, store.Bookings().Create(&models.Booking{UserID: 1, ShowtimeID: showtime.ShowtimeID, Seat: booked.seat}))
+			}
+
+			w := sendHall(router, "PUT", "/halls/"+strconv.Itoa(hall.HallID), `"1"`, tt.input)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.err)
+			stored, err := store.Halls().Get(hall.HallID)
+			assert.NoError(t, err)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.input.Layout, stored.Layout)
+			} else {
+				assert.Equal(t, hall, stored)
+			}
+		})
+	}
+}
+
+func TestDeleteHall(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/halls/"+strconv.Itoa(created.HallID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := store.Halls().Get(created.HallID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestDeleteHallWithShowtimes(t *testing.T) {
+	router, store := setupRouter()
+	created := createHall(t, store.Halls())
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	assert.NoError(t, store.Movies().Create(&movie))
+	showtime := models.Showtime{MovieID: movie.MovieID, Showtime: "2024-05-30 12:00", HallID: created.HallID}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/halls/"+strconv.Itoa(created.HallID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), HallInUseError)
+}
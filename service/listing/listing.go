@@ -0,0 +1,144 @@
+package listing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/repository"
+)
+
+const (
+	// DefaultLimit is the size of a page when the request does not set it
+	DefaultLimit = 50
+	// MaxLimit is the largest page a request may ask for
+	MaxLimit = 200
+)
+
+var (
+	errInvalidLimit  = apierror.Validation("invalid_limit", fmt.Sprintf("Invalid limit, it must be between 1 and %d", MaxLimit))
+	errInvalidCursor = apierror.Validation("invalid_cursor", "Invalid cursor")
+)
+
+// Param is a query parameter filtering a list by comparing a field with its
+// value, parsed with Parse
+type Param struct {
+	Field string
+	Op    repository.FilterOp
+	Parse func(string) (interface{}, error)
+}
+
+// Spec whitelists the query parameters of a list endpoint, other parameters
+// are ignored
+type Spec struct {
+	// Filters maps the name of query parameters to their filter
+	Filters map[string]Param
+	// Sorts are the fields the list can be sorted by, the first one is the
+	// default
+	Sorts []string
+	// Count returns the number of matching records with every page, for
+	// lists that can count them cheaply
+	Count bool
+}
+
+// Response is a page of a list with the cursor of the next page
+type Response[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+// Int parses an integer filter
+func Int(s string) (interface{}, error) {
+	return strconv.Atoi(s)
+}
+
+// String parses a text filter
+func String(s string) (interface{}, error) {
+	return s, nil
+}
+
+// Time parses a time filter written as a date, the day starting at midnight
+// UTC, or as RFC 3339
+func Time(s string) (interface{}, error) {
+	if day, err := time.Parse(time.DateOnly, s); err == nil {
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// TimeEnd parses the end of a time range: a date includes the whole day
+func TimeEnd(s string) (interface{}, error) {
+	if day, err := time.Parse(time.DateOnly, s); err == nil {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Parse reads the list query of the request: its filters, `sort` as a field
+// prefixed with `-` for the descending order, `limit` and `cursor`
+func (s Spec) Parse(c *gin.Context) (repository.ListQuery, error) {
+	query := repository.ListQuery{Sort: s.Sorts[0], Limit: DefaultLimit, Count: s.Count}
+
+	for name, param := range s.Filters {
+		value, ok := c.GetQuery(name)
+		if !ok {
+			continue
+		}
+		parsed, err := param.Parse(value)
+		if err != nil {
+			return query, apierror.Validation("invalid_filter", "Invalid value of filter "+name)
+		}
+		query.Filters = append(query.Filters, repository.Filter{Field: param.Field, Op: param.Op, Value: parsed})
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		query.Desc = strings.HasPrefix(sort, "-")
+		query.Sort = strings.TrimPrefix(sort, "-")
+		if !s.sortable(query.Sort) {
+			return query, apierror.Validation("invalid_sort", fmt.Sprintf("Invalid sort field %s, it must be one of %s", query.Sort, strings.Join(s.Sorts, ", ")))
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return query, errInvalidLimit
+		}
+		query.Limit = n
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := repository.DecodeCursor(cursor)
+		if err != nil || after.Sort != query.Sort || after.Desc != query.Desc {
+			return query, errInvalidCursor
+		}
+		query.After = &after
+	}
+	return query, nil
+}
+
+func (s Spec) sortable(field string) bool {
+	for _, sort := range s.Sorts {
+		if sort == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Respond writes the page as the response
+func Respond[T any](c *gin.Context, page repository.Page[T]) {
+	response := Response[T]{Items: page.Items, Total: page.Total}
+	if response.Items == nil {
+		response.Items = []T{}
+	}
+	if page.Next != nil {
+		response.NextCursor = page.Next.Encode()
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,77 @@
+package listing
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/repository"
+	"testing"
+	"time"
+)
+
+var testSpec = Spec{
+	Filters: map[string]Param{
+		"movie_id": {Field: "movie_id", Op: repository.OpEqual, Parse: Int},
+		"from":     {Field: "showtime", Op: repository.OpFrom, Parse: Time},
+		"to":       {Field: "showtime", Op: repository.OpBefore, Parse: TimeEnd},
+	},
+	Sorts: []string{"showtime_id", "showtime"},
+	Count: true,
+}
+
+func parse(query string) (repository.ListQuery, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/showtimes?"+query, nil)
+	return testSpec.Parse(c)
+}
+
+func TestParseDefaults(t *testing.T) {
+	query, err := parse("")
+
+	assert.NoError(t, err)
+	assert.Equal(t, repository.ListQuery{Sort: "showtime_id", Limit: DefaultLimit, Count: true}, query)
+}
+
+func TestParse(t *testing.T) {
+	cursor := repository.NewCursor("showtime", true, time.Date(2024, 5, 30, 12, 0, 0, 0, time.UTC), 7)
+
+	query, err := parse("movie_id=3&to=2024-05-30&sort=-showtime&limit=10&cursor=" + cursor.Encode())
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []repository.Filter{
+		{Field: "movie_id", Op: repository.OpEqual, Value: 3},
+		{Field: "showtime", Op: repository.OpBefore, Value: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+	}, query.Filters)
+	assert.Equal(t, "showtime", query.Sort)
+	assert.True(t, query.Desc)
+	assert.Equal(t, 10, query.Limit)
+	assert.Equal(t, &cursor, query.After)
+}
+
+func TestParseInvalid(t *testing.T) {
+	otherSort := repository.NewCursor("showtime_id", false, 7, 7)
+	descending := repository.NewCursor("showtime_id", true, 7, 7)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Invalid Filter", "movie_id=three"},
+		{"Invalid Date", "from=30/05/2024"},
+		{"Unknown Sort", "sort=title"},
+		{"Zero Limit", "limit=0"},
+		{"Limit Too Large", "limit=201"},
+		{"Garbage Cursor", "cursor=garbage"},
+		{"Cursor Of Other Sort", "sort=showtime&cursor=" + otherSort.Encode()},
+		{"Cursor Of Other Order", "cursor=" + descending.Encode()},
+		{"Cursor Of Ascending Order", "sort=-showtime_id&cursor=" + otherSort.Encode()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.query)
+			assert.Error(t, err)
+		})
+	}
+}
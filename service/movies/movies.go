@@ -1,60 +1,98 @@
 package movies
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/service/etag"
+	"one-way-ticket/service/listing"
+	"one-way-ticket/validation"
 )
 
 var log = logrus.New()
 
 const (
-	InvalidMovieId = "Invalid movie ID"
+	InvalidMovieId     = "Invalid movie ID"
+	MovieNotFoundError = "Movie not found"
+	MovieInUseError    = "Movie has showtimes and cannot be deleted"
 )
 
-func GetMovies(c *gin.Context) {
-	var movies []models.Movie
-	err := db.Dbx.Select(&movies, "SELECT * FROM movies")
+var (
+	errInvalidMovieID = apierror.Validation("invalid_movie_id", InvalidMovieId)
+	errMovieNotFound  = apierror.NotFound("movie_not_found", MovieNotFoundError)
+	errMovieInUse     = apierror.Conflict("movie_in_use", MovieInUseError)
+)
+
+type Handler struct {
+	movies repository.MovieRepository
+}
+
+func NewHandler(movies repository.MovieRepository) *Handler {
+	return &Handler{movies: movies}
+}
+
+// listSpec whitelists the filters and sort fields of the movie list
+var listSpec = listing.Spec{
+	Filters: map[string]listing.Param{
+		"genre": {Field: "genre", Op: repository.OpEqual, Parse: listing.String},
+		"title": {Field: "title", Op: repository.OpEqual, Parse: listing.String},
+	},
+	Sorts: []string{"movie_id", "title", "duration"},
+	Count: true,
+}
+
+// movieError reports the errors of the movie repository
+func movieError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return errMovieNotFound.Wrap(err)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errMovieInUse.Wrap(err)
+	}
+	return err
+}
+
+func (h *Handler) GetMovies(c *gin.Context) {
+	query, err := listSpec.Parse(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, movies)
+	page, err := h.movies.ListPage(query)
+	if err != nil {
+		apierror.Abort(c, movieError(err))
+		return
+	}
+	listing.Respond(c, page)
 }
 
-func GetMovie(c *gin.Context) {
+func (h *Handler) GetMovie(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidMovieId})
+		apierror.Abort(c, errInvalidMovieID)
 		return
 	}
 
-	var movie models.Movie
-	err = db.Dbx.Get(&movie, "SELECT * FROM movies WHERE movie_id=$1", id)
+	movie, err := h.movies.Get(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, movieError(err))
 		return
 	}
+	etag.Set(c, movie.Version)
 	c.JSON(http.StatusOK, movie)
 }
 
-func CreateMovie(c *gin.Context) {
+func (h *Handler) CreateMovie(c *gin.Context) {
 	var movieInput models.MovieInput
-	if err := c.ShouldBindJSON(&movieInput); err != nil {
+	if err := validation.BindJSON(c, &movieInput); err != nil {
 		log.Error("Error binding JSON: ", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	query := `INSERT INTO movies (title, duration, genre) VALUES (:title, :duration, :genre) RETURNING movie_id`
-	rows, err := db.Dbx.NamedQuery(query, &movieInput)
-	if err != nil {
-		log.Error("Error inserting movie: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -64,57 +102,96 @@ func CreateMovie(c *gin.Context) {
 		Genre:    movieInput.Genre,
 	}
 
-	if rows.Next() {
-		err = rows.Scan(&movie.MovieID)
-		if err != nil {
-			log.Error("Error retrieving new movie ID: ", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	err := h.movies.Create(&movie)
+	if err != nil {
+		log.Error("Error inserting movie: ", err)
+		apierror.Abort(c, movieError(err))
+		return
 	}
 
 	log.Info("Movie created successfully with ID:", movie.MovieID)
+	etag.Set(c, movie.Version)
 	c.JSON(http.StatusCreated, movie)
 }
 
-func UpdateMovie(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidMovieId})
+// UpdateMovie replaces the movie, unless it changed since the client read
+// the version of its If-Match header
+func (h *Handler) UpdateMovie(c *gin.Context) {
+	movie, ok := h.current(c)
+	if !ok {
 		return
 	}
 
 	var movieInput models.MovieInput
-	if err := c.BindJSON(&movieInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := validation.BindJSON(c, &movieInput); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
+	h.save(c, movie, movieInput)
+}
 
-	movie := models.Movie{
-		MovieID:  id,
-		Title:    movieInput.Title,
-		Duration: movieInput.Duration,
-		Genre:    movieInput.Genre,
+// PatchMovie applies a JSON merge patch to the movie, unless it changed since
+// the client read the version of its If-Match header
+func (h *Handler) PatchMovie(c *gin.Context) {
+	movie, ok := h.current(c)
+	if !ok {
+		return
 	}
 
-	_, err = db.Dbx.NamedExec("UPDATE movies SET title=:title, duration=:duration, genre=:genre WHERE movie_id=:movie_id", &movie)
+	movieInput := models.MovieInput{Title: movie.Title, Duration: movie.Duration, Genre: movie.Genre}
+	if err := validation.BindMergePatch(c, &movieInput); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	h.save(c, movie, movieInput)
+}
+
+// current reads the movie of the request and checks that it is the version
+// the client expects
+func (h *Handler) current(c *gin.Context) (models.Movie, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidMovieID)
+		return models.Movie{}, false
+	}
+
+	movie, err := h.movies.Get(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, movieError(err))
+		return movie, false
+	}
+	if err := etag.Check(c, movie.Version); err != nil {
+		apierror.Abort(c, err)
+		return movie, false
+	}
+	return movie, true
+}
+
+// save updates the movie with the input, the update fails when the movie
+// changed since it was read
+func (h *Handler) save(c *gin.Context, movie models.Movie, movieInput models.MovieInput) {
+	movie.Title = movieInput.Title
+	movie.Duration = movieInput.Duration
+	movie.Genre = movieInput.Genre
+
+	if err := h.movies.Update(&movie); err != nil {
+		apierror.Abort(c, movieError(err))
 		return
 	}
+	etag.Set(c, movie.Version)
 	c.JSON(http.StatusOK, movie)
 }
 
-func DeleteMovie(c *gin.Context) {
+func (h *Handler) DeleteMovie(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidMovieId})
+		apierror.Abort(c, errInvalidMovieID)
 		return
 	}
 
-	_, err = db.Dbx.Exec("DELETE FROM movies WHERE movie_id=$1", id)
+	err = h.movies.Delete(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, movieError(err))
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{})
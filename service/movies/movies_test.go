@@ -7,39 +7,42 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/service/listing"
 	"strconv"
 	"testing"
 )
 
-func setupRouter() *gin.Engine {
+func setupRouter() (*gin.Engine, repository.MovieRepository) {
+	movies := memory.NewStore().Movies()
+	handler := NewHandler(movies)
+
 	r := gin.Default()
-	r.GET("/movies", GetMovies)
-	r.GET("/movies/:id", GetMovie)
-	r.POST("/movies", CreateMovie)
-	r.PUT("/movies/:id", UpdateMovie)
-	r.DELETE("/movies/:id", DeleteMovie)
-	return r
+	r.Use(apierror.Middleware())
+	r.GET("/movies", handler.GetMovies)
+	r.GET("/movies/:id", handler.GetMovie)
+	r.POST("/movies", handler.CreateMovie)
+	r.PUT("/movies/:id", handler.UpdateMovie)
+	r.PATCH("/movies/:id", handler.PatchMovie)
+	r.DELETE("/movies/:id", handler.DeleteMovie)
+	return r, movies
 }
 
-func TestMain(m *testing.M) {
-	err := db.Connect()
+func createMovie(t *testing.T, movies repository.MovieRepository) models.Movie {
+	movie := models.Movie{Title: "Inception", Duration: 148, Genre: "Sci-Fi"}
+	err := movies.Create(&movie)
 	if err != nil {
-		return
+		t.Fatalf("Failed to create movie: %v", err)
 	}
-
-	_, err = db.Dbx.Exec("TRUNCATE TABLE bookings, showtimes, movies, users RESTART IDENTITY CASCADE")
-	if err != nil {
-		panic(err)
-	}
-	m.Run()
+	return movie
 }
 
 func TestGetMovies(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO movies (title, duration, genre) VALUES ('test', '123', 'comedy')")
+	router, movies := setupRouter()
+	createMovie(t, movies)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/movies", nil)
@@ -47,34 +50,45 @@ func TestGetMovies(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var users []models.User
-	err := json.Unmarshal(w.Body.Bytes(), &users)
+	var list listing.Response[models.Movie]
+	err := json.Unmarshal(w.Body.Bytes(), &list)
 	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+	if assert.NotNil(t, list.Total) {
+		assert.Equal(t, 1, *list.Total)
+	}
+	assert.Empty(t, list.NextCursor)
 }
 
 func TestGetMovie(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO movies (title, duration, genre) VALUES ('Inception', 148, 'Sci-Fi')")
-	var movieID int
-	err := db.Dbx.Get(&movieID, "SELECT movie_id FROM movies WHERE title='Inception' AND duration=148 AND genre='Sci-Fi'")
-	if err != nil {
-		t.Fatalf("Failed to get booking ID: %v", err)
-	}
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/movies/"+strconv.Itoa(movieID), nil)
+	req, _ := http.NewRequest("GET", "/movies/"+strconv.Itoa(created.MovieID), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var movie models.Movie
-	err = json.Unmarshal(w.Body.Bytes(), &movie)
+	err := json.Unmarshal(w.Body.Bytes(), &movie)
 	assert.NoError(t, err)
+	assert.Equal(t, created, movie)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+}
+
+func TestGetMovieNotFound(t *testing.T) {
+	router, _ := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/movies/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestCreateMovie(t *testing.T) {
-	router := setupRouter()
+	router, movies := setupRouter()
 
 	movieInput := models.MovieInput{
 		Title:    "Interstellar",
@@ -94,17 +108,34 @@ func TestCreateMovie(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &movie)
 	assert.NoError(t, err)
 	assert.Equal(t, "Interstellar", movie.Title)
+
+	stored, err := movies.Get(movie.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, movie, stored)
 }
 
-func TestUpdateMovie(t *testing.T) {
-	router := setupRouter()
+func TestCreateMovieValidation(t *testing.T) {
+	router, movies := setupRouter()
 
-	db.Dbx.MustExec("INSERT INTO movies (title, duration, genre) VALUES ('Inception', 148, 'Sci-Fi')")
-	var movieID int
-	err := db.Dbx.Get(&movieID, "SELECT movie_id FROM movies WHERE title='Inception' AND duration=148 AND genre='Sci-Fi'")
-	if err != nil {
-		t.Fatalf("Failed to get booking ID: %v", err)
-	}
+	jsonValue, _ := json.Marshal(models.MovieInput{Title: "Interstellar", Duration: -169, Genre: "Sci-Fi"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/movies", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem apierror.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, []apierror.Violation{{Field: "duration", Rule: "min", Message: "duration must be at least 1"}}, problem.Violations)
+
+	list, err := movies.ListPage(repository.ListQuery{Sort: "movie_id", Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestUpdateMovie(t *testing.T) {
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
 
 	movieInput := models.MovieInput{
 		Title:    "Inception Updated",
@@ -114,32 +145,164 @@ func TestUpdateMovie(t *testing.T) {
 	jsonValue, _ := json.Marshal(movieInput)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("PUT", "/movies/"+strconv.Itoa(movieID), bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("PUT", "/movies/"+strconv.Itoa(created.MovieID), bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var movie models.Movie
-	err = json.Unmarshal(w.Body.Bytes(), &movie)
+	err := json.Unmarshal(w.Body.Bytes(), &movie)
 	assert.NoError(t, err)
 	assert.Equal(t, "Inception Updated", movie.Title)
+
+	stored, err := movies.Get(created.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, 150, stored.Duration)
 }
 
-func TestDeleteMovie(t *testing.T) {
-	router := setupRouter()
+func TestPatchMovie(t *testing.T) {
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
 
-	db.Dbx.MustExec("INSERT INTO movies (title, duration, genre) VALUES ('Inception', 148, 'Sci-Fi')")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/movies/"+strconv.Itoa(created.MovieID), bytes.NewBufferString(`{"duration":150}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
 
-	var movieID int
-	err := db.Dbx.Get(&movieID, "SELECT movie_id FROM movies WHERE title='Inception' AND duration=148 AND genre='Sci-Fi'")
-	if err != nil {
-		t.Fatalf("Failed to get booking ID: %v", err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	stored, err := movies.Get(created.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Inception", stored.Title)
+	assert.Equal(t, 150, stored.Duration)
+	assert.Equal(t, 2, stored.Version)
+}
+
+func TestPatchMovieInvalid(t *testing.T) {
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"required field removed", "application/merge-patch+json", `{"title":null}`, http.StatusBadRequest, "invalid_fields"},
+		{"broken rule", "application/merge-patch+json", `{"duration":0}`, http.StatusBadRequest, "invalid_fields"},
+		{"not an object", "application/merge-patch+json", `[]`, http.StatusBadRequest, "invalid_patch"},
+		{"other media type", "text/plain", `{"duration":150}`, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PATCH", "/movies/"+strconv.Itoa(created.MovieID), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("If-Match", `"1"`)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"`+tt.code+`"`)
+		})
+	}
+
+	stored, err := movies.Get(created.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, created, stored)
+}
+
+func TestUpdateMovieStale(t *testing.T) {
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
+
+	// somebody else updated the movie since the client read it
+	changed := created
+	changed.Genre = "Thriller"
+	assert.NoError(t, movies.Update(&changed))
+
+	for _, method := range []string{"PUT", "PATCH"} {
+		t.Run(method, func(t *testing.T) {
+			jsonValue, _ := json.Marshal(models.MovieInput{Title: "Inception", Duration: 150, Genre: "Sci-Fi"})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, "/movies/"+strconv.Itoa(created.MovieID), bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", `"1"`)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"precondition_failed"`)
+		})
+	}
+
+	stored, err := movies.Get(created.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, changed, stored)
+}
+
+func TestUpdateMovieUnconditional(t *testing.T) {
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
+
+	for _, method := range []string{"PUT", "PATCH"} {
+		t.Run(method, func(t *testing.T) {
+			jsonValue, _ := json.Marshal(models.MovieInput{Title: "Inception", Duration: 150, Genre: "Sci-Fi"})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, "/movies/"+strconv.Itoa(created.MovieID), bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"precondition_required"`)
+		})
 	}
 
+	// * overwrites whatever version is stored
+	jsonValue, _ := json.Marshal(models.MovieInput{Title: "Inception", Duration: 150, Genre: "Sci-Fi"})
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("DELETE", "/movies/"+strconv.Itoa(movieID), nil)
+	req, _ := http.NewRequest("PUT", "/movies/"+strconv.Itoa(created.MovieID), bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err := movies.Get(created.MovieID)
+	assert.NoError(t, err)
+	assert.Equal(t, 150, stored.Duration)
+}
+
+func TestDeleteMovie(t *testing.T) {
+	router, movies := setupRouter()
+	created := createMovie(t, movies)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/movies/"+strconv.Itoa(created.MovieID), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := movies.Get(created.MovieID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestMissingMovie(t *testing.T) {
+	router, _ := setupRouter()
+	jsonValue, _ := json.Marshal(models.MovieInput{Title: "Inception", Duration: 148, Genre: "Sci-Fi"})
+
+	for _, method := range []string{"PUT", "DELETE"} {
+		t.Run(method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, "/movies/42", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), `"code":"movie_not_found"`)
+		})
+	}
 }
@@ -0,0 +1,165 @@
+package showtimes
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+// planChanges decides what happens to the bookings of the showtime existing
+// when it becomes updated, starting at start. Bookings are affected by any
+// change of the time, hall or movie. In a new hall, seats that exist and can
+// be booked are kept, the others are rebooked to a free seat of the same
+// category, or else of any category, and cancelled when the hall is full.
+func planChanges(tx repository.Store, existing, updated models.Showtime, start time.Time) ([]models.BookingChange, error) {
+	changes := []models.BookingChange{}
+	from, err := existing.Start()
+	if err != nil {
+		return nil, err
+	}
+	if existing.MovieID == updated.MovieID && existing.HallID == updated.HallID && from.Equal(start) {
+		return changes, nil
+	}
+
+	bookings, err := tx.Bookings().ListForShowtime(existing.ShowtimeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, booking := range bookings {
+		changes = append(changes, models.BookingChange{
+			BookingID: booking.BookingID,
+			UserID:    booking.UserID,
+			Seat:      booking.Seat,
+			Action:    models.BookingKept,
+		})
+	}
+	if existing.HallID == updated.HallID || len(bookings) == 0 {
+		return changes, nil
+	}
+
+	oldHall, err := tx.Halls().Get(existing.HallID)
+	if err != nil {
+		return nil, err
+	}
+	newHall, err := tx.Halls().Get(updated.HallID)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []string
+	for _, change := range changes {
+		if newHall.Layout.IsBookable(change.Seat) {
+			kept = append(kept, change.Seat)
+		}
+	}
+	categories := map[string]string{}
+	for _, seat := range models.NewSeatMap(existing.ShowtimeID, oldHall, nil, nil).Seats {
+		categories[seat.Label] = seat.Category
+	}
+	var free []models.SeatMapSeat
+	for _, seat := range models.NewSeatMap(updated.ShowtimeID, newHall, kept, nil).Seats {
+		if seat.Status == models.SeatFree {
+			free = append(free, seat)
+		}
+	}
+
+	for i, change := range changes {
+		if newHall.Layout.IsBookable(change.Seat) {
+			continue
+		}
+		seat := -1
+		for j := range free {
+			if free[j].Category == categories[change.Seat] {
+				seat = j
+				break
+			}
+		}
+		if seat < 0 && len(free) > 0 {
+			seat = 0
+		}
+		if seat < 0 {
+			changes[i].Action = models.BookingCancelled
+			continue
+		}
+		changes[i].Action = models.BookingRebooked
+		changes[i].NewSeat = free[seat].Label
+		free = append(free[:seat], free[seat+1:]...)
+	}
+	return changes, nil
+}
+
+// needsForce reports whether the change of the showtime costs a customer
+// their seat or the movie they paid for
+func needsForce(existing, updated models.Showtime, changes []models.BookingChange) bool {
+	if len(changes) > 0 && existing.MovieID != updated.MovieID {
+		return true
+	}
+	for _, change := range changes {
+		if change.Action != models.BookingKept {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelledByChangeReason is the reason of the bookings cancelled because
+// their showtime changed, they are refunded in full
+const cancelledByChangeReason = "The showtime changed and no seat was left"
+
+// applyChanges rebooks and cancels the bookings as planned. The holds of a
+// showtime moved to another hall are released, their seats may not exist in
+// the new hall.
+func applyChanges(tx repository.Store, existing, updated models.Showtime, changes []models.BookingChange) error {
+	if existing.HallID != updated.HallID {
+		if err := tx.Holds().DeleteForShowtime(existing.ShowtimeID); err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		switch change.Action {
+		case models.BookingRebooked:
+			booking, err := tx.Bookings().Get(change.BookingID)
+			if err != nil {
+				return err
+			}
+			booking.Seat = change.NewSeat
+			if err := tx.Bookings().Update(&booking); err != nil {
+				return err
+			}
+		case models.BookingCancelled:
+			booking, err := tx.Bookings().Get(change.BookingID)
+			if err != nil {
+				return err
+			}
+			err = tx.Bookings().Cancel(booking.BookingID, cancelledByChangeReason, booking.Paid(), time.Now())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// notifier tells customers that a change of the showtime affected their
+// bookings
+type notifier interface {
+	BookingsChanged(showtime models.Showtime, changes []models.BookingChange)
+}
+
+// logNotifier logs the changes, customers cannot be reached otherwise yet
+type logNotifier struct{}
+
+func (logNotifier) BookingsChanged(showtime models.Showtime, changes []models.BookingChange) {
+	for _, change := range changes {
+		log.WithFields(logrus.Fields{
+			"showtime_id": showtime.ShowtimeID,
+			"booking_id":  change.BookingID,
+			"user_id":     change.UserID,
+			"seat":        change.Seat,
+			"new_seat":    change.NewSeat,
+			"action":      change.Action,
+		}).Info("Booking affected by showtime change")
+	}
+}
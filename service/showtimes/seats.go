@@ -0,0 +1,61 @@
+package showtimes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+)
+
+// GetSeatMap returns the state of every seat of the hall of the showtime. The
+// response carries an ETag derived from its body, a request whose
+// If-None-Match matches it gets 304 Not Modified without a body.
+func (h *Handler) GetSeatMap(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidShowtimeID)
+		return
+	}
+
+	occupancy, err := h.repos.Showtimes().Occupancy(id)
+	if err != nil {
+		apierror.Abort(c, showtimeError(err))
+		return
+	}
+
+	seatMap := models.NewSeatMap(occupancy.ShowtimeID, occupancy.Hall, occupancy.Booked, occupancy.Held)
+	body, err := json.Marshal(seatMap)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	// seats are taken at any time, caches must revalidate before reusing
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches reports whether the If-None-Match header lists etag, weak
+// validators compare equal to their strong counterpart
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
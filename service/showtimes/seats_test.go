@@ -0,0 +1,112 @@
+package showtimes
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+	"one-way-ticket/repository/memory"
+	"testing"
+	"time"
+)
+
+// setupSeatMap creates showtime 1 in a hall whose row A holds premium seats,
+// A2 is an aisle and B1 is disabled
+func setupSeatMap(t *testing.T) (*gin.Engine, *memory.Store) {
+	store := memory.NewStore()
+	err := store.Movies().Create(&models.Movie{Title: "Sample Movie", Duration: 120, Genre: "Action"})
+	if err != nil {
+		t.Fatalf("Failed to create movie: %v", err)
+	}
+	err = store.Users().Create(&models.User{Username: "testuser", Password: "password", Email: "test@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	layout := models.HallLayout{Rows: []models.HallRow{
+		{Label: "A", Seats: 3, Aisles: []int{2}, Category: "premium"},
+		{Label: "B", Seats: 2, Disabled: []int{1}},
+	}}
+	err = store.Halls().Create(&models.Hall{Name: "Hall 1", Capacity: layout.Capacity(), Layout: layout})
+	if err != nil {
+		t.Fatalf("Failed to create hall: %v", err)
+	}
+	err = store.Showtimes().Create(&models.Showtime{MovieID: 1, Showtime: "2024-05-30 12:00:00", HallID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create showtime: %v", err)
+	}
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.GET("/showtimes/:id/seats", NewHandler(store).GetSeatMap)
+	return r, store
+}
+
+func TestGetSeatMap(t *testing.T) {
+	router, store := setupSeatMap(t)
+	err := store.Bookings().Create(&models.Booking{UserID: 1, ShowtimeID: 1, Seat: "A3"})
+	assert.NoError(t, err)
+	err = store.Holds().Create(&models.Hold{UserID: 1, ShowtimeID: 1, Seats: []string{"B2"}, ExpiresAt: time.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/showtimes/1/seats", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	var seatMap models.SeatMap
+	err = json.Unmarshal(w.Body.Bytes(), &seatMap)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, seatMap.ShowtimeID)
+	assert.Equal(t, 1, seatMap.HallID)
+	assert.Equal(t, 1, seatMap.Available)
+	assert.Equal(t, []models.SeatMapSeat{
+		{Label: "A1", Row: "A", Position: 1, Category: "premium", Status: models.SeatFree},
+		{Label: "A3", Row: "A", Position: 3, Category: "premium", Status: models.SeatBooked},
+		{Label: "B1", Row: "B", Position: 1, Category: models.DefaultSeatCategory, Status: models.SeatBlocked},
+		{Label: "B2", Row: "B", Position: 2, Category: models.DefaultSeatCategory, Status: models.SeatHeld},
+	}, seatMap.Seats)
+}
+
+func TestGetSeatMapNotModified(t *testing.T) {
+	router, store := setupSeatMap(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/showtimes/1/seats", nil)
+	router.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/showtimes/1/seats", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// a new booking changes the map and its ETag
+	err := store.Bookings().Create(&models.Booking{UserID: 1, ShowtimeID: 1, Seat: "A1"})
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/showtimes/1/seats", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestGetSeatMapNotFound(t *testing.T) {
+	router, _ := setupSeatMap(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/showtimes/42/seats", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
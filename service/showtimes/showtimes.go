@@ -1,175 +1,366 @@
 package showtimes
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/service/etag"
+	"one-way-ticket/service/listing"
+	"one-way-ticket/validation"
 )
 
 var log = logrus.New()
 
 const (
 	InvalidShowtimeID        = "Invalid showtime ID"
+	ShowtimeNotFoundError    = "Showtime not found"
 	OverlappingShowtimeError = "Showtime overlaps with an existing showtime in the same hall"
+	InvalidReferenceError    = "Movie or hall does not exist"
+	InvalidForceError        = "Invalid force flag"
+	InvalidShowtimeError     = "Invalid showtime format"
+	AffectedBookingsError    = "Showtime has bookings that the change would affect, retry with force=true to rebook them"
+	ShowtimeInUseError       = "Showtime has bookings or orders and cannot be deleted"
 )
 
-func parseShowtime(showtimeStr string) (time.Time, error) {
-	return time.Parse("2006-01-02 15:04", showtimeStr)
+var (
+	errInvalidShowtimeID = apierror.Validation("invalid_showtime_id", InvalidShowtimeID)
+	errInvalidForce      = apierror.Validation("invalid_force", InvalidForceError)
+	errInvalidShowtime   = apierror.Validation("invalid_showtime", InvalidShowtimeError)
+	errShowtimeNotFound  = apierror.NotFound("showtime_not_found", ShowtimeNotFoundError)
+	errInvalidReference  = apierror.Validation("invalid_reference", InvalidReferenceError)
+	errOverlap           = apierror.Validation("showtime_overlap", OverlappingShowtimeError)
+	errBookingsAffected  = apierror.Conflict("bookings_affected", AffectedBookingsError)
+	errShowtimeInUse     = apierror.Conflict("showtime_in_use", ShowtimeInUseError)
+)
+
+// showtimeError returns the error reported for err, an error of the
+// repositories about a showtime being created or changed, whose movie or hall
+// may not exist
+func showtimeError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return errShowtimeNotFound.Wrap(err)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errInvalidReference.Wrap(err)
+	}
+	return err
 }
 
-func showtimeOverlap(movieID int, showtime time.Time, hall string) (bool, error) {
-	var existingShowtimes []models.Showtime
-	query := `SELECT * FROM showtimes WHERE hall = $1 AND showtime BETWEEN $2 AND $3`
-	start := showtime.Add(-time.Hour * 3)
-	end := showtime.Add(time.Hour * 3)
+type Handler struct {
+	repos    repository.Store
+	notifier notifier
+}
 
-	err := db.Dbx.Select(&existingShowtimes, query, hall, start, end)
-	if err != nil {
-		return false, err
-	}
+// NewHandler creates a new Handler, showtimes are scheduled against the
+// movies and halls of the store
+func NewHandler(repos repository.Store) *Handler {
+	return &Handler{repos: repos, notifier: logNotifier{}}
+}
 
-	if len(existingShowtimes) == 0 {
-		return false, nil
-	} else {
-		return true, nil
+// lockHall locks the hall a showtime is scheduled in until the end of the
+// transaction, so that two showtimes cannot both pass the overlap check and
+// be scheduled at the same time in the hall
+func lockHall(tx repository.Store, hallID int) error {
+	err := tx.Halls().Lock(hallID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidReference.Wrap(err)
 	}
+	return err
+}
+
+func parseShowtime(showtimeStr string) (time.Time, error) {
+	return time.Parse(models.ShowtimeLayout, showtimeStr)
 }
 
-func GetShowtimes(c *gin.Context) {
-	var showtimes []models.Showtime
-	err := db.Dbx.Select(&showtimes, "SELECT * FROM showtimes")
+// validateShowtime checks the rules of a showtime that need the store: it
+// starts in the future, unless the update of existing keeps its start, and
+// its movie and hall exist. It returns the start of the showtime.
+func validateShowtime(repos repository.Store, input models.ShowtimeInput, existing *models.Showtime) (time.Time, error) {
+	start, err := parseShowtime(input.Showtime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return start, errInvalidShowtime
+	}
+
+	var violations validation.Violations
+	moved := true
+	if existing != nil {
+		existingStart, err := existing.Start()
+		moved = err != nil || !existingStart.Equal(start)
+	}
+	if moved && !start.After(time.Now()) {
+		violations.Add("showtime", "future", "showtime must be in the future")
+	}
+	if _, err := repos.Movies().Get(input.MovieID); errors.Is(err, repository.ErrNotFound) {
+		violations.Add("movie_id", "exists", fmt.Sprintf("movie %d does not exist", input.MovieID))
+	} else if err != nil {
+		return start, err
+	}
+	if _, err := repos.Halls().Get(input.HallID); errors.Is(err, repository.ErrNotFound) {
+		violations.Add("hall_id", "exists", fmt.Sprintf("hall %d does not exist", input.HallID))
+	} else if err != nil {
+		return start, err
 	}
-	c.JSON(http.StatusOK, showtimes)
+	return start, violations.Err()
 }
 
-func GetShowtime(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// showtimeOverlap reports whether a showtime of the movie starting at start
+// would overlap another showtime of the hall than excludeID. Showtimes occupy
+// their hall from their start, through the trailers and the movie, until the
+// hall has been cleaned. It returns ErrNotFound when the movie or the hall
+// does not exist.
+func showtimeOverlap(repos repository.Store, movieID int, start time.Time, hallID int, excludeID int) (bool, error) {
+	movie, err := repos.Movies().Get(movieID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidShowtimeID})
-		return
+		return false, err
+	}
+	hall, err := repos.Halls().Get(hallID)
+	if err != nil {
+		return false, err
 	}
 
-	var showtime models.Showtime
-	err = db.Dbx.Get(&showtime, "SELECT * FROM showtimes WHERE showtime_id=$1", id)
+	existingShowtimes, err := repos.Showtimes().ListOverlapping(hallID, start, hall.ShowtimeEnd(start, movie.Duration), excludeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return false, err
 	}
-	c.JSON(http.StatusOK, showtime)
+	return len(existingShowtimes) > 0, nil
 }
 
-func CreateShowtime(c *gin.Context) {
-	var showtimeInput models.ShowtimeInput
-	if err := c.ShouldBindJSON(&showtimeInput); err != nil {
-		log.Error("Error binding JSON: ", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// listSpec whitelists the filters and sort fields of the showtime list,
+// from and to select the showtimes starting in a date range
+var listSpec = listing.Spec{
+	Filters: map[string]listing.Param{
+		"movie_id": {Field: "movie_id", Op: repository.OpEqual, Parse: listing.Int},
+		"hall_id":  {Field: "hall_id", Op: repository.OpEqual, Parse: listing.Int},
+		"from":     {Field: "showtime", Op: repository.OpFrom, Parse: listing.Time},
+		"to":       {Field: "showtime", Op: repository.OpBefore, Parse: listing.TimeEnd},
+	},
+	Sorts: []string{"showtime_id", "showtime", "movie_id", "hall_id"},
+	Count: true,
+}
+
+func (h *Handler) GetShowtimes(c *gin.Context) {
+	query, err := listSpec.Parse(c)
+	if err != nil {
+		apierror.Abort(c, err)
 		return
 	}
-
-	showtimeTime, err := parseShowtime(showtimeInput.Showtime)
+	page, err := h.repos.Showtimes().ListPage(query)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid showtime format"})
+		apierror.Abort(c, err)
 		return
 	}
+	listing.Respond(c, page)
+}
 
-	overlap, err := showtimeOverlap(showtimeInput.MovieID, showtimeTime, showtimeInput.Hall)
+func (h *Handler) GetShowtime(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, errInvalidShowtimeID)
 		return
 	}
-	if overlap {
-		c.JSON(http.StatusBadRequest, gin.H{"error": OverlappingShowtimeError})
+
+	showtime, err := h.repos.Showtimes().Get(id)
+	if err != nil {
+		apierror.Abort(c, showtimeError(err))
 		return
 	}
+	etag.Set(c, showtime.Version)
+	c.JSON(http.StatusOK, showtime)
+}
 
-	query := `INSERT INTO showtimes (movie_id, showtime, hall) VALUES (:movie_id, :showtime, :hall) RETURNING showtime_id`
-	rows, err := db.Dbx.NamedQuery(query, &showtimeInput)
-	if err != nil {
-		log.Error("Error inserting showtime: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *Handler) CreateShowtime(c *gin.Context) {
+	var showtimeInput models.ShowtimeInput
+	if err := validation.BindJSON(c, &showtimeInput); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
 
 	var showtime models.Showtime
-	if rows.Next() {
-		err = rows.Scan(&showtime.ShowtimeID)
+	err := h.repos.WithTx(func(tx repository.Store) error {
+		showtimeTime, err := validateShowtime(tx, showtimeInput, nil)
 		if err != nil {
-			log.Error("Error retrieving new showtime ID: ", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return err
+		}
+		if err := lockHall(tx, showtimeInput.HallID); err != nil {
+			return err
 		}
-	}
-
-	showtime.MovieID = showtimeInput.MovieID
-	showtime.Showtime = showtimeInput.Showtime
-	showtime.Hall = showtimeInput.Hall
 
-	log.Info("Showtime created successfully with ID:", showtime.ShowtimeID)
-	c.JSON(http.StatusCreated, showtime)
-}
+		overlap, err := showtimeOverlap(tx, showtimeInput.MovieID, showtimeTime, showtimeInput.HallID, 0)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errInvalidReference.Wrap(err)
+		}
+		if err != nil {
+			return err
+		}
+		if overlap {
+			return errOverlap
+		}
 
-func UpdateShowtime(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+		showtime = models.Showtime{
+			MovieID:  showtimeInput.MovieID,
+			Showtime: showtimeInput.Showtime,
+			HallID:   showtimeInput.HallID,
+		}
+		return tx.Showtimes().Create(&showtime)
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidShowtimeID})
+		apierror.Abort(c, showtimeError(err))
 		return
 	}
 
+	log.Info("Showtime created successfully with ID:", showtime.ShowtimeID)
+	etag.Set(c, showtime.Version)
+	c.JSON(http.StatusCreated, showtime)
+}
+
+// UpdateShowtime moves a showtime to another time, hall or movie. The bookings
+// of the showtime are reported in the response. Changes that cost customers
+// their seat or their movie are refused with 409 Conflict, listing the
+// bookings they would affect, unless the request is sent with ?force=true:
+// the bookings are then rebooked or cancelled and their customers notified.
+func (h *Handler) UpdateShowtime(c *gin.Context) {
 	var showtimeInput models.ShowtimeInput
-	if err := c.BindJSON(&showtimeInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := validation.BindJSON(c, &showtimeInput); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
+	h.update(c, func(models.Showtime) (models.ShowtimeInput, error) {
+		return showtimeInput, nil
+	})
+}
 
-	showtimeTime, err := parseShowtime(showtimeInput.Showtime)
+// PatchShowtime applies a JSON merge patch to the showtime, which is then
+// moved like UpdateShowtime moves it
+func (h *Handler) PatchShowtime(c *gin.Context) {
+	patch, err := validation.ReadMergePatch(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid showtime format"})
+		apierror.Abort(c, err)
 		return
 	}
+	h.update(c, func(existing models.Showtime) (models.ShowtimeInput, error) {
+		showtimeInput := models.ShowtimeInput{MovieID: existing.MovieID, Showtime: existing.Showtime, HallID: existing.HallID}
+		// the database returns the start in another format
+		if start, err := existing.Start(); err == nil {
+			showtimeInput.Showtime = start.Format(models.ShowtimeLayout)
+		}
+		return showtimeInput, validation.MergePatch(patch, &showtimeInput)
+	})
+}
 
-	overlap, err := showtimeOverlap(showtimeInput.MovieID, showtimeTime, showtimeInput.Hall)
+// update moves the showtime of the request to the input returned by
+// showtimeInput for the stored showtime, unless the showtime changed since the
+// client read the version of its If-Match header
+func (h *Handler) update(c *gin.Context, showtimeInput func(existing models.Showtime) (models.ShowtimeInput, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, errInvalidShowtimeID)
 		return
 	}
-	if overlap {
-		c.JSON(http.StatusBadRequest, gin.H{"error": OverlappingShowtimeError})
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		apierror.Abort(c, errInvalidForce)
 		return
 	}
 
-	showtime := models.Showtime{
-		ShowtimeID: id,
-		MovieID:    showtimeInput.MovieID,
-		Showtime:   showtimeInput.Showtime,
-		Hall:       showtimeInput.Hall,
-	}
+	var showtime models.Showtime
+	var changes []models.BookingChange
+	err = h.repos.WithTx(func(tx repository.Store) error {
+		if err := tx.Showtimes().Lock(id); err != nil {
+			return err
+		}
+		existing, err := tx.Showtimes().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := etag.Check(c, existing.Version); err != nil {
+			return err
+		}
+		input, err := showtimeInput(existing)
+		if err != nil {
+			return err
+		}
+		showtimeTime, err := validateShowtime(tx, input, &existing)
+		if err != nil {
+			return err
+		}
+		if err := lockHall(tx, input.HallID); err != nil {
+			return err
+		}
+		showtime = models.Showtime{
+			ShowtimeID: id,
+			MovieID:    input.MovieID,
+			Showtime:   input.Showtime,
+			HallID:     input.HallID,
+			Version:    existing.Version,
+		}
+
+		overlap, err := showtimeOverlap(tx, showtime.MovieID, showtimeTime, showtime.HallID, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errInvalidReference.Wrap(err)
+		}
+		if err != nil {
+			return err
+		}
+		if overlap {
+			return errOverlap
+		}
+
+		changes, err = planChanges(tx, existing, showtime, showtimeTime)
+		if err != nil {
+			return err
+		}
+		if !force && needsForce(existing, showtime, changes) {
+			return errBookingsAffected
+		}
 
-	_, err = db.Dbx.NamedExec("UPDATE showtimes SET movie_id=:movie_id, showtime=:showtime, hall=:hall WHERE showtime_id=:showtime_id", &showtime)
+		if err := tx.Showtimes().Update(&showtime); err != nil {
+			return err
+		}
+		return applyChanges(tx, existing, showtime, changes)
+	})
+	if errors.Is(err, errBookingsAffected) {
+		// the problem lists the bookings so that staff can decide to force
+		apierror.Write(c, http.StatusConflict, struct {
+			apierror.Problem
+			AffectedBookings []models.BookingChange `json:"affected_bookings"`
+		}{errBookingsAffected.Problem(c), changes})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, showtimeError(err))
 		return
 	}
-	c.JSON(http.StatusOK, showtime)
+
+	if len(changes) > 0 {
+		h.notifier.BookingsChanged(showtime, changes)
+	}
+	etag.Set(c, showtime.Version)
+	c.JSON(http.StatusOK, models.ShowtimeUpdate{Showtime: showtime, AffectedBookings: changes})
 }
 
-func DeleteShowtime(c *gin.Context) {
+func (h *Handler) DeleteShowtime(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidShowtimeID})
+		apierror.Abort(c, errInvalidShowtimeID)
 		return
 	}
 
-	_, err = db.Dbx.Exec("DELETE FROM showtimes WHERE showtime_id=$1", id)
+	// a showtime is referenced by its bookings and orders, not the reverse
+	err = h.repos.Showtimes().Delete(id)
+	if errors.Is(err, repository.ErrInvalidReference) {
+		apierror.Abort(c, errShowtimeInUse.Wrap(err))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, showtimeError(err))
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{})
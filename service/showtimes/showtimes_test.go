@@ -3,52 +3,62 @@ package showtimes
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
 	"one-way-ticket/models"
-	"os"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/service/listing"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
-func setupRouter() *gin.Engine {
-	r := gin.Default()
-	r.GET("/showtimes", GetShowtimes)
-	r.GET("/showtimes/:id", GetShowtime)
-	r.POST("/showtimes", CreateShowtime)
-	r.PUT("/showtimes/:id", UpdateShowtime)
-	r.DELETE("/showtimes/:id", DeleteShowtime)
-	return r
-}
+// day is a day a week ahead, showtimes are scheduled in the future
+var day = time.Now().AddDate(0, 0, 7).Format(time.DateOnly)
 
-func TestMain(m *testing.M) {
-	err := db.Connect()
+func setupRouter(t *testing.T) (*gin.Engine, repository.ShowtimeRepository) {
+	store := memory.NewStore()
+	err := store.Movies().Create(&models.Movie{Title: "Sample Movie", Duration: 120, Genre: "Action"})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create movie: %v", err)
 	}
-	_, err = db.Dbx.Exec("TRUNCATE TABLE bookings, showtimes, movies, users RESTART IDENTITY CASCADE")
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10}}}
+	err = store.Halls().Create(&models.Hall{Name: "Hall 1", Capacity: layout.Capacity(), Layout: layout})
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create hall: %v", err)
 	}
-	_, err = db.Dbx.Exec("INSERT INTO movies (title, duration, genre) VALUES ('Sample Movie', 120, 'Action')")
-	if err != nil {
-		panic(err)
-	}
-	code := m.Run()
-	err = db.Dbx.Close()
+	handler := NewHandler(store)
+
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.GET("/showtimes", handler.GetShowtimes)
+	r.GET("/showtimes/:id", handler.GetShowtime)
+	r.GET("/showtimes/:id/seats", handler.GetSeatMap)
+	r.POST("/showtimes", handler.CreateShowtime)
+	r.PUT("/showtimes/:id", handler.UpdateShowtime)
+	r.PATCH("/showtimes/:id", handler.PatchShowtime)
+	r.DELETE("/showtimes/:id", handler.DeleteShowtime)
+	return r, store.Showtimes()
+}
+
+func createShowtime(t *testing.T, showtimes repository.ShowtimeRepository) models.Showtime {
+	showtime := models.Showtime{MovieID: 1, Showtime: day + " 12:00:00", HallID: 1}
+	err := showtimes.Create(&showtime)
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create showtime: %v", err)
 	}
-	os.Exit(code)
+	return showtime
 }
 
 func TestGetShowtimes(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO showtimes (movie_id, showtime, hall) VALUES (1, '2024-05-30 12:00:00', 'Hall 1')")
+	router, showtimes := setupRouter(t)
+	createShowtime(t, showtimes)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/showtimes", nil)
@@ -56,42 +66,93 @@ func TestGetShowtimes(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var showtimes []models.Showtime
-	err := json.Unmarshal(w.Body.Bytes(), &showtimes)
+	var list listing.Response[models.Showtime]
+	err := json.Unmarshal(w.Body.Bytes(), &list)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, showtimes)
+	assert.NotEmpty(t, list.Items)
 }
 
-func TestGetShowtime(t *testing.T) {
-	router := setupRouter()
+func TestGetShowtimesFiltered(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	var ids []int
+	for _, start := range []string{"2024-05-30 20:00:00", "2024-05-29 12:00:00", "2024-05-30 12:00:00", "2024-05-31 12:00:00"} {
+		showtime := models.Showtime{MovieID: 1, Showtime: start, HallID: 1}
+		assert.NoError(t, showtimes.Create(&showtime))
+		ids = append(ids, showtime.ShowtimeID)
+	}
 
-	db.Dbx.MustExec("INSERT INTO showtimes (movie_id, showtime, hall) VALUES (1, '2024-05-30 12:00:00', 'Hall 1')")
-	var showtimeID int
-	err := db.Dbx.Get(&showtimeID, "SELECT showtime_id FROM showtimes WHERE movie_id=1 AND showtime='2024-05-30 12:00:00' AND hall='Hall 1'")
-	if err != nil {
-		t.Fatalf("Failed to get showtime ID: %v", err)
+	tests := []struct {
+		name  string
+		query string
+		ids   []int
+	}{
+		{"Day", "from=2024-05-30&to=2024-05-30&sort=showtime", []int{ids[2], ids[0]}},
+		{"From Time", "from=2024-05-30T13:00:00Z", []int{ids[0], ids[3]}},
+		{"Movie And Hall", "movie_id=1&hall_id=1&sort=-showtime", []int{ids[3], ids[0], ids[2], ids[1]}},
+		{"Other Hall", "hall_id=2", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/showtimes?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var list listing.Response[models.Showtime]
+			err := json.Unmarshal(w.Body.Bytes(), &list)
+			assert.NoError(t, err)
+			ids := []int{}
+			for _, showtime := range list.Items {
+				ids = append(ids, showtime.ShowtimeID)
+			}
+			assert.Equal(t, tt.ids, ids)
+			if assert.NotNil(t, list.Total) {
+				assert.Equal(t, len(tt.ids), *list.Total)
+			}
+		})
 	}
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/showtimes/"+strconv.Itoa(showtimeID), nil)
+	req, _ := http.NewRequest("GET", "/showtimes?from=tomorrow", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetShowtime(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	created := createShowtime(t, showtimes)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/showtimes/"+strconv.Itoa(created.ShowtimeID), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var showtime models.Showtime
-	err = json.Unmarshal(w.Body.Bytes(), &showtime)
+	err := json.Unmarshal(w.Body.Bytes(), &showtime)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, showtime.MovieID)
-	assert.Equal(t, "Hall 1", showtime.Hall)
+	assert.Equal(t, 1, showtime.HallID)
+}
+
+func TestGetShowtimeNotFound(t *testing.T) {
+	router, _ := setupRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/showtimes/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestCreateShowtime(t *testing.T) {
-	router := setupRouter()
+	router, _ := setupRouter(t)
 
 	showtimeInput := models.ShowtimeInput{
 		MovieID:  1,
-		Showtime: "2024-05-30 16:00",
-		Hall:     "Hall 1",
+		Showtime: day + " 16:00",
+		HallID:   1,
 	}
 	jsonValue, _ := json.Marshal(showtimeInput)
 	w := httptest.NewRecorder()
@@ -105,19 +166,18 @@ func TestCreateShowtime(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &showtime)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, showtime.MovieID)
-	assert.Equal(t, "2024-05-30 16:00", showtime.Showtime)
-	assert.Equal(t, "Hall 1", showtime.Hall)
+	assert.Equal(t, day+" 16:00", showtime.Showtime)
+	assert.Equal(t, 1, showtime.HallID)
 }
 
 func TestCreateShowtimeOverlap(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO showtimes (movie_id, showtime, hall) VALUES (1, '2024-05-30 12:00:00', 'Hall 1')")
+	router, showtimes := setupRouter(t)
+	createShowtime(t, showtimes)
 
 	showtimeInput := models.ShowtimeInput{
 		MovieID:  1,
-		Showtime: "2024-05-30 13:00",
-		Hall:     "Hall 1",
+		Showtime: day + " 13:00",
+		HallID:   1,
 	}
 	jsonValue, _ := json.Marshal(showtimeInput)
 
@@ -130,50 +190,449 @@ func TestCreateShowtimeOverlap(t *testing.T) {
 	assert.Contains(t, w.Body.String(), OverlappingShowtimeError)
 }
 
-func TestUpdateShowtime(t *testing.T) {
-	router := setupRouter()
+// TestConcurrentShowtimes schedules overlapping showtimes in the same hall
+// from many goroutines at once: exactly one of them must be created
+func TestConcurrentShowtimes(t *testing.T) {
+	router, showtimes := setupRouter(t)
 
-	db.Dbx.MustExec("INSERT INTO showtimes (movie_id, showtime, hall) VALUES (1, '2024-05-30 12:00:00', 'Hall 1')")
-	var showtimeID int
-	err := db.Dbx.Get(&showtimeID, "SELECT showtime_id FROM showtimes WHERE movie_id=1 AND showtime='2024-05-30 12:00:00' AND hall='Hall 1'")
-	if err != nil {
-		t.Fatalf("Failed to get showtime ID: %v", err)
+	const requests = 20
+	statuses := make(chan int, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		jsonValue, _ := json.Marshal(models.ShowtimeInput{MovieID: 1, Showtime: fmt.Sprintf("%s 12:%02d", day, i), HallID: 1})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/showtimes", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			statuses <- w.Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
 	}
+	assert.Equal(t, map[int]int{http.StatusCreated: 1, http.StatusBadRequest: requests - 1}, counts)
+
+	list, err := showtimes.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestCreateShowtimeSchedule(t *testing.T) {
+	// the hall shows 15 minutes of trailers and needs 15 minutes of cleaning,
+	// the movie lasts 90 minutes and the epic 200 minutes
+	tests := []struct {
+		name     string
+		existing string
+		movieID  int
+		showtime string
+		status   int
+	}{
+		{"After Short Movie", day + " 12:00", 2, day + " 14:00", http.StatusCreated},
+		{"During Cleaning", day + " 12:00", 2, day + " 13:50", http.StatusBadRequest},
+		{"During Long Movie", day + " 12:00", 3, day + " 15:45", http.StatusBadRequest},
+		{"After Long Movie", day + " 12:00", 3, day + " 15:50", http.StatusCreated},
+		{"Ending During Next", day + " 14:00", 2, day + " 12:30", http.StatusBadRequest},
+		{"Ending Before Next", day + " 14:00", 2, day + " 12:00", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			assert.NoError(t, store.Movies().Create(&models.Movie{Title: "Unused", Duration: 120, Genre: "Action"}))
+			assert.NoError(t, store.Movies().Create(&models.Movie{Title: "Short", Duration: 90, Genre: "Comedy"}))
+			assert.NoError(t, store.Movies().Create(&models.Movie{Title: "Epic", Duration: 200, Genre: "History"}))
+			layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10}}}
+			hall := models.Hall{Name: "Hall 1", Capacity: layout.Capacity(), Layout: layout, TrailerMinutes: 15, CleaningMinutes: 15}
+			assert.NoError(t, store.Halls().Create(&hall))
+			// the existing showtime is of the movie under test
+			assert.NoError(t, store.Showtimes().Create(&models.Showtime{MovieID: tt.movieID, Showtime: tt.existing, HallID: 1}))
+
+			router := gin.Default()
+			router.Use(apierror.Middleware())
+			router.POST("/showtimes", NewHandler(store).CreateShowtime)
+
+			jsonValue, _ := json.Marshal(models.ShowtimeInput{MovieID: tt.movieID, Showtime: tt.showtime, HallID: 1})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/showtimes", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestCreateShowtimeUnknownHall(t *testing.T) {
+	router, _ := setupRouter(t)
 
 	showtimeInput := models.ShowtimeInput{
 		MovieID:  1,
-		Showtime: "2024-05-30 20:00",
-		Hall:     "Hall 1",
+		Showtime: day + " 16:00",
+		HallID:   2,
 	}
 	jsonValue, _ := json.Marshal(showtimeInput)
+
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("PUT", "/showtimes/"+strconv.Itoa(showtimeID), bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("POST", "/showtimes", bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem apierror.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, []apierror.Violation{{Field: "hall_id", Rule: "exists", Message: "hall 2 does not exist"}}, problem.Violations)
+}
+
+func TestCreateShowtimeValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+		rules  []string
+	}{
+		{"Missing Fields", `{}`, []string{"movie_id", "showtime", "hall_id"}, []string{"required", "required", "required"}},
+		{"Invalid Format", `{"movie_id": 1, "showtime": "30/05/2030 16:00", "hall_id": 1}`, []string{"showtime"}, []string{"showtime"}},
+		{"Wrong Type", `{"movie_id": "one", "showtime": "` + day + ` 16:00", "hall_id": 1}`, []string{"movie_id"}, []string{"type"}},
+		{"Past", `{"movie_id": 1, "showtime": "2024-05-30 16:00", "hall_id": 1}`, []string{"showtime"}, []string{"future"}},
+		{"Unknown References", `{"movie_id": 7, "showtime": "` + day + ` 16:00", "hall_id": 9}`, []string{"movie_id", "hall_id"}, []string{"exists", "exists"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, showtimes := setupRouter(t)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/showtimes", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem apierror.Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "invalid_fields", problem.Code)
+			var fields, rules []string
+			for _, violation := range problem.Violations {
+				fields = append(fields, violation.Field)
+				rules = append(rules, violation.Rule)
+			}
+			assert.Equal(t, tt.fields, fields)
+			assert.Equal(t, tt.rules, rules)
+
+			list, err := showtimes.ListPage(repository.ListQuery{Sort: "showtime_id", Limit: 10})
+			assert.NoError(t, err)
+			assert.Empty(t, list.Items)
+		})
+	}
+}
+
+func TestUpdatePastShowtime(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	past := models.Showtime{MovieID: 1, Showtime: "2024-05-30 12:00", HallID: 1}
+	assert.NoError(t, showtimes.Create(&past))
+
+	tests := []struct {
+		name     string
+		showtime string
+		status   int
+	}{
+		{"Same Start", "2024-05-30 12:00", http.StatusOK},
+		{"Moved To Past", "2024-05-30 14:00", http.StatusBadRequest},
+		{"Moved To Future", day + " 14:00", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonValue, _ := json.Marshal(models.ShowtimeInput{MovieID: 1, Showtime: tt.showtime, HallID: 1})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/showtimes/"+strconv.Itoa(past.ShowtimeID), bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", "*")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestUpdateShowtime(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	created := createShowtime(t, showtimes)
+
+	showtimeInput := models.ShowtimeInput{
+		MovieID:  1,
+		Showtime: day + " 20:00",
+		HallID:   1,
+	}
+	jsonValue, _ := json.Marshal(showtimeInput)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/showtimes/"+strconv.Itoa(created.ShowtimeID), bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var showtime models.Showtime
-	err = json.Unmarshal(w.Body.Bytes(), &showtime)
+	err := json.Unmarshal(w.Body.Bytes(), &showtime)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, showtime.MovieID)
-	assert.Equal(t, "2024-05-30 20:00", showtime.Showtime)
-	assert.Equal(t, "Hall 1", showtime.Hall)
+	assert.Equal(t, day+" 20:00", showtime.Showtime)
+	assert.Equal(t, 1, showtime.HallID)
 }
 
-func TestDeleteShowtime(t *testing.T) {
-	router := setupRouter()
+func TestPatchShowtime(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	created := createShowtime(t, showtimes)
 
-	db.Dbx.MustExec("INSERT INTO showtimes (movie_id, showtime, hall) VALUES (1, '2024-05-30 12:00:00', 'Hall 1')")
-	var showtimeID int
-	err := db.Dbx.Get(&showtimeID, "SELECT showtime_id FROM showtimes WHERE movie_id=1 AND showtime='2024-05-30 12:00:00' AND hall='Hall 1'")
-	if err != nil {
-		t.Fatalf("Failed to get showtime ID: %v", err)
-	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/showtimes/"+strconv.Itoa(created.ShowtimeID), bytes.NewBufferString(`{"showtime":"`+day+` 20:00"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	stored, err := showtimes.Get(created.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, day+" 20:00", stored.Showtime)
+	assert.Equal(t, 1, stored.MovieID)
+	assert.Equal(t, 1, stored.HallID)
+}
+
+func TestPatchShowtimeStale(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	created := createShowtime(t, showtimes)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("DELETE", "/showtimes/"+strconv.Itoa(showtimeID), nil)
+	req, _ := http.NewRequest("PATCH", "/showtimes/"+strconv.Itoa(created.ShowtimeID), bytes.NewBufferString(`{"hall_id":2}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"0"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	stored, err := showtimes.Get(created.ShowtimeID)
+	assert.NoError(t, err)
+	assert.Equal(t, created, stored)
+}
+
+func TestDeleteShowtime(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	created := createShowtime(t, showtimes)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/showtimes/"+strconv.Itoa(created.ShowtimeID), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := showtimes.Get(created.ShowtimeID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestDeleteShowtimeInUse(t *testing.T) {
+	store := memory.NewStore()
+	assert.NoError(t, store.Users().Create(&models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}))
+	assert.NoError(t, store.Movies().Create(&models.Movie{Title: "Sample Movie", Duration: 120, Genre: "Action"}))
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10}}}
+	assert.NoError(t, store.Halls().Create(&models.Hall{Name: "Hall 1", Capacity: layout.Capacity(), Layout: layout}))
+	showtime := models.Showtime{MovieID: 1, Showtime: day + " 12:00", HallID: 1}
+	assert.NoError(t, store.Showtimes().Create(&showtime))
+	assert.NoError(t, store.Bookings().Create(&models.Booking{UserID: 1, ShowtimeID: showtime.ShowtimeID, Seat: "A1"}))
+
+	handler := NewHandler(store)
+	router := gin.Default()
+	router.Use(apierror.Middleware())
+	router.DELETE("/showtimes/:id", handler.DeleteShowtime)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/showtimes/"+strconv.Itoa(showtime.ShowtimeID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var problem apierror.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "showtime_in_use", problem.Code)
+	assert.Equal(t, ShowtimeInUseError, problem.Detail)
+
+	_, err := store.Showtimes().Get(showtime.ShowtimeID)
+	assert.NoError(t, err)
+}
+
+func TestUpdateShowtimeShift(t *testing.T) {
+	router, showtimes := setupRouter(t)
+	created := createShowtime(t, showtimes)
+
+	// moving the showtime by ten minutes overlaps its old slot only
+	jsonValue, _ := json.Marshal(models.ShowtimeInput{MovieID: 1, Showtime: day + " 12:10", HallID: 1})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/showtimes/"+strconv.Itoa(created.ShowtimeID), bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateShowtimeNotFound(t *testing.T) {
+	router, _ := setupRouter(t)
+
+	jsonValue, _ := json.Marshal(models.ShowtimeInput{MovieID: 1, Showtime: day + " 12:00", HallID: 1})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/showtimes/42", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// recordingNotifier records the changes it is told about
+type recordingNotifier struct {
+	changes []models.BookingChange
+}
+
+func (n *recordingNotifier) BookingsChanged(showtime models.Showtime, changes []models.BookingChange) {
+	n.changes = append(n.changes, changes...)
+}
+
+func TestUpdateShowtimeBookings(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   models.ShowtimeInput
+		query   string
+		status  int
+		changes []models.BookingChange
+		seats   []string
+	}{
+		{
+			name:   "Time Change",
+			input:  models.ShowtimeInput{MovieID: 1, Showtime: day + " 18:00", HallID: 1},
+			status: http.StatusOK,
+			changes: []models.BookingChange{
+				{BookingID: 1, UserID: 1, Seat: "A2", Action: models.BookingKept},
+				{BookingID: 2, UserID: 1, Seat: "A8", Action: models.BookingKept},
+				{BookingID: 3, UserID: 1, Seat: "B4", Action: models.BookingKept},
+			},
+			seats: []string{"A2", "A8", "B4"},
+		},
+		{
+			name:   "Hall Change",
+			input:  models.ShowtimeInput{MovieID: 1, Showtime: day + " 12:00", HallID: 2},
+			status: http.StatusConflict,
+			changes: []models.BookingChange{
+				{BookingID: 1, UserID: 1, Seat: "A2", Action: models.BookingKept},
+				{BookingID: 2, UserID: 1, Seat: "A8", NewSeat: "A1", Action: models.BookingRebooked},
+				{BookingID: 3, UserID: 1, Seat: "B4", NewSeat: "B1", Action: models.BookingRebooked},
+			},
+			seats: []string{"A2", "A8", "B4"},
+		},
+		{
+			name:   "Forced Hall Change",
+			input:  models.ShowtimeInput{MovieID: 1, Showtime: day + " 12:00", HallID: 2},
+			query:  "?force=true",
+			status: http.StatusOK,
+			changes: []models.BookingChange{
+				{BookingID: 1, UserID: 1, Seat: "A2", Action: models.BookingKept},
+				{BookingID: 2, UserID: 1, Seat: "A8", NewSeat: "A1", Action: models.BookingRebooked},
+				{BookingID: 3, UserID: 1, Seat: "B4", NewSeat: "B1", Action: models.BookingRebooked},
+			},
+			seats: []string{"A2", "A1", "B1"},
+		},
+		{
+			name:   "Forced Change To Small Hall",
+			input:  models.ShowtimeInput{MovieID: 1, Showtime: day + " 12:00", HallID: 3},
+			query:  "?force=true",
+			status: http.StatusOK,
+			changes: []models.BookingChange{
+				{BookingID: 1, UserID: 1, Seat: "A2", Action: models.BookingKept},
+				{BookingID: 2, UserID: 1, Seat: "A8", NewSeat: "A1", Action: models.BookingRebooked},
+				{BookingID: 3, UserID: 1, Seat: "B4", Action: models.BookingCancelled},
+			},
+			seats: []string{"A2", "A1"},
+		},
+		{
+			name:   "Movie Change",
+			input:  models.ShowtimeInput{MovieID: 2, Showtime: day + " 12:00", HallID: 1},
+			status: http.StatusConflict,
+			changes: []models.BookingChange{
+				{BookingID: 1, UserID: 1, Seat: "A2", Action: models.BookingKept},
+				{BookingID: 2, UserID: 1, Seat: "A8", Action: models.BookingKept},
+				{BookingID: 3, UserID: 1, Seat: "B4", Action: models.BookingKept},
+			},
+			seats: []string{"A2", "A8", "B4"},
+		},
+		{
+			name:   "Invalid Force",
+			input:  models.ShowtimeInput{MovieID: 1, Showtime: day + " 12:00", HallID: 2},
+			query:  "?force=maybe",
+			status: http.StatusBadRequest,
+			seats:  []string{"A2", "A8", "B4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			assert.NoError(t, store.Users().Create(&models.User{Username: "alice", Password: "hash", Email: "alice@example.com", Role: models.RoleCustomer}))
+			assert.NoError(t, store.Movies().Create(&models.Movie{Title: "Sample Movie", Duration: 120, Genre: "Action"}))
+			assert.NoError(t, store.Movies().Create(&models.Movie{Title: "Other Movie", Duration: 90, Genre: "Comedy"}))
+			for i, layout := range []models.HallLayout{
+				{Rows: []models.HallRow{{Label: "A", Seats: 10}, {Label: "B", Seats: 5, Category: "premium"}}},
+				{Rows: []models.HallRow{{Label: "A", Seats: 5}, {Label: "B", Seats: 2, Category: "premium"}}},
+				{Rows: []models.HallRow{{Label: "A", Seats: 2}}},
+			} {
+				assert.NoError(t, store.Halls().Create(&models.Hall{Name: "Hall " + strconv.Itoa(i+1), Capacity: layout.Capacity(), Layout: layout}))
+			}
+			showtime := models.Showtime{MovieID: 1, Showtime: day + " 12:00", HallID: 1}
+			assert.NoError(t, store.Showtimes().Create(&showtime))
+			for _, seat := range []string{"A2", "A8", "B4"} {
+				assert.NoError(t, store.Bookings().Create(&models.Booking{UserID: 1, ShowtimeID: showtime.ShowtimeID, Seat: seat}))
+			}
+
+			handler := NewHandler(store)
+			notifications := &recordingNotifier{}
+			handler.notifier = notifications
+			router := gin.Default()
+			router.Use(apierror.Middleware())
+			router.PUT("/showtimes/:id", handler.UpdateShowtime)
+
+			jsonValue, _ := json.Marshal(tt.input)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/showtimes/1"+tt.query, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", `"1"`)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.changes != nil {
+				var update models.ShowtimeUpdate
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &update))
+				assert.Equal(t, tt.changes, update.AffectedBookings)
+			}
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.changes, notifications.changes)
+			} else {
+				assert.Empty(t, notifications.changes)
+			}
+
+			bookings, err := store.Bookings().ListForShowtime(showtime.ShowtimeID)
+			assert.NoError(t, err)
+			var seats []string
+			for _, booking := range bookings {
+				seats = append(seats, booking.Seat)
+			}
+			assert.Equal(t, tt.seats, seats)
+		})
+	}
 }
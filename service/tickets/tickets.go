@@ -0,0 +1,229 @@
+package tickets
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth"
+	"one-way-ticket/config"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/tickets"
+	"one-way-ticket/validation"
+)
+
+var log = logrus.New()
+
+const (
+	InvalidBookingID     = "Invalid booking ID"
+	BookingNotFoundError = "Booking not found"
+	TicketOwnerError     = "Ticket belongs to another user"
+	TicketCancelledError = "Booking of the ticket is cancelled"
+	UnpaidTicketError    = "Booking of the ticket is not paid yet"
+	InvalidTicketError   = "Ticket is not valid"
+	OutdatedTicketError  = "Ticket is outdated, its booking moved to another seat or showtime"
+	TicketUsedError      = "Ticket was used already"
+	CheckInNotOpenError  = "Check-in for this showtime is not open yet"
+	CheckInClosedError   = "Check-in for this showtime is closed"
+)
+
+var (
+	errInvalidBookingID = apierror.Validation("invalid_booking_id", InvalidBookingID)
+	errBookingNotFound  = apierror.NotFound("booking_not_found", BookingNotFoundError)
+	errNotOwner         = apierror.Forbidden("ticket_owner", TicketOwnerError)
+	errCancelled        = apierror.Gone("ticket_cancelled", TicketCancelledError)
+	errUnpaid           = apierror.Conflict("ticket_unpaid", UnpaidTicketError)
+	errOutdated         = apierror.Conflict("ticket_outdated", OutdatedTicketError)
+	errUsed             = apierror.Conflict("ticket_used", TicketUsedError)
+	errNotOpen          = apierror.Unprocessable("checkin_not_open", CheckInNotOpenError)
+	errClosed           = apierror.Unprocessable("checkin_closed", CheckInClosedError)
+	errInvalid          = apierror.Validation("invalid_ticket", InvalidTicketError)
+)
+
+type Handler struct {
+	repos  repository.Store
+	signer *tickets.Signer
+	cfg    config.TicketsConfig
+}
+
+// NewHandler creates a new Handler issuing the tickets of the bookings of the
+// store, signed with key, and checking them in during the window of cfg
+func NewHandler(repos repository.Store, cfg config.TicketsConfig, key string) *Handler {
+	return &Handler{repos: repos, signer: tickets.NewSigner(key), cfg: cfg}
+}
+
+// ticketError returns the error reported for err, the booking of a ticket
+// that does not exist is not found
+func ticketError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return errBookingNotFound.Wrap(err)
+	}
+	return err
+}
+
+// admits checks that the ticket of the booking admits to its showtime: the
+// booking is active and paid with an order. Bookings without an order are
+// sold by staff at the box office and admit when staff own them, customers
+// always pay with an order.
+func (h *Handler) admits(booking models.Booking) error {
+	if booking.Status == models.BookingStatusCancelled {
+		return errCancelled
+	}
+	if booking.OrderID == nil {
+		owner, err := h.repos.Users().Get(booking.UserID)
+		if err != nil {
+			return err
+		}
+		if !auth.HasPermission(owner.Role, auth.ManageBookings) {
+			return errUnpaid
+		}
+		return nil
+	}
+	order, err := h.repos.Orders().Get(*booking.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != models.OrderPaid {
+		return errUnpaid
+	}
+	return nil
+}
+
+// issue returns the ticket of the booking id to its owner, or to staff
+func (h *Handler) issue(c *gin.Context, id int) (models.Ticket, error) {
+	booking, err := h.repos.Bookings().Get(id)
+	if err != nil {
+		return models.Ticket{}, err
+	}
+	if !auth.ActsFor(c, booking.UserID) {
+		return models.Ticket{}, errNotOwner
+	}
+	if err := h.admits(booking); err != nil {
+		return models.Ticket{}, err
+	}
+
+	token, err := h.signer.Sign(tickets.Claims{BookingID: booking.BookingID, ShowtimeID: booking.ShowtimeID, Seat: booking.Seat})
+	if err != nil {
+		return models.Ticket{}, err
+	}
+	return models.Ticket{
+		BookingID:   booking.BookingID,
+		ShowtimeID:  booking.ShowtimeID,
+		Seat:        booking.Seat,
+		Token:       token,
+		CheckedInAt: booking.CheckedInAt,
+	}, nil
+}
+
+// GetTicket returns the ticket of a booking with its token as text
+func (h *Handler) GetTicket(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidBookingID)
+		return
+	}
+
+	ticket, err := h.issue(c, id)
+	if err != nil {
+		apierror.Abort(c, ticketError(err))
+		return
+	}
+	c.JSON(http.StatusOK, ticket)
+}
+
+// GetTicketQR returns the token of the ticket of a booking as a PNG QR code
+func (h *Handler) GetTicketQR(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, errInvalidBookingID)
+		return
+	}
+
+	ticket, err := h.issue(c, id)
+	if err != nil {
+		apierror.Abort(c, ticketError(err))
+		return
+	}
+	png, err := tickets.QRCode(ticket.Token)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// CheckIn admits the holder of a ticket at the entrance. The ticket must be
+// signed, match its booking, be scanned during the check-in window of its
+// showtime and not have been used before; marking it as used is atomic so
+// that a ticket scanned twice at once admits only once.
+func (h *Handler) CheckIn(c *gin.Context) {
+	var input models.CheckInInput
+	if err := validation.BindJSON(c, &input); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	ticket, err := h.checkIn(input.Token, time.Now())
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Warn("Ticket refused at check-in")
+		apierror.Abort(c, ticketError(err))
+		return
+	}
+	log.Info("Ticket checked in for booking ", ticket.BookingID)
+	c.JSON(http.StatusOK, ticket)
+}
+
+func (h *Handler) checkIn(token string, now time.Time) (models.Ticket, error) {
+	claims, err := h.signer.Verify(token)
+	if err != nil {
+		return models.Ticket{}, errInvalid.Wrap(err)
+	}
+	booking, err := h.repos.Bookings().Get(claims.BookingID)
+	if err != nil {
+		return models.Ticket{}, err
+	}
+	if err := h.admits(booking); err != nil {
+		return models.Ticket{}, err
+	}
+	if booking.ShowtimeID != claims.ShowtimeID || booking.Seat != claims.Seat {
+		return models.Ticket{}, errOutdated
+	}
+	if booking.CheckedInAt != nil {
+		return models.Ticket{}, errUsed
+	}
+
+	showtime, err := h.repos.Showtimes().Get(booking.ShowtimeID)
+	if err != nil {
+		return models.Ticket{}, err
+	}
+	start, err := showtime.Start()
+	if err != nil {
+		return models.Ticket{}, err
+	}
+	if now.Before(start.Add(-h.cfg.CheckInOpensBefore)) {
+		return models.Ticket{}, errNotOpen
+	}
+	if now.After(start.Add(h.cfg.CheckInClosesAfter)) {
+		return models.Ticket{}, errClosed
+	}
+
+	err = h.repos.Bookings().CheckIn(booking.BookingID, now)
+	if errors.Is(err, repository.ErrConflict) {
+		return models.Ticket{}, errUsed.Wrap(err)
+	}
+	if err != nil {
+		return models.Ticket{}, err
+	}
+	return models.Ticket{
+		BookingID:   booking.BookingID,
+		ShowtimeID:  booking.ShowtimeID,
+		Seat:        booking.Seat,
+		Token:       token,
+		CheckedInAt: &now,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package tickets
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth"
+	"one-way-ticket/config"
+	"one-way-ticket/models"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/tickets"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testKey = "ticket-signing-key-with-32-characters"
+
+// setupTickets serves the ticket routes as user 1 with role, user 3 is staff
+// selling tickets at the box office. Showtime 1
+// starts in 30 minutes, its check-in is open; showtime 2 starts in 3 hours
+// and showtime 3 started 2 hours ago.
+func setupTickets(t *testing.T, role models.Role) (*gin.Engine, *memory.Store) {
+	store := memory.NewStore()
+	err := store.Movies().Create(&models.Movie{Title: "Sample Movie", Duration: 120, Genre: "Action"})
+	if err != nil {
+		t.Fatalf("Failed to create movie: %v", err)
+	}
+	for _, name := range []string{"testuser", "other"} {
+		err = store.Users().Create(&models.User{Username: name, Password: "password", Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+	err = store.Users().Create(&models.User{Username: "boxoffice", Password: "password", Email: "boxoffice@example.com", Role: models.RoleStaff})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	layout := models.HallLayout{Rows: []models.HallRow{{Label: "A", Seats: 10}}}
+	err = store.Halls().Create(&models.Hall{Name: "Hall 1", Capacity: layout.Capacity(), Layout: layout})
+	if err != nil {
+		t.Fatalf("Failed to create hall: %v", err)
+	}
+	for _, start := range []time.Duration{30 * time.Minute, 3 * time.Hour, -2 * time.Hour} {
+		showtime := models.Showtime{MovieID: 1, HallID: 1, Showtime: time.Now().UTC().Add(start).Format("2006-01-02 15:04:05")}
+		if err := store.Showtimes().Create(&showtime); err != nil {
+			t.Fatalf("Failed to create showtime: %v", err)
+		}
+	}
+
+	handler := NewHandler(store, config.TicketsConfig{CheckInOpensBefore: time.Hour, CheckInClosesAfter: 30 * time.Minute}, testKey)
+	r := gin.Default()
+	r.Use(apierror.Middleware())
+	r.Use(func(c *gin.Context) {
+		c.Set(auth.UserIDKey, uint(1))
+		c.Set(auth.RoleKey, role)
+	})
+	r.GET("/bookings/:id/ticket", handler.GetTicket)
+	r.GET("/bookings/:id/ticket/qr", handler.GetTicketQR)
+	r.POST("/checkin", handler.CheckIn)
+	return r, store
+}
+
+// createBooking books the seat with a paid order
+func createBooking(t *testing.T, store *memory.Store, userID, showtimeID int, seat string) models.Booking {
+	order := models.Order{UserID: userID, ShowtimeID: showtimeID, Status: models.OrderPaid}
+	if err := store.Orders().Create(&order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	booking := models.Booking{UserID: userID, ShowtimeID: showtimeID, Seat: seat, OrderID: &order.OrderID}
+	if err := store.Bookings().Create(&booking); err != nil {
+		t.Fatalf("Failed to create booking: %v", err)
+	}
+	return booking
+}
+
+func getTicket(router *gin.Engine, bookingID int, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/bookings/"+strconv.Itoa(bookingID)+"/ticket"+path, nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func postCheckIn(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(models.CheckInInput{Token: token})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/checkin", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func sign(t *testing.T, booking models.Booking) string {
+	token, err := tickets.NewSigner(testKey).Sign(tickets.Claims{BookingID: booking.BookingID, ShowtimeID: booking.ShowtimeID, Seat: booking.Seat})
+	if err != nil {
+		t.Fatalf("Failed to sign ticket: %v", err)
+	}
+	return token
+}
+
+func TestGetTicket(t *testing.T) {
+	router, store := setupTickets(t, models.RoleCustomer)
+	booking := createBooking(t, store, 1, 1, "A1")
+
+	w := getTicket(router, booking.BookingID, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ticket models.Ticket
+	err := json.Unmarshal(w.Body.Bytes(), &ticket)
+	assert.NoError(t, err)
+	assert.Equal(t, "A1", ticket.Seat)
+	claims, err := tickets.NewSigner(testKey).Verify(ticket.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, tickets.Claims{BookingID: booking.BookingID, ShowtimeID: 1, Seat: "A1"}, claims)
+
+	w = getTicket(router, booking.BookingID, "/qr")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	_, err = png.Decode(w.Body)
+	assert.NoError(t, err)
+}
+
+func TestGetTicketRejected(t *testing.T) {
+	router, store := setupTickets(t, models.RoleCustomer)
+	other := createBooking(t, store, 2, 1, "A1")
+	cancelled := createBooking(t, store, 1, 1, "A2")
+	assert.NoError(t, store.Bookings().Cancel(cancelled.BookingID, "", 0, time.Now()))
+	order := models.Order{UserID: 1, ShowtimeID: 1, Status: models.OrderPending}
+	assert.NoError(t, store.Orders().Create(&order))
+	unpaid := models.Booking{UserID: 1, ShowtimeID: 1, Seat: "A3", OrderID: &order.OrderID}
+	assert.NoError(t, store.Bookings().Create(&unpaid))
+	// customers pay with orders, a booking of theirs without one is not paid
+	withoutOrder := models.Booking{UserID: 1, ShowtimeID: 1, Seat: "A4"}
+	assert.NoError(t, store.Bookings().Create(&withoutOrder))
+
+	tests := []struct {
+		name      string
+		bookingID int
+		status    int
+	}{
+		{"Other User", other.BookingID, http.StatusForbidden},
+		{"Cancelled", cancelled.BookingID, http.StatusGone},
+		{"Unpaid Order", unpaid.BookingID, http.StatusConflict},
+		{"Without Order", withoutOrder.BookingID, http.StatusConflict},
+		{"Missing", 42, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getTicket(router, tt.bookingID, "")
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestCheckIn(t *testing.T) {
+	router, store := setupTickets(t, models.RoleStaff)
+	booking := createBooking(t, store, 2, 1, "A1")
+	token := sign(t, booking)
+
+	w := postCheckIn(router, token)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ticket models.Ticket
+	err := json.Unmarshal(w.Body.Bytes(), &ticket)
+	assert.NoError(t, err)
+	assert.NotNil(t, ticket.CheckedInAt)
+	found, err := store.Bookings().Get(booking.BookingID)
+	assert.NoError(t, err)
+	assert.NotNil(t, found.CheckedInAt)
+
+	w = postCheckIn(router, token)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), TicketUsedError)
+}
+
+func TestCheckInBoxOffice(t *testing.T) {
+	router, store := setupTickets(t, models.RoleStaff)
+	// staff sell seats at the box office without an order
+	booking := models.Booking{UserID: 3, ShowtimeID: 1, Seat: "A1"}
+	assert.NoError(t, store.Bookings().Create(&booking))
+
+	w := postCheckIn(router, sign(t, booking))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCheckInRejected(t *testing.T) {
+	router, store := setupTickets(t, models.RoleStaff)
+	valid := createBooking(t, store, 2, 1, "A1")
+	early := createBooking(t, store, 2, 2, "A1")
+	late := createBooking(t, store, 2, 3, "A1")
+	cancelled := createBooking(t, store, 2, 1, "A2")
+	cancelledToken := sign(t, cancelled)
+	assert.NoError(t, store.Bookings().Cancel(cancelled.BookingID, "", 0, time.Now()))
+	moved := createBooking(t, store, 2, 1, "A3")
+	movedToken := sign(t, moved)
+	moved.Seat = "A4"
+	assert.NoError(t, store.Bookings().Update(&moved))
+	forged, err := tickets.NewSigner(testKey + "-forged").Sign(tickets.Claims{BookingID: valid.BookingID, ShowtimeID: 1, Seat: "A1"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		status  int
+		message string
+	}{
+		{"Forged", forged, http.StatusBadRequest, InvalidTicketError},
+		{"Garbage", "not-a-ticket", http.StatusBadRequest, InvalidTicketError},
+		{"Cancelled", cancelledToken, http.StatusGone, TicketCancelledError},
+		{"Moved", movedToken, http.StatusConflict, OutdatedTicketError},
+		{"Too Early", sign(t, early), http.StatusUnprocessableEntity, CheckInNotOpenError},
+		{"Too Late", sign(t, late), http.StatusUnprocessableEntity, CheckInClosedError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postCheckIn(router, tt.token)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+		})
+	}
+
+	found, err := store.Bookings().Get(valid.BookingID)
+	assert.NoError(t, err)
+	assert.Nil(t, found.CheckedInAt)
+}
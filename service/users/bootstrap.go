@@ -0,0 +1,45 @@
+package users
+
+import (
+	"fmt"
+	"one-way-ticket/auth/password"
+	"one-way-ticket/config"
+	"one-way-ticket/models"
+	"one-way-ticket/repository"
+)
+
+// BootstrapAdmin creates the configured admin account when the repository
+// does not contain any admin yet. It does nothing when no account is configured.
+func BootstrapAdmin(users repository.UserRepository, cfg config.BootstrapConfig) error {
+	if cfg.AdminUsername == "" {
+		return nil
+	}
+
+	count, err := users.CountByRole(models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to count admin users: %v", err)
+	}
+	if count > 0 {
+		log.Info("Admin user already exists, skipping bootstrap")
+		return nil
+	}
+
+	hash, err := password.Hash(cfg.AdminPassword)
+	if err != nil {
+		return err
+	}
+
+	user := models.User{
+		Username: cfg.AdminUsername,
+		Password: hash,
+		Email:    cfg.AdminEmail,
+		Role:     models.RoleAdmin,
+	}
+	err = users.Create(&user)
+	if err != nil {
+		return fmt.Errorf("failed to create admin user: %v", err)
+	}
+
+	log.Info("Bootstrap admin user created: ", user.Username)
+	return nil
+}
@@ -1,119 +1,244 @@
 package users
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"net/http"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth/password"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/service/etag"
+	"one-way-ticket/service/listing"
+	"one-way-ticket/validation"
 	"strconv"
 )
 
 var log = logrus.New()
 
 const (
-	InvalidUserId = "Invalid user ID"
+	InvalidUserId        = "Invalid user ID"
+	UserNotFoundError    = "User not found"
+	DuplicateUserError   = "A user with this username or email already exists"
+	UserInUseError       = "User has bookings or orders and cannot be deleted"
+	PasswordTooLongError = "Password must be at most 72 bytes long"
 )
 
-func GetUsers(c *gin.Context) {
-	var users []models.User
-	err := db.Dbx.Select(&users, "SELECT * FROM users")
+var (
+	errInvalidUserID   = apierror.Validation("invalid_user_id", InvalidUserId)
+	errUserNotFound    = apierror.NotFound("user_not_found", UserNotFoundError)
+	errDuplicateUser   = apierror.Conflict("user_exists", DuplicateUserError)
+	errUserInUse       = apierror.Conflict("user_in_use", UserInUseError)
+	errPasswordTooLong = apierror.Unprocessable("password_too_long", PasswordTooLongError)
+)
+
+type Handler struct {
+	users repository.UserRepository
+}
+
+func NewHandler(users repository.UserRepository) *Handler {
+	return &Handler{users: users}
+}
+
+func roleOrDefault(role models.Role) models.Role {
+	if role == "" {
+		return models.RoleCustomer
+	}
+	return role
+}
+
+// userError reports the errors of the user repository and of hashing passwords
+func userError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return errUserNotFound.Wrap(err)
+	case errors.Is(err, repository.ErrDuplicate):
+		return errDuplicateUser.Wrap(err)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errUserInUse.Wrap(err)
+	case errors.Is(err, password.ErrTooLong):
+		return errPasswordTooLong.Wrap(err)
+	}
+	return err
+}
+
+// listSpec whitelists the filters and sort fields of the user list
+var listSpec = listing.Spec{
+	Filters: map[string]listing.Param{
+		"role":     {Field: "role", Op: repository.OpEqual, Parse: listing.String},
+		"username": {Field: "username", Op: repository.OpEqual, Parse: listing.String},
+		"email":    {Field: "email", Op: repository.OpEqual, Parse: listing.String},
+	},
+	Sorts: []string{"user_id", "username", "email"},
+	Count: true,
+}
+
+func (h *Handler) GetUsers(c *gin.Context) {
+	query, err := listSpec.Parse(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	page, err := h.users.ListPage(query)
+	if err != nil {
+		apierror.Abort(c, userError(err))
+		return
+	}
+	listing.Respond(c, page)
 }
 
-func GetUser(c *gin.Context) {
+func (h *Handler) GetUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidUserId})
+		apierror.Abort(c, errInvalidUserID)
 		return
 	}
 
-	var user models.User
-	err = db.Dbx.Get(&user, "SELECT * FROM users WHERE user_id=$1", id)
+	user, err := h.users.Get(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, userError(err))
 		return
 	}
+	etag.Set(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
-func CreateUser(c *gin.Context) {
+func (h *Handler) CreateUser(c *gin.Context) {
 	var userInput models.UserInput
-	if err := c.ShouldBindJSON(&userInput); err != nil {
+	if err := validation.BindJSON(c, &userInput); err != nil {
 		log.Error("Error binding JSON: ", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, err)
 		return
 	}
 
-	query := `INSERT INTO Users (username, password, email) VALUES (:username, :password, :email) RETURNING user_id`
-	rows, err := db.Dbx.NamedQuery(query, &userInput)
+	hash, err := password.Hash(userInput.Password)
 	if err != nil {
-		log.Error("Error inserting user: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Error("Error hashing password: ", err)
+		apierror.Abort(c, userError(err))
 		return
 	}
 
 	user := models.User{
 		Username: userInput.Username,
-		Password: userInput.Password,
+		Password: hash,
 		Email:    userInput.Email,
+		Role:     roleOrDefault(userInput.Role),
 	}
 
-	if rows.Next() {
-		err = rows.Scan(&user.ID)
-		if err != nil {
-			log.Error("Error retrieving new user ID: ", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	err = h.users.Create(&user)
+	if err != nil {
+		log.Error("Error inserting user: ", err)
+		apierror.Abort(c, userError(err))
+		return
 	}
 
 	log.Info("User created successfully with ID:", user.ID)
+	etag.Set(c, user.Version)
 	c.JSON(http.StatusCreated, user)
 }
 
-func UpdateUser(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidUserId})
+// UpdateUser replaces the user, unless it changed since the client read the
+// version of its If-Match header
+func (h *Handler) UpdateUser(c *gin.Context) {
+	user, ok := h.current(c)
+	if !ok {
 		return
 	}
 
 	var userInput models.UserInput
-	if err := c.BindJSON(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := validation.BindJSON(c, &userInput); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
 
-	user := models.User{
-		ID:       uint(id),
-		Username: userInput.Username,
-		Password: userInput.Password,
-		Email:    userInput.Email,
+	hash, err := password.Hash(userInput.Password)
+	if err != nil {
+		log.Error("Error hashing password: ", err)
+		apierror.Abort(c, userError(err))
+		return
+	}
+	user.Username = userInput.Username
+	user.Password = hash
+	user.Email = userInput.Email
+	// a body without role keeps the role of the user
+	if userInput.Role != "" {
+		user.Role = userInput.Role
+	}
+	h.save(c, user)
+}
+
+// PatchUser applies a JSON merge patch to the user, unless it changed since
+// the client read the version of its If-Match header
+func (h *Handler) PatchUser(c *gin.Context) {
+	user, ok := h.current(c)
+	if !ok {
+		return
+	}
+
+	patch := models.UserPatch{Username: user.Username, Email: user.Email, Role: user.Role}
+	if err := validation.BindMergePatch(c, &patch); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	if patch.Password != "" {
+		hash, err := password.Hash(patch.Password)
+		if err != nil {
+			log.Error("Error hashing password: ", err)
+			apierror.Abort(c, userError(err))
+			return
+		}
+		user.Password = hash
 	}
+	user.Username = patch.Username
+	user.Email = patch.Email
+	user.Role = patch.Role
+	h.save(c, user)
+}
 
-	_, err = db.Dbx.NamedExec("UPDATE users SET username=:username, password=:password, email=:email WHERE user_id=:user_id", &user)
+// current reads the user of the request and checks that it is the version
+// the client expects
+func (h *Handler) current(c *gin.Context) (models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, errInvalidUserID)
+		return models.User{}, false
+	}
+
+	user, err := h.users.Get(id)
+	if err != nil {
+		apierror.Abort(c, userError(err))
+		return user, false
+	}
+	if err := etag.Check(c, user.Version); err != nil {
+		apierror.Abort(c, err)
+		return user, false
+	}
+	return user, true
+}
+
+// save updates the user, the update fails when the user changed since it was
+// read
+func (h *Handler) save(c *gin.Context, user models.User) {
+	if err := h.users.Update(&user); err != nil {
+		apierror.Abort(c, userError(err))
 		return
 	}
+	etag.Set(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
-func DeleteUser(c *gin.Context) {
+func (h *Handler) DeleteUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": InvalidUserId})
+		apierror.Abort(c, errInvalidUserID)
 		return
 	}
 
-	_, err = db.Dbx.Exec("DELETE FROM users WHERE user_id=$1", id)
+	err = h.users.Delete(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Abort(c, userError(err))
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{})
@@ -7,44 +7,58 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
-	"one-way-ticket/db"
+	"one-way-ticket/apierror"
+	"one-way-ticket/auth/password"
+	"one-way-ticket/config"
 	"one-way-ticket/models"
+	"one-way-ticket/repository"
+	"one-way-ticket/repository/memory"
+	"one-way-ticket/service/listing"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
-func setupRouter() *gin.Engine {
+func setupRouter() (*gin.Engine, repository.UserRepository) {
+	users := memory.NewStore().Users()
+	handler := NewHandler(users)
+
 	r := gin.Default()
-	r.GET("/users", GetUsers)
-	r.GET("/users/:id", GetUser)
-	r.POST("/users", CreateUser)
-	r.PUT("/users/:id", UpdateUser)
-	r.DELETE("/users/:id", DeleteUser)
-	return r
+	r.Use(apierror.Middleware())
+	r.GET("/users", handler.GetUsers)
+	r.GET("/users/:id", handler.GetUser)
+	r.POST("/users", handler.CreateUser)
+	r.PUT("/users/:id", handler.UpdateUser)
+	r.PATCH("/users/:id", handler.PatchUser)
+	r.DELETE("/users/:id", handler.DeleteUser)
+	return r, users
 }
 
 func TestMain(m *testing.M) {
-	err := db.Connect()
-	if err != nil {
-		return
-	}
-	_, err = db.Dbx.Exec("TRUNCATE TABLE bookings, showtimes, movies, users RESTART IDENTITY CASCADE")
+	// the lowest bcrypt cost keeps the tests fast
+	params := password.DefaultParams
+	params.BcryptCost = 4
+	hasher, err := password.NewHasher(params)
 	if err != nil {
 		panic(err)
 	}
-	code := m.Run()
-	err = db.Dbx.Close()
+	password.SetDefault(hasher)
+	os.Exit(m.Run())
+}
+
+func createUser(t *testing.T, users repository.UserRepository, username string) models.User {
+	user := models.User{Username: username, Password: "password", Email: username + "@example.com", Role: models.RoleCustomer}
+	err := users.Create(&user)
 	if err != nil {
-		panic(err)
+		t.Fatalf("Failed to create user: %v", err)
 	}
-	os.Exit(code)
+	return user
 }
 
 func TestGetUsers(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO users (username, password, email) VALUES ('testuser', 'password', 'test@example.com')")
+	router, users := setupRouter()
+	createUser(t, users, "testuser")
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/users", nil)
@@ -52,34 +66,41 @@ func TestGetUsers(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var users []models.User
-	err := json.Unmarshal(w.Body.Bytes(), &users)
+	var list listing.Response[models.User]
+	err := json.Unmarshal(w.Body.Bytes(), &list)
 	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+	assert.NotContains(t, w.Body.String(), "password")
 }
 
 func TestGetUser(t *testing.T) {
-	router := setupRouter()
-
-	db.Dbx.MustExec("INSERT INTO users (username, password, email) VALUES ('testuser2', 'password', 'test2@example.com')")
-	var userID int
-	err := db.Dbx.Get(&userID, "SELECT user_id FROM users WHERE username='testuser2' AND email='test2@example.com'")
-	if err != nil {
-		t.Fatalf("Failed to get user ID: %v", err)
-	}
+	router, users := setupRouter()
+	created := createUser(t, users, "testuser2")
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/users/"+strconv.Itoa(userID), nil)
+	req, _ := http.NewRequest("GET", "/users/"+strconv.Itoa(int(created.ID)), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var user models.User
-	err = json.Unmarshal(w.Body.Bytes(), &user)
+	err := json.Unmarshal(w.Body.Bytes(), &user)
 	assert.NoError(t, err)
+	assert.Equal(t, "testuser2", user.Username)
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	router, _ := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestCreateUser(t *testing.T) {
-	router := setupRouter()
+	router, users := setupRouter()
 
 	userInput := models.UserInput{
 		Username: "newuser",
@@ -98,17 +119,79 @@ func TestCreateUser(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &user)
 	assert.NoError(t, err)
 	assert.Equal(t, "newuser", user.Username)
+	assert.Equal(t, models.RoleCustomer, user.Role)
+
+	stored, err := users.Get(int(user.ID))
+	assert.NoError(t, err)
+	match, _, err := password.Verify("newpassword", stored.Password)
+	assert.NoError(t, err)
+	assert.True(t, match)
 }
 
-func TestUpdateUser(t *testing.T) {
-	router := setupRouter()
+func TestCreateUserValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      models.UserInput
+		violations []apierror.Violation
+	}{
+		{"Empty", models.UserInput{}, []apierror.Violation{
+			{Field: "username", Rule: "required", Message: "username is required"},
+			{Field: "password", Rule: "required", Message: "password is required"},
+			{Field: "email", Rule: "required", Message: "email is required"},
+		}},
+		{"Invalid Email", models.UserInput{Username: "newuser", Password: "newpassword", Email: "newuser"}, []apierror.Violation{
+			{Field: "email", Rule: "email", Message: "email must be an email address"},
+		}},
+		{"Short Fields", models.UserInput{Username: "ab", Password: "secret", Email: "ab@example.com"}, []apierror.Violation{
+			{Field: "username", Rule: "min", Message: "username must be at least 3 characters long"},
+			{Field: "password", Rule: "min", Message: "password must be at least 8 characters long"},
+		}},
+		{"Unknown Role", models.UserInput{Username: "newuser", Password: "newpassword", Email: "newuser@example.com", Role: "root"}, []apierror.Violation{
+			{Field: "role", Rule: "oneof", Message: "role must be one of admin, staff, customer"},
+		}},
+		{"Long Password", models.UserInput{Username: "newuser", Password: strings.Repeat("a", 73), Email: "newuser@example.com"}, []apierror.Violation{
+			{Field: "password", Rule: "bytes", Message: "password must be at most 72 bytes long"},
+		}},
+		{"Long Password In Bytes", models.UserInput{Username: "newuser", Password: strings.Repeat("é", 37), Email: "newuser@example.com"}, []apierror.Violation{
+			{Field: "password", Rule: "bytes", Message: "password must be at most 72 bytes long"},
+		}},
+	}
 
-	db.Dbx.MustExec("INSERT INTO users (username, password, email) VALUES ('updateuser', 'password', 'update@example.com')")
-	var userID int
-	err := db.Dbx.Get(&userID, "SELECT user_id FROM users WHERE username='updateuser' AND email='update@example.com'")
-	if err != nil {
-		t.Fatalf("Failed to get user ID: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, users := setupRouter()
+
+			jsonValue, _ := json.Marshal(tt.input)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem apierror.Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "invalid_fields", problem.Code)
+			assert.Equal(t, tt.violations, problem.Violations)
+
+			list, err := users.ListPage(repository.ListQuery{Sort: "user_id", Limit: 10})
+			assert.NoError(t, err)
+			assert.Empty(t, list.Items)
+		})
 	}
+}
+
+func TestPasswordTooLongError(t *testing.T) {
+	_, err := password.Hash(strings.Repeat("é", 37))
+	assert.ErrorIs(t, err, password.ErrTooLong)
+
+	problem := apierror.From(userError(err))
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "password_too_long", problem.Code)
+}
+
+func TestUpdateUser(t *testing.T) {
+	router, users := setupRouter()
+	created := createUser(t, users, "updateuser")
 
 	userInput := models.UserInput{
 		Username: "updateduser",
@@ -117,31 +200,120 @@ func TestUpdateUser(t *testing.T) {
 	}
 	jsonValue, _ := json.Marshal(userInput)
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("PUT", "/users/"+strconv.Itoa(userID), bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("PUT", "/users/"+strconv.Itoa(int(created.ID)), bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var user models.User
-	err = json.Unmarshal(w.Body.Bytes(), &user)
+	err := json.Unmarshal(w.Body.Bytes(), &user)
 	assert.NoError(t, err)
 	assert.Equal(t, "updateduser", user.Username)
+
+	stored, err := users.Get(int(created.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "updated@example.com", stored.Email)
 }
 
-func TestDeleteUser(t *testing.T) {
-	router := setupRouter()
+func TestUpdateUserKeepsRole(t *testing.T) {
+	router, users := setupRouter()
+	created := models.User{Username: "staffuser", Password: "password", Email: "staff@example.com", Role: models.RoleStaff}
+	assert.NoError(t, users.Create(&created))
 
-	db.Dbx.MustExec("INSERT INTO users (username, password, email) VALUES ('deleteuser', 'password', 'delete@example.com')")
-	var userID int
-	err := db.Dbx.Get(&userID, "SELECT user_id FROM users WHERE username='deleteuser' AND email='delete@example.com'")
-	if err != nil {
-		t.Fatalf("Failed to get user ID: %v", err)
-	}
+	jsonValue, _ := json.Marshal(models.UserInput{Username: "staffuser", Password: "newpassword", Email: "staff@example.com"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/users/"+strconv.Itoa(int(created.ID)), bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := users.Get(int(created.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleStaff, stored.Role)
+}
+
+func TestPatchUser(t *testing.T) {
+	router, users := setupRouter()
+	created := createUser(t, users, "patchuser")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/users/"+strconv.Itoa(int(created.ID)), bytes.NewBufferString(`{"email":"patched@example.com"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.NotContains(t, w.Body.String(), "password")
+
+	// the password is kept as it was not patched
+	stored, err := users.Get(int(created.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "patchuser", stored.Username)
+	assert.Equal(t, "patched@example.com", stored.Email)
+	assert.Equal(t, created.Password, stored.Password)
+}
+
+func TestPatchUserPassword(t *testing.T) {
+	router, users := setupRouter()
+	created := createUser(t, users, "patchpassword")
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("DELETE", "/users/"+strconv.Itoa(userID), nil)
+	req, _ := http.NewRequest("PATCH", "/users/"+strconv.Itoa(int(created.ID)), bytes.NewBufferString(`{"password":"newpassword"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := users.Get(int(created.ID))
+	assert.NoError(t, err)
+	ok, _, err := password.Verify("newpassword", stored.Password)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestDeleteUser(t *testing.T) {
+	router, users := setupRouter()
+	created := createUser(t, users, "deleteuser")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/users/"+strconv.Itoa(int(created.ID)), nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := users.Get(int(created.ID))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	users := memory.NewStore().Users()
+	bootstrap := config.BootstrapConfig{
+		AdminUsername: "bootstrapadmin",
+		AdminPassword: "bootstrap-password",
+		AdminEmail:    "bootstrap@example.com",
+	}
+
+	err := BootstrapAdmin(users, bootstrap)
+	assert.NoError(t, err)
+
+	admin, err := users.GetByUsername("bootstrapadmin")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, admin.Role)
+	assert.NotEqual(t, "bootstrap-password", admin.Password)
+
+	// a second run must not create another admin
+	bootstrap.AdminUsername = "secondadmin"
+	bootstrap.AdminEmail = "second@example.com"
+	err = BootstrapAdmin(users, bootstrap)
+	assert.NoError(t, err)
+
+	count, err := users.CountByRole(models.RoleAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
 }
@@ -0,0 +1,46 @@
+package sessions
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"one-way-ticket/dynamo"
+	"one-way-ticket/models"
+)
+
+// DynamoStore keeps the sessions in the DynamoDB sessions table
+type DynamoStore struct {
+	ddb dynamodbiface.DynamoDBAPI
+}
+
+// NewDynamoStore creates a new DynamoStore with the provided DynamoDB client
+func NewDynamoStore(ddb dynamodbiface.DynamoDBAPI) *DynamoStore {
+	return &DynamoStore{ddb: ddb}
+}
+
+func (s *DynamoStore) Create(sess models.Session) error {
+	return dynamo.CreateSession(s.ddb, sess)
+}
+
+func (s *DynamoStore) Get(token string) (*models.Session, error) {
+	return dynamo.GetSessionForUser(s.ddb, token)
+}
+
+func (s *DynamoStore) Delete(token string) error {
+	return dynamo.DeleteSession(s.ddb, token)
+}
+
+func (s *DynamoStore) ListByUser(userID uint) ([]models.Session, error) {
+	return dynamo.GetSessionsByUser(s.ddb, userID)
+}
+
+func (s *DynamoStore) PurgeExpired(now int64) (int, error) {
+	return dynamo.DeleteExpiredSessions(s.ddb, now)
+}
+
+func (s *DynamoStore) RotateRefreshToken(token, oldHash, newHash string, ttl int64) error {
+	err := dynamo.RotateRefreshToken(s.ddb, token, oldHash, newHash, ttl)
+	if errors.Is(err, dynamo.ErrRefreshTokenReused) {
+		return ErrRefreshTokenReused
+	}
+	return err
+}
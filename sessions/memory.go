@@ -0,0 +1,86 @@
+package sessions
+
+import (
+	"one-way-ticket/models"
+	"sync"
+)
+
+// MemoryStore keeps the sessions in a map. It is lost on restart and not
+// shared between instances, use it for tests and local development only.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]models.Session)}
+}
+
+func (s *MemoryStore) Create(sess models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sess.Token] = sess
+	return nil
+}
+
+func (s *MemoryStore) Get(token string) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok {
+		return nil, nil
+	}
+	return &sess, nil
+}
+
+func (s *MemoryStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+	return nil
+}
+
+func (s *MemoryStore) ListByUser(userID uint) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []models.Session
+	for _, sess := range s.sessions {
+		if sess.UserID == userID {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *MemoryStore) PurgeExpired(now int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for token, sess := range s.sessions {
+		if sess.TTL < now {
+			delete(s.sessions, token)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(token, oldHash, newHash string, ttl int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok || sess.RefreshToken != oldHash {
+		return ErrRefreshTokenReused
+	}
+	sess.RefreshToken = newHash
+	sess.TTL = ttl
+	s.sessions[token] = sess
+	return nil
+}
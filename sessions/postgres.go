@@ -0,0 +1,83 @@
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"one-way-ticket/models"
+)
+
+// PostgresStore keeps the sessions in the sessions table of the main
+// database, for deployments that do not want to run DynamoDB
+type PostgresStore struct {
+	dbx *sqlx.DB
+}
+
+// NewPostgresStore creates a new PostgresStore on the provided connection
+func NewPostgresStore(dbx *sqlx.DB) *PostgresStore {
+	return &PostgresStore{dbx: dbx}
+}
+
+func (s *PostgresStore) Create(sess models.Session) error {
+	query := `INSERT INTO sessions (token, user_id, refresh_token, ttl) VALUES (:token, :user_id, :refresh_token, :ttl)
+		ON CONFLICT (token) DO UPDATE SET user_id=EXCLUDED.user_id, refresh_token=EXCLUDED.refresh_token, ttl=EXCLUDED.ttl`
+	_, err := s.dbx.NamedExec(query, &sess)
+	if err != nil {
+		return fmt.Errorf("failed to insert session: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Get(token string) (*models.Session, error) {
+	var sess models.Session
+	err := s.dbx.Get(&sess, "SELECT token, user_id, refresh_token, ttl FROM sessions WHERE token=$1", token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %v", err)
+	}
+	return &sess, nil
+}
+
+func (s *PostgresStore) Delete(token string) error {
+	_, err := s.dbx.Exec("DELETE FROM sessions WHERE token=$1", token)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) ListByUser(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.dbx.Select(&sessions, "SELECT token, user_id, refresh_token, ttl FROM sessions WHERE user_id=$1", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	return sessions, nil
+}
+
+func (s *PostgresStore) PurgeExpired(now int64) (int, error) {
+	result, err := s.dbx.Exec("DELETE FROM sessions WHERE ttl < $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge sessions: %v", err)
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+func (s *PostgresStore) RotateRefreshToken(token, oldHash, newHash string, ttl int64) error {
+	result, err := s.dbx.Exec("UPDATE sessions SET refresh_token=$1, ttl=$2 WHERE token=$3 AND refresh_token=$4", newHash, ttl, token, oldHash)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session: %v", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
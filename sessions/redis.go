@@ -0,0 +1,185 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"one-way-ticket/models"
+	"strconv"
+	"time"
+)
+
+const (
+	sessionKeyPrefix     = "session:"
+	userSessionKeyPrefix = "user_sessions:"
+)
+
+// rotateScript compares and swaps the refresh token hash in a single step
+var rotateScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'refresh_token') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'refresh_token', ARGV[2], 'ttl', ARGV[3])
+redis.call('EXPIREAT', KEYS[1], ARGV[3])
+return 1
+`)
+
+// RedisStore keeps each session in a hash that Redis expires at the session
+// TTL, and the tokens of each user in a set. It works with any server that
+// speaks the Redis protocol and supports Lua scripts, e.g. Redis or Valkey.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a new RedisStore with the provided client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func sessionKey(token string) string {
+	return sessionKeyPrefix + token
+}
+
+func userSessionsKey(userID uint) string {
+	return userSessionKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+func (s *RedisStore) Create(sess models.Session) error {
+	ctx := context.Background()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(sess.Token),
+			"user_id", sess.UserID,
+			"refresh_token", sess.RefreshToken,
+			"ttl", sess.TTL,
+		)
+		pipe.ExpireAt(ctx, sessionKey(sess.Token), time.Unix(sess.TTL, 0))
+		pipe.SAdd(ctx, userSessionsKey(sess.UserID), sess.Token)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store session in redis: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Get(token string) (*models.Session, error) {
+	fields, err := s.client.HGetAll(context.Background(), sessionKey(token)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session from redis: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil // Session not found
+	}
+	return parseSession(token, fields)
+}
+
+func parseSession(token string, fields map[string]string) (*models.Session, error) {
+	userID, err := strconv.ParseUint(fields["user_id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user_id of session: %v", err)
+	}
+	ttl, err := strconv.ParseInt(fields["ttl"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ttl of session: %v", err)
+	}
+	return &models.Session{
+		Token:        token,
+		UserID:       uint(userID),
+		RefreshToken: fields["refresh_token"],
+		TTL:          ttl,
+	}, nil
+}
+
+func (s *RedisStore) Delete(token string) error {
+	ctx := context.Background()
+	userID, err := s.client.HGet(ctx, sessionKey(token), "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get session from redis: %v", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(token))
+		pipe.SRem(ctx, userSessionKeyPrefix+userID, token)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session from redis: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) ListByUser(userID uint) ([]models.Session, error) {
+	ctx := context.Background()
+	tokens, err := s.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions from redis: %v", err)
+	}
+
+	var sessions []models.Session
+	for _, token := range tokens {
+		sess, err := s.Get(token)
+		if err != nil {
+			return nil, err
+		}
+		// the session hash has expired, only the set member is left
+		if sess == nil {
+			continue
+		}
+		sessions = append(sessions, *sess)
+	}
+	return sessions, nil
+}
+
+// PurgeExpired removes the tokens of expired sessions from the user sets,
+// Redis expires the session hashes on its own
+func (s *RedisStore) PurgeExpired(now int64) (int, error) {
+	ctx := context.Background()
+	deleted := 0
+
+	iter := s.client.Scan(ctx, 0, userSessionKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		tokens, err := s.client.SMembers(ctx, key).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list sessions from redis: %v", err)
+		}
+
+		for _, token := range tokens {
+			sess, err := s.Get(token)
+			if err != nil {
+				return deleted, err
+			}
+			if sess != nil && sess.TTL >= now {
+				continue
+			}
+			err = s.client.Del(ctx, sessionKey(token)).Err()
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete session from redis: %v", err)
+			}
+			err = s.client.SRem(ctx, key, token).Err()
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete session from redis: %v", err)
+			}
+			deleted++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, fmt.Errorf("failed to scan redis: %v", err)
+	}
+	return deleted, nil
+}
+
+func (s *RedisStore) RotateRefreshToken(token, oldHash, newHash string, ttl int64) error {
+	rotated, err := rotateScript.Run(context.Background(), s.client, []string{sessionKey(token)}, oldHash, newHash, ttl).Int()
+	if err != nil {
+		return fmt.Errorf("failed to rotate session in redis: %v", err)
+	}
+	if rotated == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"one-way-ticket/config"
+	"one-way-ticket/db"
+	"one-way-ticket/dynamo"
+	"one-way-ticket/models"
+	"time"
+)
+
+var log = logrus.New()
+
+// ErrRefreshTokenReused is returned by RotateRefreshToken when the session no
+// longer holds the expected refresh token, i.e. it has already been used
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+// SessionStore persists the sessions behind access and refresh tokens
+type SessionStore interface {
+	// Create stores a new session, replacing any session with the same token
+	Create(sess models.Session) error
+	// Get returns the session, or nil when it does not exist
+	Get(token string) (*models.Session, error)
+	// Delete removes the session, deleting a missing session is not an error
+	Delete(token string) error
+	// ListByUser returns every session of the user
+	ListByUser(userID uint) ([]models.Session, error)
+	// PurgeExpired deletes the sessions whose TTL is before now and returns
+	// how many were deleted
+	PurgeExpired(now int64) (int, error)
+	// RotateRefreshToken atomically replaces the refresh token hash and the
+	// TTL of the session if it still holds oldHash, and returns
+	// ErrRefreshTokenReused otherwise
+	RotateRefreshToken(token, oldHash, newHash string, ttl int64) error
+}
+
+// NewStore builds the session store selected by the configuration. The
+// postgres store uses the db.Dbx connection, which must be open.
+func NewStore(cfg *config.Config) (SessionStore, error) {
+	switch cfg.Sessions.Store {
+	case config.SessionStoreDynamoDB:
+		ddb := dynamo.NewDynamoClient(cfg.Dynamo)
+		if err := dynamo.EnsureSessionsTable(ddb); err != nil {
+			return nil, err
+		}
+		return NewDynamoStore(ddb), nil
+	case config.SessionStoreMemory:
+		return NewMemoryStore(), nil
+	case config.SessionStorePostgres:
+		return NewPostgresStore(db.Dbx), nil
+	case config.SessionStoreRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Sessions.Redis.Address,
+			Password: cfg.Sessions.Redis.Password,
+			DB:       cfg.Sessions.Redis.DB,
+		})
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			return nil, fmt.Errorf("failed to connect to redis: %v", err)
+		}
+		return NewRedisStore(client), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", cfg.Sessions.Store)
+	}
+}
+
+// DeleteAllForUser revokes every session of the user
+func DeleteAllForUser(store SessionStore, userID uint) error {
+	sessions, err := store.ListByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		err = store.Delete(sess.Token)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartPurger deletes expired sessions every interval until ctx is done
+func StartPurger(ctx context.Context, store SessionStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				deleted, err := store.PurgeExpired(now.Unix())
+				if err != nil {
+					log.Error("Error purging expired sessions: ", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Info("Purged expired sessions: ", deleted)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,160 @@
+package sessions
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"one-way-ticket/config"
+	"one-way-ticket/db"
+	"one-way-ticket/db/migrations"
+	"one-way-ticket/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// testSessionStore checks the behaviour every SessionStore must share
+func testSessionStore(t *testing.T, store SessionStore, firstUser, secondUser uint) {
+	now := time.Now().Unix()
+
+	t.Run("Create And Get", func(t *testing.T) {
+		sess := models.Session{Token: "created", UserID: firstUser, RefreshToken: "hash", TTL: now + 3600}
+		assert.NoError(t, store.Create(sess))
+
+		found, err := store.Get("created")
+		assert.NoError(t, err)
+		assert.Equal(t, &sess, found)
+
+		missing, err := store.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, store.Create(models.Session{Token: "deleted", UserID: firstUser, RefreshToken: "hash", TTL: now + 3600}))
+		assert.NoError(t, store.Delete("deleted"))
+		assert.NoError(t, store.Delete("deleted"))
+
+		found, err := store.Get("deleted")
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+	})
+
+	t.Run("List By User", func(t *testing.T) {
+		assert.NoError(t, store.Create(models.Session{Token: "listed", UserID: secondUser, RefreshToken: "hash", TTL: now + 3600}))
+		assert.NoError(t, store.Create(models.Session{Token: "listed-too", UserID: secondUser, RefreshToken: "hash", TTL: now + 3600}))
+
+		found, err := store.ListByUser(secondUser)
+		assert.NoError(t, err)
+		assert.Len(t, found, 2)
+
+		assert.NoError(t, DeleteAllForUser(store, secondUser))
+		found, err = store.ListByUser(secondUser)
+		assert.NoError(t, err)
+		assert.Empty(t, found)
+	})
+
+	t.Run("Rotate Refresh Token", func(t *testing.T) {
+		assert.NoError(t, store.Create(models.Session{Token: "rotated", UserID: firstUser, RefreshToken: "old", TTL: now + 3600}))
+
+		assert.NoError(t, store.RotateRefreshToken("rotated", "old", "new", now+7200))
+		assert.ErrorIs(t, store.RotateRefreshToken("rotated", "old", "newer", now+7200), ErrRefreshTokenReused)
+		assert.ErrorIs(t, store.RotateRefreshToken("missing", "old", "new", now+7200), ErrRefreshTokenReused)
+
+		found, err := store.Get("rotated")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", found.RefreshToken)
+		assert.Equal(t, now+7200, found.TTL)
+	})
+
+	t.Run("Purge Expired", func(t *testing.T) {
+		assert.NoError(t, store.Create(models.Session{Token: "expired", UserID: firstUser, RefreshToken: "hash", TTL: now - 60}))
+
+		deleted, err := store.PurgeExpired(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		found, err := store.Get("expired")
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+		found, err = store.Get("created")
+		assert.NoError(t, err)
+		assert.NotNil(t, found)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testSessionStore(t, NewMemoryStore(), 1, 2)
+}
+
+func TestPostgresStore(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("database is not configured")
+	}
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Connect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db.Dbx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Dbx.MustExec("TRUNCATE TABLE sessions")
+	var users []uint
+	err = db.Dbx.Select(&users, `INSERT INTO users (username, password, email) VALUES
+		('sessionuser1', 'password', 'session1@example.com'), ('sessionuser2', 'password', 'session2@example.com')
+		RETURNING user_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSessionStore(t, NewPostgresStore(db.Dbx), users[0], users[1])
+}
+
+func TestRedisStore(t *testing.T) {
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("redis is not configured")
+	}
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Sessions.Redis.Address,
+		Password: cfg.Sessions.Redis.Password,
+		DB:       cfg.Sessions.Redis.DB,
+	})
+	defer client.Close()
+
+	err = client.FlushDB(context.Background()).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSessionStore(t, NewRedisStore(client), 1, 2)
+}
+
+func TestStartPurger(t *testing.T) {
+	store := NewMemoryStore()
+	assert.NoError(t, store.Create(models.Session{Token: "expired", UserID: 1, TTL: time.Now().Add(-time.Minute).Unix()}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartPurger(ctx, store, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		sess, _ := store.Get("expired")
+		return sess == nil
+	}, time.Second, 10*time.Millisecond)
+}
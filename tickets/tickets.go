@@ -0,0 +1,82 @@
+package tickets
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// ErrInvalidToken is returned for tokens that were not signed with the key of
+// the Signer or that cannot be decoded
+var ErrInvalidToken = errors.New("invalid ticket token")
+
+// QRSize is the width and height of the QR codes, in pixels
+const QRSize = 256
+
+// Claims identify the booking a ticket admits to. The seat and the showtime
+// are signed as well, so that a ticket no longer admits once its booking was
+// moved to another seat or showtime.
+type Claims struct {
+	BookingID  int    `json:"booking_id"`
+	ShowtimeID int    `json:"showtime_id"`
+	Seat       string `json:"seat"`
+}
+
+// Signer issues and verifies the tokens of the tickets. A token is the
+// base64url encoded JSON of its claims followed by a dot and their base64url
+// encoded HMAC-SHA256.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a Signer using key
+func NewSigner(key string) *Signer {
+	return &Signer{key: []byte(key)}
+}
+
+func (s *Signer) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Sign returns the token of the claims
+func (s *Signer) Sign(claims Claims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload)), nil
+}
+
+// Verify checks the signature of the token and returns its claims
+func (s *Signer) Verify(token string) (Claims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return Claims{}, ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// QRCode renders the token as a PNG QR code
+func QRCode(token string) ([]byte, error) {
+	return qrcode.Encode(token, qrcode.Medium, QRSize)
+}
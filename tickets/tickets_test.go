@@ -0,0 +1,64 @@
+package tickets
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKey = "ticket-signing-key-with-32-characters"
+
+func TestSignVerify(t *testing.T) {
+	signer := NewSigner(testKey)
+	claims := Claims{BookingID: 7, ShowtimeID: 3, Seat: "F12"}
+
+	token, err := signer.Sign(claims)
+	assert.NoError(t, err)
+
+	verified, err := signer.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims, verified)
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	signer := NewSigner(testKey)
+	token, err := signer.Sign(Claims{BookingID: 7, ShowtimeID: 3, Seat: "F12"})
+	assert.NoError(t, err)
+	forged, err := signer.Sign(Claims{BookingID: 8, ShowtimeID: 3, Seat: "F13"})
+	assert.NoError(t, err)
+	otherKey, err := NewSigner(testKey + "-other").Sign(Claims{BookingID: 7, ShowtimeID: 3, Seat: "F12"})
+	assert.NoError(t, err)
+
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Empty", ""},
+		{"No Signature", payload},
+		{"Swapped Payload", payload + "." + signature},
+		{"Other Key", otherKey},
+		{"Not Base64", "!!!." + signature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	data, err := QRCode("token")
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, QRSize, img.Bounds().Dx())
+}
@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"one-way-ticket/apierror"
+)
+
+// MergePatchContentType is the media type of JSON merge patches
+const MergePatchContentType = "application/merge-patch+json"
+
+var (
+	errPatchType    = apierror.New(http.StatusUnsupportedMediaType, "unsupported_media_type", "Patches must be sent as "+MergePatchContentType)
+	errInvalidPatch = apierror.Validation("invalid_patch", "Patch must be a JSON object")
+)
+
+// BindMergePatch applies the body of the request, a JSON merge patch as
+// defined by RFC 7386, to input, which holds the current values of the
+// record, and validates the result like BindJSON. Fields set to null in the
+// patch are reset to their zero value.
+func BindMergePatch(c *gin.Context, input interface{}) error {
+	patch, err := ReadMergePatch(c)
+	if err != nil {
+		return err
+	}
+	return MergePatch(patch, input)
+}
+
+// ReadMergePatch reads the JSON merge patch of the request, sent as
+// merge-patch+json or as plain JSON
+func ReadMergePatch(c *gin.Context) (map[string]interface{}, error) {
+	if contentType := c.ContentType(); contentType != MergePatchContentType && contentType != binding.MIMEJSON {
+		return nil, errPatchType
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, apierror.InvalidBody(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var patch interface{}
+	if err := decoder.Decode(&patch); err != nil {
+		return nil, apierror.InvalidBody(err)
+	}
+	object, ok := patch.(map[string]interface{})
+	if !ok {
+		return nil, errInvalidPatch
+	}
+	return object, nil
+}
+
+// MergePatch applies the patch to input and validates the result
+func MergePatch(patch map[string]interface{}, input interface{}) error {
+	current, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(current))
+	decoder.UseNumber()
+	var target interface{}
+	if err := decoder.Decode(&target); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(merge(target, patch))
+	if err != nil {
+		return err
+	}
+
+	// the fields removed by the patch are left out of merged
+	value := reflect.ValueOf(input).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(merged, input); err != nil {
+		return bodyError(err)
+	}
+	return bodyError(binding.Validator.ValidateStruct(input))
+}
+
+// merge applies the patch to target as RFC 7386 describes: objects are
+// merged member by member, null removes a member and other values replace
+// the target
+func merge(target, patch interface{}) interface{} {
+	object, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+	for name, value := range object {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = merge(merged[name], value)
+		}
+	}
+	return merged
+}
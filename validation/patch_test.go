@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	// examples of RFC 7386, appendix A
+	tests := []struct {
+		target string
+		patch  string
+		result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			var target, patch interface{}
+			assert.NoError(t, json.Unmarshal([]byte(tt.target), &target))
+			assert.NoError(t, json.Unmarshal([]byte(tt.patch), &patch))
+
+			result, err := json.Marshal(merge(target, patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.result, string(result))
+		})
+	}
+}
+
+func patch(contentType, body string, in *input) error {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("PATCH", "/", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	return BindMergePatch(c, in)
+}
+
+func TestBindMergePatch(t *testing.T) {
+	in := input{Name: "Hall", Seats: []string{"A1"}, Showtime: "2030-05-30 16:00"}
+	assert.NoError(t, patch(MergePatchContentType, `{"seats": ["B1", "B2"], "showtime": null}`, &in))
+	assert.Equal(t, input{Name: "Hall", Seats: []string{"B1", "B2"}}, in)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		code        string
+	}{
+		{"Required Removed", MergePatchContentType, `{"name": null}`, "invalid_fields"},
+		{"Wrong Type", "application/json", `{"name": 12}`, "invalid_fields"},
+		{"Not An Object", MergePatchContentType, `"name"`, "invalid_patch"},
+		{"Malformed", MergePatchContentType, `{"name": `, "invalid_body"},
+		{"Form", "application/x-www-form-urlencoded", `name=Hall`, "unsupported_media_type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := input{Name: "Hall", Seats: []string{"A1"}}
+			err := patch(tt.contentType, tt.body, &in)
+
+			var e *apierror.Error
+			if assert.ErrorAs(t, err, &e) {
+				assert.Equal(t, tt.code, e.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package validation reads the JSON bodies of requests and validates them
+// with the binding tags of the models, reporting every field breaking a rule
+// as a violation. Rules that need the store, e.g. that a referenced movie
+// exists, are checked by the handlers, which collect their violations with
+// Violations.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"one-way-ticket/apierror"
+	"one-way-ticket/models"
+)
+
+func init() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("validation: gin does not validate with go-playground/validator")
+	}
+	// fields are named as in the body
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	if err := engine.RegisterValidation("showtime", isShowtime); err != nil {
+		panic(err)
+	}
+	if err := engine.RegisterValidation("bytes", maxBytes); err != nil {
+		panic(err)
+	}
+}
+
+// isShowtime validates a showtime written as clients write them
+func isShowtime(fl validator.FieldLevel) bool {
+	_, err := time.Parse(models.ShowtimeLayout, fl.Field().String())
+	return err == nil
+}
+
+// maxBytes validates a string encoded in at most as many bytes as the
+// parameter of the rule, where max counts characters
+func maxBytes(fl validator.FieldLevel) bool {
+	n, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		panic("validation: bytes needs a number of bytes")
+	}
+	return len(fl.Field().String()) <= n
+}
+
+// BindJSON reads the JSON body of the request into input and validates it. A
+// body breaking the rules of input is reported with its violations.
+func BindJSON(c *gin.Context, input interface{}) error {
+	return bodyError(c.ShouldBindJSON(input))
+}
+
+// bodyError reports the error of reading or validating a body: the rules of
+// its fields it breaks, or the body itself when it cannot be read
+func bodyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var fields validator.ValidationErrors
+	if errors.As(err, &fields) {
+		var violations Violations
+		for _, field := range fields {
+			name := fieldPath(field)
+			violations.Add(name, field.Tag(), name+" "+message(field))
+		}
+		return violations.Err()
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		var violations Violations
+		violations.Add(typeErr.Field, "type", typeErr.Field+" must be "+typeName(typeErr.Type))
+		return violations.Err()
+	}
+	return apierror.InvalidBody(err)
+}
+
+// Violations collects the violations of the rules checked by a handler
+type Violations []apierror.Violation
+
+// Add records that field breaks rule
+func (v *Violations) Add(field, rule, message string) {
+	*v = append(*v, apierror.Violation{Field: field, Rule: rule, Message: message})
+}
+
+// Err returns the error reporting the violations, nil when there are none
+func (v Violations) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return apierror.Invalid(v...)
+}
+
+// fieldPath returns the path of the field in the body, the namespace of the
+// error without the name of the input struct
+func fieldPath(field validator.FieldError) string {
+	_, path, _ := strings.Cut(field.Namespace(), ".")
+	return path
+}
+
+// message describes the rule a field breaks
+func message(field validator.FieldError) string {
+	switch field.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be an email address"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(field.Param()), ", ")
+	case "showtime":
+		return "must be a time written as YYYY-MM-DD HH:MM"
+	case "bytes":
+		return fmt.Sprintf("must be at most %s bytes long", field.Param())
+	case "min", "max":
+		bound := "at least"
+		if field.Tag() == "max" {
+			bound = "at most"
+		}
+		switch field.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, field.Param())
+		case reflect.Slice, reflect.Map:
+			if field.Param() == "1" {
+				return fmt.Sprintf("must have %s 1 element", bound)
+			}
+			return fmt.Sprintf("must have %s %s elements", bound, field.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, field.Param())
+	}
+	return "is invalid"
+}
+
+// typeName names the JSON type of values of t
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"one-way-ticket/apierror"
+	"testing"
+)
+
+type row struct {
+	Label string `json:"label" binding:"required"`
+}
+
+type input struct {
+	Name     string   `json:"name" binding:"required,max=5"`
+	Seats    []string `json:"seats" binding:"required,min=1,dive,required"`
+	Rows     []row    `json:"rows" binding:"dive"`
+	Showtime string   `json:"showtime" binding:"omitempty,showtime"`
+}
+
+func bind(body string) error {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("POST", "/", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	var in input
+	return BindJSON(c, &in)
+}
+
+func TestBindJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		violations []apierror.Violation
+	}{
+		{"Nested Fields", `{"name": "Hall 12", "seats": ["A1", ""], "rows": [{"label": "A"}, {}], "showtime": "tomorrow"}`, []apierror.Violation{
+			{Field: "name", Rule: "max", Message: "name must be at most 5 characters long"},
+			{Field: "seats[1]", Rule: "required", Message: "seats[1] is required"},
+			{Field: "rows[1].label", Rule: "required", Message: "rows[1].label is required"},
+			{Field: "showtime", Rule: "showtime", Message: "showtime must be a time written as YYYY-MM-DD HH:MM"},
+		}},
+		{"Empty List", `{"name": "Hall", "seats": []}`, []apierror.Violation{
+			{Field: "seats", Rule: "min", Message: "seats must have at least 1 element"},
+		}},
+		{"Wrong Type", `{"name": 12, "seats": ["A1"]}`, []apierror.Violation{
+			{Field: "name", Rule: "type", Message: "name must be a string"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bind(tt.body)
+
+			var e *apierror.Error
+			if assert.ErrorAs(t, err, &e) {
+				assert.Equal(t, "invalid_fields", e.Code)
+				assert.Equal(t, tt.violations, e.Violations)
+			}
+		})
+	}
+
+	assert.NoError(t, bind(`{"name": "Hall", "seats": ["A1"], "showtime": "2030-05-30 16:00"}`))
+
+	var e *apierror.Error
+	if assert.ErrorAs(t, bind(`{"name": `), &e) {
+		assert.Equal(t, "invalid_body", e.Code)
+		assert.Empty(t, e.Violations)
+	}
+}